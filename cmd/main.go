@@ -4,12 +4,23 @@ import (
 	"backend/config"
 	"backend/docs"
 	"backend/internal/apache_jena"
+	"backend/internal/archive"
+	"backend/internal/classifier"
 	"backend/internal/handlers"
+	"backend/internal/knowledge_base"
 	"backend/internal/models"
+	"backend/internal/outbox"
+	"backend/internal/prediction"
+	"backend/internal/reconcile"
+	"backend/internal/report"
 	"backend/internal/repositories"
+	"backend/internal/retrain"
 	"backend/internal/router"
 	"backend/internal/services"
+	"context"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
@@ -41,26 +52,64 @@ func main() {
 	}
 
 	// Migrate the schema
-	err = db.AutoMigrate(&models.Class{}, &models.Parameter{}, &models.Service{})
+	err = db.AutoMigrate(&models.Class{}, &models.Parameter{}, &models.Service{}, &models.ServiceBatch{}, &models.ServicePrediction{}, &models.ApprovalFeedback{}, &models.FeatureSchema{}, &models.OutboxEvent{}, &models.PredictionJob{}, &models.RevenueRecord{}, &models.ArchivedReport{})
 	if err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
 
-	baseURL := fmt.Sprintf("http://%s:%s/%s", os.Getenv("KB_HOST"), os.Getenv("KB_PORT"), os.Getenv("KB_DATASET"))
-	publicURL := fmt.Sprintf("http://%s:%s/%s#", os.Getenv("PUBLIC_HOST"), os.Getenv("KB_PORT"), os.Getenv("KB_DATASET"))
-	jenaService := apache_jena.NewService(publicURL, baseURL, os.Getenv("KB_LOGIN"), os.Getenv("KB_PASSWORD"))
+	knowledgeBase := newKnowledgeBase(cfg)
 
 	serviceRepo := repositories.NewServiceRepository(db)
+	if err := serviceRepo.BackfillStatus(); err != nil {
+		log.Fatalf("failed to backfill service status: %v", err)
+	}
 	classRepo := repositories.NewClassRepository(db)
 	paramRepo := repositories.NewParameterRepository(db)
-	parameterService := services.NewParameterService(paramRepo, jenaService)
-	classService := services.NewClassService(classRepo, jenaService)
-	handler := handlers.NewHandler(serviceRepo, classRepo, paramRepo, jenaService, parameterService, classService)
+	outboxRepo := repositories.NewOutboxRepository(db)
+	predictionRepo := repositories.NewPredictionJobRepository(db)
+	servicePredictionRepo := repositories.NewServicePredictionRepository(db)
+	feedbackRepo := repositories.NewApprovalFeedbackRepository(db)
+	schemaRepo := repositories.NewFeatureSchemaRepository(db)
+	batchRepo := repositories.NewServiceBatchRepository(db)
+	revenueRepo := repositories.NewRevenueRepository(db)
+	archivedReportRepo := repositories.NewArchivedReportRepository(db)
+	kbOutbox := outbox.New(outboxRepo)
+	retrainTrigger := retrain.NewTrigger(cfg.MLRetrainURL, cfg.MLModelToken,
+		fmt.Sprintf("http://%s:8080/ml/training-data", cfg.PublicHost), paramRepo, cfg.MLRetrainDebounce)
+	predictionQueue := prediction.NewQueue(predictionRepo)
+	parameterService := services.NewParameterService(db, paramRepo, kbOutbox, retrainTrigger)
+	classService := services.NewClassService(db, classRepo, kbOutbox, retrainTrigger)
+	serviceService := services.NewServiceService(db, serviceRepo, servicePredictionRepo, feedbackRepo, batchRepo, kbOutbox, predictionQueue)
+	reconciler := reconcile.NewReconciler(serviceRepo, classRepo, paramRepo, kbOutbox, knowledgeBase, serviceService)
+	predictionPolicy := prediction.Policy{
+		AutoAssign:   cfg.AutoAssignThreshold,
+		Floor:        cfg.ReviewFloor,
+		AutoApprove:  cfg.AutoApprove,
+		Suggestions:  cfg.ReviewSuggestions,
+		StrictSchema: cfg.MLSchemaStrict,
+	}
+	if err := predictionPolicy.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	handler := handlers.NewHandler(serviceRepo, classRepo, paramRepo, outboxRepo, predictionRepo, servicePredictionRepo, feedbackRepo, schemaRepo, batchRepo, revenueRepo, archivedReportRepo, knowledgeBase, predictionPolicy, retrainTrigger, cfg.ReportCurrency, parameterService, classService, serviceService, reconciler)
+
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		runReconcile(reconciler, os.Args[2:])
+		return
+	}
 
 	migrate(classService, parameterService)
 
-	host := os.Getenv("PUBLIC_HOST")
-	docs.SwaggerInfo.Host = host + ":8080"
+	dispatcher := outbox.NewDispatcher(kbOutbox, knowledgeBase, cfg.OutboxInterval, cfg.OutboxMaxAttempts)
+	go dispatcher.Run(context.Background())
+
+	predictionPool := prediction.NewPool(predictionQueue, servicePredictionRepo, schemaRepo, serviceService, serviceRepo, classRepo, paramRepo, knowledgeBase,
+		newClassifier(cfg, serviceRepo), predictionPolicy, cfg.PredictionWorkers, cfg.PredictionBatchSize, cfg.PredictionInterval, cfg.PredictionMaxAttempts)
+	go predictionPool.Run(context.Background())
+	go retrainTrigger.Run(context.Background())
+	go newReportScheduler(cfg, archivedReportRepo, handler).Run(context.Background())
+
+	docs.SwaggerInfo.Host = cfg.PublicHost + ":8080"
 	docs.SwaggerInfo.Description = "This is a backend server."
 
 	r := router.SetupRouter(handler)
@@ -68,6 +117,87 @@ func main() {
 	log.Fatal(r.Run(":8080"))
 }
 
+// runReconcile implements the "reconcile" subcommand:
+//
+//	main reconcile [-dry-run=false] [-direction=kb|db]
+func runReconcile(reconciler *reconcile.Reconciler, args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", true, "only report differences, do not repair them")
+	direction := flags.String("direction", string(reconcile.ToKnowledgeBase), "source of truth for repairs: kb (Postgres -> graph) or db (graph -> Postgres)")
+	_ = flags.Parse(args)
+
+	dir, err := reconcile.ParseDirection(*direction)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := reconciler.Run(context.Background(), reconcile.Options{DryRun: *dryRun, Direction: dir})
+	if err != nil {
+		log.Fatalf("failed to reconcile: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+	if len(report.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+func newKnowledgeBase(cfg *config.Config) knowledge_base.KnowledgeBase {
+	switch cfg.KnowledgeBase {
+	case "memory":
+		slog.Warn("Using in-memory knowledge base, the graph is not persisted")
+		return knowledge_base.NewMemory()
+	case "jena":
+		baseURL := fmt.Sprintf("http://%s:%s/%s", cfg.KBHost, cfg.KBPort, cfg.KBDataset)
+		publicURL := fmt.Sprintf("http://%s:%s/%s#", cfg.PublicHost, cfg.KBPort, cfg.KBDataset)
+		return apache_jena.NewService(publicURL, baseURL, cfg.KBLogin, cfg.KBPassword)
+	default:
+		log.Fatalf("unknown knowledge base backend: %s", cfg.KnowledgeBase)
+		return nil
+	}
+}
+
+// newClassifier builds the prediction model. The local model learns from
+// approved services and also backs up the external one.
+func newClassifier(cfg *config.Config, serviceRepo repositories.ServiceRepository) classifier.Classifier {
+	local := classifier.NewLocal(serviceRepo.ListApproved, cfg.LocalNeighbours, cfg.LocalRetrainEvery)
+	switch cfg.Classifier {
+	case "local":
+		return local
+	case "http":
+		return classifier.WithFallback(classifier.NewHTTP(cfg.MLModelURL, cfg.MLModelBatchURL, cfg.MLModelToken, cfg.MLModelTimeout), local)
+	default:
+		log.Fatalf("unknown classifier: %s", cfg.Classifier)
+		return nil
+	}
+}
+
+// newReportScheduler sets up the scheduled reports, it has nothing to do
+// without REPORT_SCHEDULE.
+func newReportScheduler(cfg *config.Config, repo repositories.ArchivedReportRepository, handler *handlers.Handler) *archive.Scheduler {
+	reportRange, err := report.ParseGranularity(cfg.ReportScheduleRange)
+	if err != nil {
+		log.Fatalf("invalid REPORT_SCHEDULE_RANGE: %v", err)
+	}
+	grouping, err := report.ParseGrouping(cfg.ReportScheduleGrouping)
+	if err != nil {
+		log.Fatalf("invalid REPORT_SCHEDULE_GROUP_BY: %v", err)
+	}
+	format, err := report.ParseFormat(cfg.ReportScheduleFormat)
+	if err != nil {
+		log.Fatalf("invalid REPORT_SCHEDULE_FORMAT: %v", err)
+	}
+	schedules, err := archive.ParseSchedules(cfg.ReportSchedule, reportRange, grouping, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return archive.NewScheduler(repo, handler.GenerateReport, cfg.ReportCurrency, schedules)
+}
+
 func migrate(classService *services.ClassService, parameterService *services.ParameterService) {
 
 	_, err := parameterService.CreateParameter(models.ParameterView{Title: "Mobile Internet", ID: "mob_inet"}, false)
@@ -136,36 +266,39 @@ func migrate(classService *services.ClassService, parameterService *services.Par
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 104, Title: "Per-minute fee for long-distance traffic to other operators", AllowedParameters: []string{"voice_mob", "csd", "mg", "conc"}}, false)))
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 105, Title: "Per-minute fee for international traffic", AllowedParameters: []string{"voice_mob", "csd", "roaming", "mn", "vsr_roam", "services_service"}}, false)))
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1001, Title: "Subscription fee for voice services", AllowedParameters: []string{"voice_mob", "voice_ap", "voice_fee", "period_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1004, Title: "SMS in international roaming", AllowedParameters: []string{"voice_mob", "sms", "roaming", "conc", "fix_op", "mn_roam", "period_service"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1006, Title: "GPRS in national roaming", AllowedParameters: []string{"mob_inet", "roaming", "national_roam", "period_service", "one_time_service", "services_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1007, Title: "GPRS in international roaming", AllowedParameters: []string{"mob_inet", "roaming", "mn_roam", "period_service", "services_service"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1004, Title: "SMS in international roaming", AllowedParameters: []string{"voice_mob", "sms", "roaming", "conc", "fix_op", "mn_roam", "period_service"}, RequiredParameters: []string{"sms", "mn_roam"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1006, Title: "GPRS in national roaming", AllowedParameters: []string{"mob_inet", "roaming", "national_roam", "period_service", "one_time_service", "services_service", "discount"}, RequiredParameters: []string{"mob_inet", "national_roam"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1007, Title: "GPRS in international roaming", AllowedParameters: []string{"mob_inet", "roaming", "mn_roam", "period_service", "services_service"}, RequiredParameters: []string{"mob_inet", "mn_roam"}}, false)))
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1016, Title: "MMS", AllowedParameters: []string{"mms", "mts", "period_service", "dop_service", "discount"}}, false)))
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1027, Title: "Revenues from activation of voice services valid for over 6 months", AllowedParameters: []string{"voice_mob", "voice_fee", "period_service", "discount"}}, false)))
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1030, Title: "Revenues from voicemail", AllowedParameters: []string{"voice_mob", "period_service", "discount", "voice_mail"}}, false)))
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1033, Title: "Revenues from Internet access services", AllowedParameters: []string{"fix_inet", "one_time_service", "dop_service", "discount"}}, false)))
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1034, Title: "Revenues from telephony services", AllowedParameters: []string{"voice_fix", "period_service", "one_time_service", "discount"}}, false)))
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1100, Title: "Revenues from combined Voice/SMS/GPRS services", AllowedParameters: []string{"mob_inet", "voice_mob", "sms", "period_service", "services_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1121, Title: "Revenues from IoT geoanalytics", AllowedParameters: []string{"iot", "period_service", "dop_service", "discount", "geo"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1124, Title: "Revenues from IoT SMS", AllowedParameters: []string{"sms", "iot", "one_time_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1125, Title: "Revenues from IoT GPRS", AllowedParameters: []string{"mob_inet", "iot", "period_service", "one_time_service"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1126, Title: "Revenues from voice + CSD IoT", AllowedParameters: []string{"voice_mob", "csd", "one_time_service"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3001, Title: "_FB. Telephony - subscription fee per number", AllowedParameters: []string{"voice_fix", "voice_ap", "dop_service", "discount", "ep_for_number"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3002, Title: "_FB. Telephony - subscription fee per line", AllowedParameters: []string{"voice_fix", "one_time_service", "dop_service", "ep_for_line"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3003, Title: "_FB. Telephony - setup fee for subscriber number", AllowedParameters: []string{"voice_fix", "period_service", "one_time_service", "discount", "one-time_fee_for_number"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3009, Title: "_FB. Telephony - zonal outgoing calls to fixed operators", AllowedParameters: []string{"voice_fix", "fix_op", "voice_ap", "period_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3010, Title: "_FB. Telephony - zonal outgoing calls to SPC", AllowedParameters: []string{"voice_fix", "conc"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3019, Title: "_FB. Telephony - long-distance services from own subscribers", AllowedParameters: []string{"voice_fix", "mg", "period_service", "one_time_service", "dop_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3020, Title: "_FB. Telephony - international services from own subscribers", AllowedParameters: []string{"voice_fix", "mn", "one_time_service", "dop_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3031, Title: "_FB. Telephony - additional services", AllowedParameters: []string{"voice_fix", "one_time_service", "discount"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1120, Title: "Revenues from IoT"}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1121, ParentID: parent(1120), Title: "Revenues from IoT geoanalytics", AllowedParameters: []string{"iot", "period_service", "dop_service", "discount", "geo"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1124, ParentID: parent(1120), Title: "Revenues from IoT SMS", AllowedParameters: []string{"sms", "iot", "one_time_service", "discount"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1125, ParentID: parent(1120), Title: "Revenues from IoT GPRS", AllowedParameters: []string{"mob_inet", "iot", "period_service", "one_time_service"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 1126, ParentID: parent(1120), Title: "Revenues from voice + CSD IoT", AllowedParameters: []string{"voice_mob", "csd", "one_time_service"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3000, Title: "_FB. Telephony"}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3001, ParentID: parent(3000), Title: "_FB. Telephony - subscription fee per number", AllowedParameters: []string{"voice_fix", "voice_ap", "dop_service", "discount", "ep_for_number"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3002, ParentID: parent(3000), Title: "_FB. Telephony - subscription fee per line", AllowedParameters: []string{"voice_fix", "one_time_service", "dop_service", "ep_for_line"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3003, ParentID: parent(3000), Title: "_FB. Telephony - setup fee for subscriber number", AllowedParameters: []string{"voice_fix", "period_service", "one_time_service", "discount", "one-time_fee_for_number"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3009, ParentID: parent(3000), Title: "_FB. Telephony - zonal outgoing calls to fixed operators", AllowedParameters: []string{"voice_fix", "fix_op", "voice_ap", "period_service", "discount"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3010, ParentID: parent(3000), Title: "_FB. Telephony - zonal outgoing calls to SPC", AllowedParameters: []string{"voice_fix", "conc"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3019, ParentID: parent(3000), Title: "_FB. Telephony - long-distance services from own subscribers", AllowedParameters: []string{"voice_fix", "mg", "period_service", "one_time_service", "dop_service", "discount"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3020, ParentID: parent(3000), Title: "_FB. Telephony - international services from own subscribers", AllowedParameters: []string{"voice_fix", "mn", "one_time_service", "dop_service", "discount"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3031, ParentID: parent(3000), Title: "_FB. Telephony - additional services", AllowedParameters: []string{"voice_fix", "one_time_service", "discount"}}, false)))
 	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3309, Title: "FB_Television (CTV) - subscription fee per line", AllowedParameters: []string{"fix_ctv", "period_service", "dop_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3311, Title: "FB_Sale of goods, works, and services - Individuals - Double Play (CTV + Telephony) - Subscription fee", AllowedParameters: []string{"fix_ctv", "voice_fix", "period_service", "one_time_service"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3312, Title: "FB_Sale of goods, works, and services - Individuals - Internet Access - Double Play (CTV) - Subscription fee", AllowedParameters: []string{"fix_inet", "fix_ctv", "period_service", "one_time_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3313, Title: "FB_Sale of goods, works, and services - Individuals - Triple Play (Internet + CTV + Telephony) - Subscription fee", AllowedParameters: []string{"fix_inet", "fix_ctv", "voice_fix", "period_service", "one_time_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3314, Title: "FB_Sale of goods, works, and services - Individuals - Equipment rental for CTV service", AllowedParameters: []string{"fix_ctv", "period_service", "equipment_rent"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3320, Title: "FB_Sale of goods, works, and services - Individuals - Television (ICTV) - Subscription fee", AllowedParameters: []string{"fix_ictv", "period_service", "one_time_service", "dop_service"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3321, Title: "FB_Sale of goods, works, and services - Individuals - Internet Access - Double Play (ICTV) - Subscription fee", AllowedParameters: []string{"fix_inet", "fix_ictv", "period_service", "one_time_service", "dop_service"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3322, Title: "FB_Sale of goods, works, and services - Individuals - Double Play (ICTV + Telephony) - Subscription fee", AllowedParameters: []string{"fix_ictv", "voice_fix", "period_service", "one_time_service", "discount"}}, false)))
-	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3323, Title: "FB_Sale of goods, works, and services - Individuals - Triple Play (Internet + ICTV + Telephony) - Subscription fee", AllowedParameters: []string{"fix_inet", "fix_ictv", "voice_fix", "period_service", "one_time_service"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3310, Title: "FB_Sale of goods, works, and services - Individuals"}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3311, ParentID: parent(3310), Title: "FB_Sale of goods, works, and services - Individuals - Double Play (CTV + Telephony) - Subscription fee", AllowedParameters: []string{"fix_ctv", "voice_fix", "period_service", "one_time_service"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3312, ParentID: parent(3310), Title: "FB_Sale of goods, works, and services - Individuals - Internet Access - Double Play (CTV) - Subscription fee", AllowedParameters: []string{"fix_inet", "fix_ctv", "period_service", "one_time_service", "discount"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3313, ParentID: parent(3310), Title: "FB_Sale of goods, works, and services - Individuals - Triple Play (Internet + CTV + Telephony) - Subscription fee", AllowedParameters: []string{"fix_inet", "fix_ctv", "voice_fix", "period_service", "one_time_service", "discount"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3314, ParentID: parent(3310), Title: "FB_Sale of goods, works, and services - Individuals - Equipment rental for CTV service", AllowedParameters: []string{"fix_ctv", "period_service", "equipment_rent"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3320, ParentID: parent(3310), Title: "FB_Sale of goods, works, and services - Individuals - Television (ICTV) - Subscription fee", AllowedParameters: []string{"fix_ictv", "period_service", "one_time_service", "dop_service"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3321, ParentID: parent(3310), Title: "FB_Sale of goods, works, and services - Individuals - Internet Access - Double Play (ICTV) - Subscription fee", AllowedParameters: []string{"fix_inet", "fix_ictv", "period_service", "one_time_service", "dop_service"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3322, ParentID: parent(3310), Title: "FB_Sale of goods, works, and services - Individuals - Double Play (ICTV + Telephony) - Subscription fee", AllowedParameters: []string{"fix_ictv", "voice_fix", "period_service", "one_time_service", "discount"}}, false)))
+	err = errors.Join(err, second(classService.CreateClass(models.ClassView{ID: 3323, ParentID: parent(3310), Title: "FB_Sale of goods, works, and services - Individuals - Triple Play (Internet + ICTV + Telephony) - Subscription fee", AllowedParameters: []string{"fix_inet", "fix_ictv", "voice_fix", "period_service", "one_time_service"}}, false)))
 
 	if err != nil {
 		slog.Error("Error while migration", slog.Any("error", err))
@@ -175,3 +308,7 @@ func migrate(classService *services.ClassService, parameterService *services.Par
 func second[T any, T2 any](a T, b T2) T2 {
 	return b
 }
+
+func parent(id uint) *uint {
+	return &id
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"log/slog"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -10,6 +13,62 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
+
+	// KnowledgeBase selects the ontology store: "jena" (default) or "memory".
+	KnowledgeBase string
+	KBHost        string
+	KBPort        string
+	KBDataset     string
+	KBLogin       string
+	KBPassword    string
+	PublicHost    string
+
+	OutboxInterval    time.Duration
+	OutboxMaxAttempts int
+
+	// Classifier selects the class prediction model: "http" (default) calls
+	// the external model and falls back to the local one when it fails,
+	// "local" only uses the built-in model.
+	Classifier string
+	MLModelURL string
+	// MLModelBatchURL classifies many services per call, without it batches
+	// are sent to MLModelURL one by one.
+	MLModelBatchURL string
+	MLModelToken    string
+	MLModelTimeout  time.Duration
+	// MLSchemaStrict refuses to auto-assign classes from a model that does
+	// not report the feature schema it was trained on.
+	MLSchemaStrict bool
+	// MLRetrainURL receives a notification when classes or parameters
+	// change, empty disables it.
+	MLRetrainURL      string
+	MLRetrainDebounce time.Duration
+	LocalNeighbours   int
+	LocalRetrainEvery time.Duration
+
+	PredictionWorkers     int
+	PredictionBatchSize   int
+	PredictionInterval    time.Duration
+	PredictionMaxAttempts int
+
+	// Predictions with a top probability of at least AutoAssignThreshold set
+	// the class, below ReviewFloor no class is suggested, in between an
+	// expert chooses from ReviewSuggestions classes.
+	AutoAssignThreshold float64
+	ReviewFloor         float64
+	AutoApprove         bool
+	ReviewSuggestions   int
+
+	// ReportCurrency is the currency of the fiscal report and of imported
+	// revenue records that do not name one.
+	ReportCurrency string
+	// ReportSchedule lists cron expressions separated by semicolons, each
+	// run archives the report of the ReportScheduleRange period before it.
+	// Empty disables scheduled reports.
+	ReportSchedule         string
+	ReportScheduleRange    string
+	ReportScheduleGrouping string
+	ReportScheduleFormat   string
 }
 
 func NewConfig() *Config {
@@ -19,6 +78,44 @@ func NewConfig() *Config {
 		DBUser:     getEnv("DB_USER", "postgres"),
 		DBPassword: getEnv("DB_PASSWORD", "password"),
 		DBName:     getEnv("DB_NAME", "myapp"),
+
+		KnowledgeBase: getEnv("KB_BACKEND", "jena"),
+		KBHost:        os.Getenv("KB_HOST"),
+		KBPort:        os.Getenv("KB_PORT"),
+		KBDataset:     os.Getenv("KB_DATASET"),
+		KBLogin:       os.Getenv("KB_LOGIN"),
+		KBPassword:    os.Getenv("KB_PASSWORD"),
+		PublicHost:    os.Getenv("PUBLIC_HOST"),
+
+		OutboxInterval:    getDurationEnv("OUTBOX_INTERVAL", 5*time.Second),
+		OutboxMaxAttempts: getIntEnv("OUTBOX_MAX_ATTEMPTS", 10),
+
+		Classifier:        getEnv("CLASSIFIER", "http"),
+		MLModelURL:        os.Getenv("ML_MODEL_URL"),
+		MLModelBatchURL:   os.Getenv("ML_MODEL_BATCH_URL"),
+		MLModelToken:      os.Getenv("BEARER_TOKEN"),
+		MLModelTimeout:    getDurationEnv("ML_MODEL_TIMEOUT", 10*time.Second),
+		MLSchemaStrict:    getBoolEnv("ML_SCHEMA_STRICT", false),
+		MLRetrainURL:      os.Getenv("ML_RETRAIN_URL"),
+		MLRetrainDebounce: getDurationEnv("ML_RETRAIN_DEBOUNCE", 30*time.Second),
+		LocalNeighbours:   getIntEnv("LOCAL_CLASSIFIER_NEIGHBOURS", 5),
+		LocalRetrainEvery: getDurationEnv("LOCAL_CLASSIFIER_RETRAIN", 10*time.Minute),
+
+		PredictionWorkers:     getIntEnv("PREDICTION_WORKERS", 4),
+		PredictionBatchSize:   getIntEnv("PREDICTION_BATCH_SIZE", 20),
+		PredictionInterval:    getDurationEnv("PREDICTION_INTERVAL", 5*time.Second),
+		PredictionMaxAttempts: getIntEnv("PREDICTION_MAX_ATTEMPTS", 5),
+
+		AutoAssignThreshold: getFloatEnv("AUTO_ASSIGN_THRESHOLD", 0.7),
+		ReviewFloor:         getFloatEnv("REVIEW_FLOOR", 0.3),
+		AutoApprove:         getBoolEnv("AUTO_APPROVE", false),
+		ReviewSuggestions:   getIntEnv("REVIEW_SUGGESTIONS", 3),
+
+		ReportCurrency:         getEnv("REPORT_CURRENCY", "USD"),
+		ReportSchedule:         os.Getenv("REPORT_SCHEDULE"),
+		ReportScheduleRange:    getEnv("REPORT_SCHEDULE_RANGE", "month"),
+		ReportScheduleGrouping: getEnv("REPORT_SCHEDULE_GROUP_BY", "class"),
+		ReportScheduleFormat:   getEnv("REPORT_SCHEDULE_FORMAT", "xlsx"),
 	}
 }
 
@@ -28,3 +125,55 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getIntEnv(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid integer in environment, using default", slog.String("key", key), slog.Int("default", fallback))
+		return fallback
+	}
+	return parsed
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Invalid duration in environment, using default", slog.String("key", key), slog.Duration("default", fallback))
+		return fallback
+	}
+	return parsed
+}
+
+func getFloatEnv(key string, fallback float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		slog.Warn("Invalid number in environment, using default", slog.String("key", key), slog.Float64("default", fallback))
+		return fallback
+	}
+	return parsed
+}
+
+func getBoolEnv(key string, fallback bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Invalid boolean in environment, using default", slog.String("key", key), slog.Bool("default", fallback))
+		return fallback
+	}
+	return parsed
+}
@@ -1,7 +1,9 @@
 package apache_jena
 
 import (
+	"backend/internal/knowledge_base"
 	"backend/internal/models"
+	"backend/internal/sparql"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -21,6 +23,8 @@ type Service struct {
 	client   *http.Client
 }
 
+var _ knowledge_base.KnowledgeBase = (*Service)(nil)
+
 func NewService(prefix string, baseURL string, login, password string) *Service {
 	return &Service{
 		prefix:   prefix,
@@ -33,163 +37,154 @@ func NewService(prefix string, baseURL string, login, password string) *Service
 	}
 }
 
+func (s *Service) prefixes() ([]sparql.Prefix, error) {
+	iri, err := sparql.NewIRI(s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	return []sparql.Prefix{
+		{Name: "", IRI: iri},
+		{Name: "rdfs", IRI: sparql.MustIRI(rdfsNamespace)},
+	}, nil
+}
+
 func (s *Service) AddParameter(ctx context.Context, parameter models.ParameterView) error {
-	update := s.buildUpdateParameterQuery(parameter, false)
+	update, err := s.buildUpdateParameterQuery(parameter)
+	if err != nil {
+		return err
+	}
 
 	return s.runSparqlUpdate(ctx, update)
 }
 
-func (s *Service) buildUpdateParameterQuery(parameter models.ParameterView, forUpdate bool) string {
-	allowedClasses := make([]string, 0, len(parameter.AllowedClasses))
-	for _, class := range parameter.AllowedClasses {
-		allowedClasses = append(allowedClasses, fmt.Sprintf(":class_%d :hasAllowedParameter :param_%s", class, parameter.ID))
+func (s *Service) buildUpdateParameterQuery(parameter models.ParameterView) (string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return "", err
 	}
-	classes := strings.Join(allowedClasses, " .\n")
-	if len(classes) > 0 {
-		classes += " .\n"
+	triples, err := parameterTriples(parameter)
+	if err != nil {
+		return "", err
 	}
 
-	contradictions := make([]string, 0, len(parameter.ContradictionParameters))
-	for _, contradiction := range parameter.ContradictionParameters {
-		contradictions = append(contradictions, fmt.Sprintf("\t:hasContradictionParameter :param_%s", contradiction))
-	}
-	contradictionsStr := strings.Join(contradictions, " ;\n")
-	if len(contradictionsStr) > 0 {
-		contradictionsStr += " .\n"
+	update := sparql.Update{
+		Prefixes:   prefixes,
+		Operations: []sparql.Operation{sparql.InsertData(triples...)},
 	}
+	return update.String(), nil
+}
 
-	var update strings.Builder
-	if !forUpdate {
-		update.WriteString("PREFIX : <")
-		update.WriteString(s.prefix)
-		update.WriteString(">\n")
-	}
-	if !forUpdate {
-		update.WriteString("INSERT DATA {\n")
-	} else {
-		update.WriteString("INSERT {\n")
+func parameterTriples(parameter models.ParameterView) ([]sparql.Triple, error) {
+	subject, err := parameterTerm(parameter.ID)
+	if err != nil {
+		return nil, err
 	}
-	update.WriteString("\t:param_")
-	update.WriteString(parameter.ID)
-	update.WriteString(" a :Parameter")
-	if len(parameter.ContradictionParameters) == 0 {
-		update.WriteString(" .\n")
-	} else {
-		update.WriteString(" ;\n")
+	contradictions, err := parameterTerms(parameter.ContradictionParameters)
+	if err != nil {
+		return nil, err
 	}
-	if len(contradictionsStr) > 0 {
-		update.WriteString(contradictionsStr)
+
+	triples := []sparql.Triple{sparql.T(subject, sparql.A, parameterType)}
+	for _, contradiction := range contradictions {
+		triples = append(triples, sparql.T(subject, hasContradictionParameter, contradiction))
 	}
-	if len(classes) > 0 {
-		update.WriteString(classes)
+	for _, class := range parameter.AllowedClasses {
+		triples = append(triples, sparql.T(classTerm(class), hasAllowedParameter, subject))
 	}
-	update.WriteString("}")
 
-	return update.String()
+	return triples, nil
 }
 
 func (s *Service) UpdateParameter(ctx context.Context, parameter models.ParameterView) error {
-	update := s.buildUpdateParameterQuery(parameter, true)
-
-	constraints, contr, err := s.GetParameterConstraints(ctx, parameter.ID)
+	update, err := s.buildReplaceParameterQuery(parameter)
 	if err != nil {
 		return err
 	}
-	old := models.ParameterView{
-		ID:                      parameter.ID,
-		Title:                   parameter.Title,
-		AllowedClasses:          constraints,
-		ContradictionParameters: contr,
-	}
-	delete := s.buildDeleteParameterQuery(old)
-
-	update = fmt.Sprintf(`
-		PREFIX : <%s>
-		%s
-		%s
-		WHERE {
-		  :param_%s ?p ?o .
-		}
-	`, s.prefix, delete, update, parameter.ID)
 
 	return s.runSparqlUpdate(ctx, update)
 }
 
-func (s *Service) DeleteParameter(ctx context.Context, parameterID string) error {
-	constraints, contr, err := s.GetParameterConstraints(ctx, parameterID)
+func (s *Service) buildReplaceParameterQuery(parameter models.ParameterView) (string, error) {
+	prefixes, err := s.prefixes()
 	if err != nil {
-		return err
+		return "", err
+	}
+	subject, err := parameterTerm(parameter.ID)
+	if err != nil {
+		return "", err
+	}
+	triples, err := parameterTriples(parameter)
+	if err != nil {
+		return "", err
 	}
 
-	view := models.ParameterView{
-		ID:                      parameterID,
-		AllowedClasses:          constraints,
-		ContradictionParameters: contr,
+	update := sparql.Update{
+		Prefixes:   prefixes,
+		Operations: append(deleteParameterOperations(subject), sparql.InsertData(triples...)),
 	}
+	return update.String(), nil
+}
 
-	delete := s.buildDeleteParameterQuery(view)
-	update := fmt.Sprintf(`
-		PREFIX : <%s>
-		%s
-		WHERE {
-		  :param_%s ?p ?o .
-		}
-	`, s.prefix, delete, parameterID)
+func (s *Service) DeleteParameter(ctx context.Context, parameterID string) error {
+	update, err := s.buildDeleteParameterQuery(parameterID)
+	if err != nil {
+		return err
+	}
 
 	return s.runSparqlUpdate(ctx, update)
 }
 
-func (s *Service) buildDeleteParameterQuery(parameter models.ParameterView) string {
-	allowedClasses := make([]string, 0, len(parameter.AllowedClasses))
-	for _, class := range parameter.AllowedClasses {
-		allowedClasses = append(allowedClasses, fmt.Sprintf(":class_%d :hasAllowedParameter :param_%s", class, parameter.ID))
+func (s *Service) buildDeleteParameterQuery(parameterID string) (string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return "", err
 	}
-	classes := strings.Join(allowedClasses, " .\n")
-	if len(classes) > 0 {
-		classes += " .\n"
+	subject, err := parameterTerm(parameterID)
+	if err != nil {
+		return "", err
 	}
 
-	contradictions := make([]string, 0, len(parameter.ContradictionParameters))
-	for _, contradiction := range parameter.ContradictionParameters {
-		contradictions = append(contradictions, fmt.Sprintf("\t:hasContradictionParameter :param_%s", contradiction))
-	}
-	contradictionsStr := strings.Join(contradictions, " ;\n")
-	if len(contradictionsStr) > 0 {
-		contradictionsStr += " .\n"
+	// Required and forbidden links are owned by the classes and survive
+	// parameter updates, but not the deletion.
+	operations := append(deleteParameterOperations(subject),
+		sparql.DeleteWhere(sparql.T(sparql.Var("class"), hasRequiredParameter, subject)),
+		sparql.DeleteWhere(sparql.T(sparql.Var("class"), hasForbiddenParameter, subject)),
+	)
+	update := sparql.Update{
+		Prefixes:   prefixes,
+		Operations: operations,
 	}
+	return update.String(), nil
+}
 
-	var del strings.Builder
-
-	del.WriteString("DELETE {\n")
-	del.WriteString("\t:param_")
-	del.WriteString(parameter.ID)
-	del.WriteString(" a :Parameter")
-	if len(parameter.ContradictionParameters) == 0 {
-		del.WriteString(" .\n")
-	} else {
-		del.WriteString(" ;\n")
-	}
-	if len(contradictionsStr) > 0 {
-		del.WriteString(contradictionsStr)
+// deleteParameterOperations removes the parameter's own triples and the
+// links that allow it in classes.
+func deleteParameterOperations(subject sparql.PrefixedName) []sparql.Operation {
+	return []sparql.Operation{
+		sparql.DeleteWhere(sparql.T(subject, sparql.Var("p"), sparql.Var("o"))),
+		sparql.DeleteWhere(sparql.T(sparql.Var("class"), hasAllowedParameter, subject)),
 	}
-	if len(classes) > 0 {
-		del.WriteString(classes)
-	}
-	del.WriteString("}")
-
-	return del.String()
 }
 
 func (s *Service) GetParameterConstraints(ctx context.Context, parameterID string) ([]uint, []string, error) {
-	query := fmt.Sprintf(`
-		PREFIX : <%s>
-		SELECT ?contradictionParam
-		WHERE {
-		    :param_%s :hasContradictionParameter ?contradictionParam .
-		}
-	`, s.prefix, parameterID)
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return nil, nil, err
+	}
+	subject, err := parameterTerm(parameterID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := sparql.Select{
+		Prefixes:   prefixes,
+		Projection: []sparql.Projection{sparql.Var("contradictionParam")},
+		Where: []sparql.Pattern{
+			sparql.T(subject, hasContradictionParameter, sparql.Var("contradictionParam")),
+		},
+	}
 
-	contrParams, err := s.query(ctx, query)
+	contrParams, err := s.query(ctx, query.String())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -201,17 +196,15 @@ func (s *Service) GetParameterConstraints(ctx context.Context, parameterID strin
 		}
 	}
 
-	query = fmt.Sprintf(`
-		PREFIX : <%s>
-		SELECT ?class
-		WHERE {
-			{ 
-				?class :hasAllowedParameter :param_%s .
-			}
-		}
-	`, s.prefix, parameterID)
+	query = sparql.Select{
+		Prefixes:   prefixes,
+		Projection: []sparql.Projection{sparql.Var("class")},
+		Where: []sparql.Pattern{
+			sparql.T(sparql.Var("class"), hasAllowedParameter, subject),
+		},
+	}
 
-	allowedClasses, err := s.query(ctx, query)
+	allowedClasses, err := s.query(ctx, query.String())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -232,237 +225,285 @@ func (s *Service) GetParameterConstraints(ctx context.Context, parameterID strin
 }
 
 func (s *Service) AddClass(ctx context.Context, class models.ClassView) error {
-	update := s.buildUpdateClassQuery(class, false)
+	update, err := s.buildUpdateClassQuery(class)
+	if err != nil {
+		return err
+	}
 
 	return s.runSparqlUpdate(ctx, update)
 }
 
-func (s *Service) buildUpdateClassQuery(class models.ClassView, forUpdate bool) string {
-	allowedParameters := make([]string, 0, len(class.AllowedParameters))
-	for _, parameter := range class.AllowedParameters {
-		allowedParameters = append(allowedParameters, fmt.Sprintf("\t:hasAllowedParameter :param_%s", parameter))
+func (s *Service) buildUpdateClassQuery(class models.ClassView) (string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return "", err
 	}
-	parameters := strings.Join(allowedParameters, " ;\n")
-	if len(parameters) > 0 {
-		parameters += " .\n"
+	triples, err := classTriples(class)
+	if err != nil {
+		return "", err
 	}
 
-	var update strings.Builder
-	if !forUpdate {
-		update.WriteString("PREFIX : <")
-		update.WriteString(s.prefix)
-		update.WriteString(">\n")
-	}
-	if !forUpdate {
-		update.WriteString("INSERT DATA {\n")
-	} else {
-		update.WriteString("INSERT {\n")
-	}
-	update.WriteString("\t:class_")
-	update.WriteString(strconv.FormatUint(uint64(class.ID), 10))
-	update.WriteString(" a :Class")
-	if len(class.AllowedParameters) > 0 {
-		update.WriteString(" ;\n")
-	} else {
-		update.WriteString(" .\n")
+	update := sparql.Update{
+		Prefixes:   prefixes,
+		Operations: []sparql.Operation{sparql.InsertData(triples...)},
 	}
-	if len(parameters) > 0 {
-		update.WriteString(parameters)
+	return update.String(), nil
+}
+
+func classTriples(class models.ClassView) ([]sparql.Triple, error) {
+	subject := classTerm(class.ID)
+	triples := []sparql.Triple{sparql.T(subject, sparql.A, classType)}
+	if class.ParentID != nil {
+		triples = append(triples, sparql.T(subject, subClassOf, classTerm(*class.ParentID)))
+	}
+	rules := []struct {
+		predicate sparql.PrefixedName
+		ids       []string
+	}{
+		{hasAllowedParameter, class.AllowedParameters},
+		{hasRequiredParameter, class.RequiredParameters},
+		{hasForbiddenParameter, class.ForbiddenParameters},
+	}
+	for _, rule := range rules {
+		parameters, err := parameterTerms(rule.ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, parameter := range parameters {
+			triples = append(triples, sparql.T(subject, rule.predicate, parameter))
+		}
 	}
-	update.WriteString("}")
 
-	return update.String()
+	return triples, nil
 }
 
 func (s *Service) UpdateClass(ctx context.Context, class models.ClassView) error {
-	update := s.buildUpdateClassQuery(class, true)
-
-	constraints, err := s.GetClassConstraints(ctx, class.ID)
+	update, err := s.buildReplaceClassQuery(class)
 	if err != nil {
 		return err
 	}
 
-	old := models.ClassView{
-		ID:                class.ID,
-		Title:             class.Title,
-		AllowedParameters: constraints,
-	}
-	delete := s.buildDeleteClassQuery(old)
-
-	update = fmt.Sprintf(`
-		PREFIX : <%s>
-		%s
-		%s
-		WHERE {
-		  :class_%d ?p ?o .
-		}
-	`, s.prefix, delete, update, class.ID)
-
 	return s.runSparqlUpdate(ctx, update)
 }
 
-func (s *Service) buildDeleteClassQuery(class models.ClassView) string {
-	allowedParameters := make([]string, 0, len(class.AllowedParameters))
-	for _, parameter := range class.AllowedParameters {
-		allowedParameters = append(allowedParameters, fmt.Sprintf("\t:hasAllowedParameter :param_%s", parameter))
+func (s *Service) buildReplaceClassQuery(class models.ClassView) (string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return "", err
 	}
-	parameters := strings.Join(allowedParameters, " ;\n")
-	if len(parameters) > 0 {
-		parameters += " .\n"
+	triples, err := classTriples(class)
+	if err != nil {
+		return "", err
 	}
 
-	var del strings.Builder
-
-	del.WriteString("DELETE {\n")
-	del.WriteString("\t:class_")
-	del.WriteString(strconv.FormatUint(uint64(class.ID), 10))
-	del.WriteString(" a :Class")
-	if len(class.AllowedParameters) > 0 {
-		del.WriteString(" ;\n")
-	} else {
-		del.WriteString(" .\n")
-	}
-	if len(parameters) > 0 {
-		del.WriteString(parameters)
+	update := sparql.Update{
+		Prefixes: prefixes,
+		Operations: []sparql.Operation{
+			deleteClassOperation(class.ID),
+			sparql.InsertData(triples...),
+		},
 	}
-	del.WriteString("}")
-
-	return del.String()
+	return update.String(), nil
 }
 
 func (s *Service) DeleteClass(ctx context.Context, id uint) error {
-	constraints, err := s.GetClassConstraints(ctx, id)
+	update, err := s.buildDeleteClassQuery(id)
 	if err != nil {
 		return err
 	}
 
-	old := models.ClassView{
-		ID:                id,
-		AllowedParameters: constraints,
+	return s.runSparqlUpdate(ctx, update)
+}
+
+func (s *Service) buildDeleteClassQuery(id uint) (string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return "", err
 	}
-	query := s.buildDeleteClassQuery(old)
 
-	update := fmt.Sprintf(`
-		PREFIX : <%s>
-		%s
-		WHERE {
-		  :class_%d ?p ?o .
-		}
-	`, s.prefix, query, id)
+	update := sparql.Update{
+		Prefixes:   prefixes,
+		Operations: []sparql.Operation{deleteClassOperation(id)},
+	}
+	return update.String(), nil
+}
 
-	return s.runSparqlUpdate(ctx, update)
+// deleteClassOperation removes the class's own triples, including the link
+// to its parent. Services and subclasses keep pointing at the class, the
+// handlers refuse to delete classes in use or with subclasses.
+func deleteClassOperation(id uint) sparql.Operation {
+	return sparql.DeleteWhere(sparql.T(classTerm(id), sparql.Var("p"), sparql.Var("o")))
 }
 
-func (s *Service) GetClassConstraints(ctx context.Context, classID uint) ([]string, error) {
-	query := fmt.Sprintf(`
-		PREFIX : <%s>
-		SELECT ?allowedParam
-		WHERE {
-			{
-				:class_%d :hasAllowedParameter ?allowedParam .
-			}
-		}
-	`, s.prefix, classID)
+// GetClassConstraints returns the class rules including the ones inherited
+// from its ancestors.
+func (s *Service) GetClassConstraints(ctx context.Context, classID uint) (knowledge_base.ClassConstraints, error) {
+	constraints, err := s.classConstraints(ctx, []uint{classID}, true, hasAllowedParameter, hasRequiredParameter, hasForbiddenParameter)
+	if err != nil {
+		return knowledge_base.ClassConstraints{}, err
+	}
+	return constraints[classID], nil
+}
+
+// GetClassRules returns only the rules linked to the class itself.
+func (s *Service) GetClassRules(ctx context.Context, classID uint) (knowledge_base.ClassConstraints, error) {
+	constraints, err := s.classConstraints(ctx, []uint{classID}, false, hasAllowedParameter, hasRequiredParameter, hasForbiddenParameter)
+	if err != nil {
+		return knowledge_base.ClassConstraints{}, err
+	}
+	return constraints[classID], nil
+}
+
+// classConstraints collects the given rules of the classes and, when
+// inherited is set, of their ancestors.
+func (s *Service) classConstraints(ctx context.Context, classIDs []uint, inherited bool, rules ...sparql.PrefixedName) (map[uint]knowledge_base.ClassConstraints, error) {
+	constraints := make(map[uint]knowledge_base.ClassConstraints, len(classIDs))
+	if len(classIDs) == 0 {
+		return constraints, nil
+	}
 
+	query, err := s.buildClassConstraintsQuery(classIDs, inherited, rules)
+	if err != nil {
+		return nil, err
+	}
 	result, err := s.query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	var allowedParams []string
 	for _, binding := range result.Results.Bindings {
-		if allowedParam, ok := binding["allowedParam"]; ok {
-			allowedParamValue := strings.TrimPrefix(allowedParam["value"], s.prefix+"param_")
-			allowedParams = append(allowedParams, allowedParamValue)
+		classID, err := s.parseID(binding["class"]["value"], "class_")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse class ID: %w", err)
 		}
+		param := strings.TrimPrefix(binding["param"]["value"], s.prefix+"param_")
+
+		class := constraints[classID]
+		switch strings.TrimPrefix(binding["rule"]["value"], s.prefix) {
+		case "hasAllowedParameter":
+			class.Allowed = append(class.Allowed, param)
+		case "hasRequiredParameter":
+			class.Required = append(class.Required, param)
+		case "hasForbiddenParameter":
+			class.Forbidden = append(class.Forbidden, param)
+		}
+		constraints[classID] = class
+	}
+
+	return constraints, nil
+}
+
+func (s *Service) buildClassConstraintsQuery(classIDs []uint, inherited bool, rules []sparql.PrefixedName) (string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return "", err
+	}
+
+	classes := make([]sparql.Term, 0, len(classIDs))
+	for _, id := range classIDs {
+		classes = append(classes, classTerm(id))
+	}
+	predicates := make([]sparql.Term, 0, len(rules))
+	for _, rule := range rules {
+		predicates = append(predicates, rule)
 	}
 
-	return allowedParams, nil
+	class, ancestor, rule, param := sparql.Var("class"), sparql.Var("ancestor"), sparql.Var("rule"), sparql.Var("param")
+	where := []sparql.Pattern{
+		sparql.Values{Var: class, Terms: classes},
+		sparql.Values{Var: rule, Terms: predicates},
+	}
+	if inherited {
+		where = append(where,
+			sparql.T(class, sparql.ZeroOrMore(subClassOf), ancestor),
+			sparql.T(ancestor, rule, param),
+		)
+	} else {
+		where = append(where, sparql.T(class, rule, param))
+	}
+	query := sparql.Select{
+		Prefixes:   prefixes,
+		Distinct:   true,
+		Projection: []sparql.Projection{class, rule, param},
+		Where:      where,
+		OrderBy:    []sparql.Order{sparql.Asc(class), sparql.Asc(rule), sparql.Asc(param)},
+	}
+	return query.String(), nil
 }
 
 func (s *Service) AddService(ctx context.Context, service *models.Service) error {
-	update := s.buildUpdateServiceQuery(service, false)
+	update, err := s.buildUpdateServiceQuery(service)
+	if err != nil {
+		return err
+	}
 
 	return s.runSparqlUpdate(ctx, update)
 }
 
-func (s *Service) buildUpdateServiceQuery(service *models.Service, forUpdate bool) string {
-	parameters := make([]string, 0, len(service.Parameters))
-	for _, parameter := range service.Parameters {
-		parameters = append(parameters, fmt.Sprintf(":param_%s", parameter.ID))
+func (s *Service) buildUpdateServiceQuery(service *models.Service) (string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return "", err
 	}
-	parametersStr := strings.Join(parameters, " , ")
-
-	var update strings.Builder
-	update.WriteString("PREFIX : <")
-	update.WriteString(s.prefix)
-	update.WriteString(">\n")
-	if !forUpdate {
-		update.WriteString("INSERT DATA {\n")
-	} else {
-		update.WriteString("INSERT {\n")
+	parameters, err := parameterTerms(knowledge_base.ServiceParameterIDs(service))
+	if err != nil {
+		return "", err
 	}
-	update.WriteString("\t:service_")
-	update.WriteString(strconv.FormatUint(uint64(service.ID), 10))
-	update.WriteString(" a :Service")
+
+	subject := serviceTerm(service.ID)
+	triples := []sparql.Triple{sparql.T(subject, sparql.A, serviceType)}
 	if service.ClassID != nil {
-		update.WriteString(" ;\n")
-		update.WriteString("\t\t:hasClass :class_")
-		update.WriteString(strconv.FormatUint(uint64(*service.ClassID), 10))
-	}
-	if len(parameters) > 0 {
-		update.WriteString(" ;\n")
-		update.WriteString("\t\t:hasParameter ")
-		update.WriteString(parametersStr)
-	}
-	update.WriteString(" .\n")
-	update.WriteString("}")
-
-	return update.String()
-}
-
-type ProposedClass struct {
-	ClassID               uint
-	MatchingParameterNums int
-	SimilarServices       []uint
-}
-
-func (s *Service) ProposedClasses(ctx context.Context, service *models.Service) ([]ProposedClass, error) {
-	var serviceParams []string
-	for _, param := range service.Parameters {
-		serviceParams = append(serviceParams, fmt.Sprintf(":param_%s", param.ID))
-	}
-	serviceParam := strings.Join(serviceParams, " ")
-	allowedParam := strings.Join(serviceParams, ", ")
-
-	query := fmt.Sprintf(`
-		PREFIX : <%s>
-		SELECT ?class (COUNT(DISTINCT ?commonParam) AS ?matching_parameter_numbers) (GROUP_CONCAT(DISTINCT ?similarService; SEPARATOR=",") AS ?similar_services)
-		WHERE {
-		  VALUES ?serviceParam { %s } # service parameters
-		  ?class a :Class ;
-		            :hasAllowedParameter ?allowedParam .
-		  FILTER(?allowedParam IN (%s))
-		  BIND(?allowedParam AS ?commonParam)
-		  OPTIONAL {
-		    ?similarService a :Service ;
-		                    :hasParameter ?allowedParam ;
-		                    :hasClass ?class .
-		    FILTER(?similarService != :service_%d)
-		  }
-		}
-		GROUP BY ?class
-		ORDER BY DESC(?matching_parameter_numbers)
-	`, s.prefix, serviceParam, allowedParam, service.ID)
+		triples = append(triples, sparql.T(subject, hasClass, classTerm(*service.ClassID)))
+	}
+	for _, parameter := range parameters {
+		triples = append(triples, sparql.T(subject, hasParameter, parameter))
+	}
+
+	update := sparql.Update{
+		Prefixes:   prefixes,
+		Operations: []sparql.Operation{sparql.InsertData(triples...)},
+	}
+	return update.String(), nil
+}
+
+func (s *Service) DeleteService(ctx context.Context, id uint) error {
+	update, err := s.buildDeleteServiceQuery(id)
+	if err != nil {
+		return err
+	}
+
+	return s.runSparqlUpdate(ctx, update)
+}
+
+func (s *Service) buildDeleteServiceQuery(id uint) (string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return "", err
+	}
+
+	update := sparql.Update{
+		Prefixes: prefixes,
+		Operations: []sparql.Operation{
+			sparql.DeleteWhere(sparql.T(serviceTerm(id), sparql.Var("p"), sparql.Var("o"))),
+		},
+	}
+	return update.String(), nil
+}
+
+func (s *Service) ProposedClasses(ctx context.Context, service *models.Service) ([]knowledge_base.ProposedClass, error) {
+	query, err := s.buildProposedClassesQuery(service)
+	if err != nil {
+		return nil, err
+	}
 
 	result, err := s.query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	classes := make([]ProposedClass, 0, len(result.Results.Bindings))
+	classes := make([]knowledge_base.ProposedClass, 0, len(result.Results.Bindings))
 	for _, binding := range result.Results.Bindings {
-		var class ProposedClass
+		var class knowledge_base.ProposedClass
 		classIDStr := strings.TrimPrefix(binding["class"]["value"], s.prefix+"class_")
 		classID, err := strconv.ParseUint(classIDStr, 10, 64)
 		if err != nil {
@@ -492,63 +533,280 @@ func (s *Service) ProposedClasses(ctx context.Context, service *models.Service)
 		classes = append(classes, class)
 	}
 
-	return classes, nil
+	classIDs := make([]uint, 0, len(classes))
+	for _, class := range classes {
+		classIDs = append(classIDs, class.ClassID)
+	}
+	constraints, err := s.classConstraints(ctx, classIDs, true, hasRequiredParameter, hasForbiddenParameter)
+	if err != nil {
+		return nil, err
+	}
+
+	return knowledge_base.ApplyClassRules(classes, constraints, knowledge_base.ServiceParameterIDs(service)), nil
 }
 
-func (s *Service) ValidateClass(ctx context.Context, service *models.Service, chosenClass uint) (bool, error) {
-	serviceParams := make([]string, 0, len(service.Parameters))
-	for _, param := range service.Parameters {
-		serviceParams = append(serviceParams, fmt.Sprintf(":param_%s", param.ID))
+func (s *Service) buildProposedClassesQuery(service *models.Service) (string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return "", err
+	}
+	parameters, err := parameterTerms(knowledge_base.ServiceParameterIDs(service))
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		class          = sparql.Var("class")
+		ancestor       = sparql.Var("ancestor")
+		rule           = sparql.Var("rule")
+		allowedParam   = sparql.Var("allowedParam")
+		similarService = sparql.Var("similarService")
+		matching       = sparql.Var("matching_parameter_numbers")
+	)
+	query := sparql.Select{
+		Prefixes: prefixes,
+		Projection: []sparql.Projection{
+			class,
+			sparql.As(sparql.Count(allowedParam, true), matching),
+			sparql.As(sparql.GroupConcat(similarService, ",", true), sparql.Var("similar_services")),
+		},
+		Where: []sparql.Pattern{
+			sparql.Values{Var: allowedParam, Terms: parameters},
+			sparql.Values{Var: rule, Terms: []sparql.Term{hasAllowedParameter, hasRequiredParameter}},
+			sparql.T(class, sparql.A, classType),
+			sparql.T(class, sparql.ZeroOrMore(subClassOf), ancestor),
+			sparql.T(ancestor, rule, allowedParam),
+			sparql.Optional(
+				sparql.T(similarService, sparql.A, serviceType),
+				sparql.T(similarService, hasParameter, allowedParam),
+				sparql.T(similarService, hasClass, class),
+				sparql.Filter(sparql.NotEqual(similarService, serviceTerm(service.ID))),
+			),
+		},
+		GroupBy: []sparql.Var{class},
+		OrderBy: []sparql.Order{sparql.Desc(matching)},
 	}
-	serviceParam := strings.Join(serviceParams, " ")
+	return query.String(), nil
+}
 
-	query := fmt.Sprintf(`
-		PREFIX : <%s>
-		SELECT (IF((COUNT(?allowedParam) = %d), true, false) AS ?allAllowed)
-		WHERE {
-		  VALUES ?requiredParam { %s } # service parameters
-		  :class_%d :hasAllowedParameter ?allowedParam .
-		  FILTER(?allowedParam = ?requiredParam)
-		}
-	`, s.prefix, len(service.Parameters), serviceParam, chosenClass)
+func (s *Service) ValidateClass(ctx context.Context, service *models.Service, chosenClass uint) ([]knowledge_base.Violation, error) {
+	parameters := knowledge_base.ServiceParameterIDs(service)
+	if err := knowledge_base.ValidateParameterIDs(parameters); err != nil {
+		return nil, err
+	}
 
-	result, err := s.query(ctx, query)
+	constraints, err := s.GetClassConstraints(ctx, chosenClass)
+	if err != nil {
+		return nil, err
+	}
+
+	return constraints.Violations(chosenClass, parameters), nil
+}
+
+func (s *Service) ValidateService(ctx context.Context, service *models.Service) ([]knowledge_base.Violation, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return nil, err
+	}
+	parameters, err := parameterTerms(knowledge_base.ServiceParameterIDs(service))
+	if err != nil {
+		return nil, err
+	}
+
+	p1, p2 := sparql.Var("p1"), sparql.Var("p2")
+	query := sparql.Select{
+		Prefixes:   prefixes,
+		Projection: []sparql.Projection{p1, p2},
+		Where: []sparql.Pattern{
+			sparql.Values{Var: p1, Terms: parameters},
+			sparql.Values{Var: p2, Terms: parameters},
+			sparql.Filter(sparql.NotEqual(p1, p2)),
+			sparql.T(p1, hasContradictionParameter, p2),
+		},
+	}
+
+	result, err := s.query(ctx, query.String())
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	declared := make(map[[2]string]struct{}, len(result.Results.Bindings))
+	for _, binding := range result.Results.Bindings {
+		declared[[2]string{
+			strings.TrimPrefix(binding["p1"]["value"], s.prefix+"param_"),
+			strings.TrimPrefix(binding["p2"]["value"], s.prefix+"param_"),
+		}] = struct{}{}
 	}
 
-	return result.Results.Bindings[0]["allAllowed"]["value"] == "true", nil
+	return knowledge_base.Contradictions(knowledge_base.ServiceParameterIDs(service), func(p1, p2 string) bool {
+		_, ok := declared[[2]string{p1, p2}]
+		return ok
+	}), nil
 }
 
-func (s *Service) ValidateService(ctx context.Context, service *models.Service) ([]string, error) {
-	serviceParams := make([]string, 0, len(service.Parameters))
-	for _, param := range service.Parameters {
-		serviceParams = append(serviceParams, fmt.Sprintf(":param_%s", param.ID))
+func (s *Service) ListClasses(ctx context.Context) ([]models.ClassView, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	class, parent, rule, param := sparql.Var("class"), sparql.Var("parent"), sparql.Var("rule"), sparql.Var("param")
+	query := sparql.Select{
+		Prefixes:   prefixes,
+		Projection: []sparql.Projection{class, parent, rule, param},
+		Where: []sparql.Pattern{
+			sparql.T(class, sparql.A, classType),
+			sparql.Optional(sparql.T(class, subClassOf, parent)),
+			sparql.Optional(
+				sparql.Values{Var: rule, Terms: []sparql.Term{hasAllowedParameter, hasRequiredParameter, hasForbiddenParameter}},
+				sparql.T(class, rule, param),
+			),
+		},
+		OrderBy: []sparql.Order{sparql.Asc(class)},
+	}
+
+	result, err := s.query(ctx, query.String())
+	if err != nil {
+		return nil, err
 	}
-	serviceParam := strings.Join(serviceParams, " ")
-	query := fmt.Sprintf(`
-		PREFIX : <%s>
-		SELECT ?p1 ?p2
-		WHERE {
-		  VALUES ?p1 { %s }
-		  VALUES ?p2 { %s }
-		  FILTER(?p1 != ?p2)
-		  ?p1 :hasContradictionParameter ?p2 .
+
+	var classes []models.ClassView
+	index := make(map[uint]int)
+	for _, binding := range result.Results.Bindings {
+		classID, err := s.parseID(binding["class"]["value"], "class_")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse class ID: %w", err)
+		}
+		i, ok := index[classID]
+		if !ok {
+			i = len(classes)
+			index[classID] = i
+			classes = append(classes, models.ClassView{ID: classID})
+		}
+		if parent, ok := binding["parent"]; ok && classes[i].ParentID == nil {
+			parentID, err := s.parseID(parent["value"], "class_")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse parent class ID: %w", err)
+			}
+			classes[i].ParentID = &parentID
+		}
+		param, ok := binding["param"]
+		if !ok {
+			continue
+		}
+		id := strings.TrimPrefix(param["value"], s.prefix+"param_")
+		switch strings.TrimPrefix(binding["rule"]["value"], s.prefix) {
+		case "hasAllowedParameter":
+			classes[i].AllowedParameters = append(classes[i].AllowedParameters, id)
+		case "hasRequiredParameter":
+			classes[i].RequiredParameters = append(classes[i].RequiredParameters, id)
+		case "hasForbiddenParameter":
+			classes[i].ForbiddenParameters = append(classes[i].ForbiddenParameters, id)
 		}
-	`, s.prefix, serviceParam, serviceParam)
+	}
 
-	result, err := s.query(ctx, query)
+	return classes, nil
+}
+
+func (s *Service) ListParameters(ctx context.Context) ([]string, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	param := sparql.Var("param")
+	query := sparql.Select{
+		Prefixes:   prefixes,
+		Projection: []sparql.Projection{param},
+		Where: []sparql.Pattern{
+			sparql.T(param, sparql.A, parameterType),
+		},
+		OrderBy: []sparql.Order{sparql.Asc(param)},
+	}
+
+	result, err := s.query(ctx, query.String())
 	if err != nil {
 		return nil, err
 	}
 
-	contradictions := make([]string, 0, len(result.Results.Bindings))
+	parameters := make([]string, 0, len(result.Results.Bindings))
 	for _, binding := range result.Results.Bindings {
-		value := strings.TrimPrefix(binding["p1"]["value"], s.prefix+"param_")
-		contradictions = append(contradictions, value)
+		parameters = append(parameters, strings.TrimPrefix(binding["param"]["value"], s.prefix+"param_"))
 	}
 
-	return contradictions, nil
+	return parameters, nil
+}
+
+func (s *Service) ListServices(ctx context.Context) ([]models.Service, error) {
+	prefixes, err := s.prefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	service, class, param := sparql.Var("service"), sparql.Var("class"), sparql.Var("param")
+	query := sparql.Select{
+		Prefixes:   prefixes,
+		Projection: []sparql.Projection{service, class, param},
+		Where: []sparql.Pattern{
+			sparql.T(service, sparql.A, serviceType),
+			sparql.Optional(sparql.T(service, hasClass, class)),
+			sparql.Optional(sparql.T(service, hasParameter, param)),
+		},
+		OrderBy: []sparql.Order{sparql.Asc(service)},
+	}
+
+	result, err := s.query(ctx, query.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var services []models.Service
+	index := make(map[uint]int)
+	for _, binding := range result.Results.Bindings {
+		serviceID, err := s.parseID(binding["service"]["value"], "service_")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse service ID: %w", err)
+		}
+		i, ok := index[serviceID]
+		if !ok {
+			i = len(services)
+			index[serviceID] = i
+			services = append(services, models.Service{ID: serviceID})
+		}
+		if value, ok := binding["class"]; ok && services[i].ClassID == nil {
+			classID, err := s.parseID(value["value"], "class_")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse class ID: %w", err)
+			}
+			services[i].ClassID = &classID
+		}
+		if value, ok := binding["param"]; ok {
+			parameterID := strings.TrimPrefix(value["value"], s.prefix+"param_")
+			if !hasParameterID(services[i].Parameters, parameterID) {
+				services[i].Parameters = append(services[i].Parameters, models.Parameter{ID: parameterID})
+			}
+		}
+	}
+
+	return services, nil
+}
+
+func hasParameterID(parameters []models.Parameter, id string) bool {
+	for _, parameter := range parameters {
+		if parameter.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// parseID extracts the numeric ID from an IRI such as <prefix>class_42.
+func (s *Service) parseID(iri string, localPrefix string) (uint, error) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(iri, s.prefix+localPrefix), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
 }
 
 func (s *Service) runSparqlUpdate(ctx context.Context, update string) error {
@@ -2,9 +2,11 @@ package apache_jena
 
 import (
 	"backend/internal/models"
+	"backend/internal/sparql"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBuildUpdateParameterQuery(t *testing.T) {
@@ -24,6 +26,7 @@ func TestBuildUpdateParameterQuery(t *testing.T) {
 				ContradictionParameters: []string{},
 			},
 			wantUpdate: `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
 INSERT DATA {
 	:param_param1 a :Parameter .
 }`,
@@ -37,10 +40,11 @@ INSERT DATA {
 				ContradictionParameters: []string{},
 			},
 			wantUpdate: `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
 INSERT DATA {
-	:param_param2 a :Parameter ;
-	:allowedClass :class_1 ;
-	:allowedClass :class_33 .
+	:param_param2 a :Parameter .
+	:class_1 :hasAllowedParameter :param_param2 .
+	:class_33 :hasAllowedParameter :param_param2 .
 }`,
 		},
 		{
@@ -52,10 +56,11 @@ INSERT DATA {
 				ContradictionParameters: []string{"paramA", "paramB"},
 			},
 			wantUpdate: `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
 INSERT DATA {
-	:param_param3 a :Parameter ;
-	:hasContradictionParameter :param_paramA ;
-	:hasContradictionParameter :param_paramB .
+	:param_param3 a :Parameter .
+	:param_param3 :hasContradictionParameter :param_paramA .
+	:param_param3 :hasContradictionParameter :param_paramB .
 }`,
 		},
 		{
@@ -67,24 +72,77 @@ INSERT DATA {
 				ContradictionParameters: []string{"paramA"},
 			},
 			wantUpdate: `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
 INSERT DATA {
-	:param_param4 a :Parameter ;
-	:allowedClass :class_1 ;
-	:hasContradictionParameter :param_paramA .
+	:param_param4 a :Parameter .
+	:param_param4 :hasContradictionParameter :param_paramA .
+	:class_1 :hasAllowedParameter :param_param4 .
 }`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUpdate := service.buildUpdateParameterQuery(tt.parameter, false)
+			gotUpdate, err := service.buildUpdateParameterQuery(tt.parameter)
+			require.NoError(t, err)
 			assert.Equal(t, tt.wantUpdate, gotUpdate)
 		})
 	}
 }
 
+func TestBuildUpdateParameterQueryRejectsUnsafeIDs(t *testing.T) {
+	service := NewService("http://example.com/", "http://example.com/sparql", "test", "test")
+
+	tests := []struct {
+		name      string
+		parameter models.ParameterView
+	}{
+		{
+			name:      "Space in ID",
+			parameter: models.ParameterView{ID: "mob inet"},
+		},
+		{
+			name:      "Closing brace in ID",
+			parameter: models.ParameterView{ID: "x } ; DROP ALL ; INSERT DATA { :a :b :c"},
+		},
+		{
+			name:      "Comment in contradiction",
+			parameter: models.ParameterView{ID: "sms", ContradictionParameters: []string{"mms#"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.buildUpdateParameterQuery(tt.parameter)
+			assert.ErrorIs(t, err, sparql.ErrInvalidLocalName)
+		})
+	}
+}
+
+func TestBuildDeleteParameterQuery(t *testing.T) {
+	service := NewService("http://example.com/", "http://example.com/sparql", "test", "test")
+
+	gotUpdate, err := service.buildDeleteParameterQuery("sms")
+	require.NoError(t, err)
+	assert.Equal(t, `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
+DELETE WHERE {
+	:param_sms ?p ?o .
+} ;
+DELETE WHERE {
+	?class :hasAllowedParameter :param_sms .
+} ;
+DELETE WHERE {
+	?class :hasRequiredParameter :param_sms .
+} ;
+DELETE WHERE {
+	?class :hasForbiddenParameter :param_sms .
+}`, gotUpdate)
+}
+
 func TestBuildUpdateClassQuery(t *testing.T) {
 	service := NewService("http://example.com/", "http://example.com/sparql", "test", "test")
+	parentClassID := uint(3000)
 
 	tests := []struct {
 		name       string
@@ -98,6 +156,7 @@ func TestBuildUpdateClassQuery(t *testing.T) {
 				AllowedParameters: []string{},
 			},
 			wantUpdate: `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
 INSERT DATA {
 	:class_1 a :Class .
 }`,
@@ -109,18 +168,137 @@ INSERT DATA {
 				AllowedParameters: []string{"param1", "param2"},
 			},
 			wantUpdate: `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
+INSERT DATA {
+	:class_2 a :Class .
+	:class_2 :hasAllowedParameter :param_param1 .
+	:class_2 :hasAllowedParameter :param_param2 .
+}`,
+		},
+		{
+			name: "Subclass",
+			class: models.ClassView{
+				ID:                3001,
+				ParentID:          &parentClassID,
+				AllowedParameters: []string{"voice_fix"},
+			},
+			wantUpdate: `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
 INSERT DATA {
-	:class_2 a :Class ;
-	:hasAllowedParameter :param_param1 ;
-	:hasAllowedParameter :param_param2 .
+	:class_3001 a :Class .
+	:class_3001 rdfs:subClassOf :class_3000 .
+	:class_3001 :hasAllowedParameter :param_voice_fix .
+}`,
+		},
+		{
+			name: "Required and forbidden parameters",
+			class: models.ClassView{
+				ID:                  1004,
+				AllowedParameters:   []string{"sms"},
+				RequiredParameters:  []string{"mn_roam"},
+				ForbiddenParameters: []string{"iot"},
+			},
+			wantUpdate: `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
+INSERT DATA {
+	:class_1004 a :Class .
+	:class_1004 :hasAllowedParameter :param_sms .
+	:class_1004 :hasRequiredParameter :param_mn_roam .
+	:class_1004 :hasForbiddenParameter :param_iot .
 }`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUpdate := service.buildUpdateClassQuery(tt.class, false)
+			gotUpdate, err := service.buildUpdateClassQuery(tt.class)
+			require.NoError(t, err)
 			assert.Equal(t, tt.wantUpdate, gotUpdate)
 		})
 	}
 }
+
+func TestBuildReplaceClassQuery(t *testing.T) {
+	service := NewService("http://example.com/", "http://example.com/sparql", "test", "test")
+
+	gotUpdate, err := service.buildReplaceClassQuery(models.ClassView{ID: 9, AllowedParameters: []string{"sms"}})
+	require.NoError(t, err)
+	assert.Equal(t, `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
+DELETE WHERE {
+	:class_9 ?p ?o .
+} ;
+INSERT DATA {
+	:class_9 a :Class .
+	:class_9 :hasAllowedParameter :param_sms .
+}`, gotUpdate)
+}
+
+func TestBuildProposedClassesQuery(t *testing.T) {
+	service := NewService("http://example.com/", "http://example.com/sparql", "test", "test")
+
+	gotQuery, err := service.buildProposedClassesQuery(&models.Service{
+		ID:         5,
+		Parameters: []models.Parameter{{ID: "sms"}, {ID: "roaming"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
+SELECT ?class (COUNT(DISTINCT ?allowedParam) AS ?matching_parameter_numbers) (GROUP_CONCAT(DISTINCT ?similarService; SEPARATOR=",") AS ?similar_services)
+WHERE {
+	VALUES ?allowedParam { :param_sms :param_roaming }
+	VALUES ?rule { :hasAllowedParameter :hasRequiredParameter }
+	?class a :Class .
+	?class rdfs:subClassOf* ?ancestor .
+	?ancestor ?rule ?allowedParam .
+	OPTIONAL {
+		?similarService a :Service .
+		?similarService :hasParameter ?allowedParam .
+		?similarService :hasClass ?class .
+		FILTER(?similarService != :service_5)
+	}
+}
+GROUP BY ?class
+ORDER BY DESC(?matching_parameter_numbers)`, gotQuery)
+}
+
+func TestBuildClassConstraintsQuery(t *testing.T) {
+	service := NewService("http://example.com/", "http://example.com/sparql", "test", "test")
+
+	gotQuery, err := service.buildClassConstraintsQuery([]uint{1004, 9}, true, []sparql.PrefixedName{hasRequiredParameter, hasForbiddenParameter})
+	require.NoError(t, err)
+	assert.Equal(t, `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
+SELECT DISTINCT ?class ?rule ?param
+WHERE {
+	VALUES ?class { :class_1004 :class_9 }
+	VALUES ?rule { :hasRequiredParameter :hasForbiddenParameter }
+	?class rdfs:subClassOf* ?ancestor .
+	?ancestor ?rule ?param .
+}
+ORDER BY ASC(?class) ASC(?rule) ASC(?param)`, gotQuery)
+
+	gotQuery, err = service.buildClassConstraintsQuery([]uint{1004}, false, []sparql.PrefixedName{hasAllowedParameter})
+	require.NoError(t, err)
+	assert.Equal(t, `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
+SELECT DISTINCT ?class ?rule ?param
+WHERE {
+	VALUES ?class { :class_1004 }
+	VALUES ?rule { :hasAllowedParameter }
+	?class ?rule ?param .
+}
+ORDER BY ASC(?class) ASC(?rule) ASC(?param)`, gotQuery)
+}
+
+func TestBuildDeleteServiceQuery(t *testing.T) {
+	service := NewService("http://example.com/", "http://example.com/sparql", "test", "test")
+
+	gotUpdate, err := service.buildDeleteServiceQuery(42)
+	require.NoError(t, err)
+	assert.Equal(t, `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
+DELETE WHERE {
+	:service_42 ?p ?o .
+}`, gotUpdate)
+}
@@ -0,0 +1,47 @@
+package apache_jena
+
+import (
+	"backend/internal/sparql"
+	"strconv"
+)
+
+var (
+	classType     = sparql.MustPrefixed("", "Class")
+	parameterType = sparql.MustPrefixed("", "Parameter")
+	serviceType   = sparql.MustPrefixed("", "Service")
+
+	hasAllowedParameter       = sparql.MustPrefixed("", "hasAllowedParameter")
+	hasRequiredParameter      = sparql.MustPrefixed("", "hasRequiredParameter")
+	hasForbiddenParameter     = sparql.MustPrefixed("", "hasForbiddenParameter")
+	hasContradictionParameter = sparql.MustPrefixed("", "hasContradictionParameter")
+	hasParameter              = sparql.MustPrefixed("", "hasParameter")
+	hasClass                  = sparql.MustPrefixed("", "hasClass")
+
+	subClassOf = sparql.MustPrefixed("rdfs", "subClassOf")
+)
+
+const rdfsNamespace = "http://www.w3.org/2000/01/rdf-schema#"
+
+func classTerm(id uint) sparql.PrefixedName {
+	return sparql.MustPrefixed("", "class_"+strconv.FormatUint(uint64(id), 10))
+}
+
+func serviceTerm(id uint) sparql.PrefixedName {
+	return sparql.MustPrefixed("", "service_"+strconv.FormatUint(uint64(id), 10))
+}
+
+func parameterTerm(id string) (sparql.PrefixedName, error) {
+	return sparql.Prefixed("", "param_"+id)
+}
+
+func parameterTerms(ids []string) ([]sparql.Term, error) {
+	terms := make([]sparql.Term, 0, len(ids))
+	for _, id := range ids {
+		term, err := parameterTerm(id)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
@@ -0,0 +1,231 @@
+// Package archive generates fiscal reports on a schedule and keeps them in
+// the database for download.
+package archive
+
+import (
+	"backend/internal/models"
+	"backend/internal/report"
+	"backend/internal/repositories"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+const (
+	// runTimeout is how long a run may take before it is considered crashed
+	// and taken over.
+	runTimeout = time.Hour
+	// retryInterval is how often failed and crashed runs are retried, up to
+	// maxAttempts attempts in total.
+	retryInterval = 15 * time.Minute
+	maxAttempts   = 5
+)
+
+// Generator writes the report for options in format to w.
+type Generator func(options report.Options, format report.Format, w io.Writer) error
+
+// Schedule generates a report whenever its cron expression fires. The
+// report covers the Range period before the run, see Options.
+type Schedule struct {
+	Spec     string
+	Range    report.Granularity
+	Grouping report.Grouping
+	Format   report.Format
+
+	cron cron.Schedule
+}
+
+// ParseSchedules parses cron expressions separated by semicolons, e.g.
+// "0 6 1 * *; @yearly". All schedules share the range, grouping and format.
+func ParseSchedules(specs string, reportRange report.Granularity, grouping report.Grouping, format report.Format) ([]Schedule, error) {
+	var schedules []Schedule
+	for _, spec := range strings.Split(specs, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		parsed, err := cron.ParseStandard(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid report schedule %q: %w", spec, err)
+		}
+		schedules = append(schedules, Schedule{Spec: spec, Range: reportRange, Grouping: grouping, Format: format, cron: parsed})
+	}
+	return schedules, nil
+}
+
+// Options returns the report options of a run at t. The range is the period
+// containing the day before t, so a run on the first of a month reports the
+// previous month and a run late on the last day of a month the month
+// itself. Months are split by month, years by quarter.
+func (s Schedule) Options(t time.Time, currency string) (report.Options, error) {
+	period := s.Range.Period(t.AddDate(0, 0, -1))
+	granularity := report.Month
+	if s.Range == report.Year {
+		granularity = report.Quarter
+	}
+	return report.NewOptions(period.Start, period.End.AddDate(0, 0, -1), granularity, s.Grouping, currency)
+}
+
+// Scheduler runs the schedules in process and archives the reports.
+type Scheduler struct {
+	repo      repositories.ArchivedReportRepository
+	generate  Generator
+	currency  string
+	schedules []Schedule
+}
+
+func NewScheduler(repo repositories.ArchivedReportRepository, generate Generator, currency string, schedules []Schedule) *Scheduler {
+	return &Scheduler{
+		repo:      repo,
+		generate:  generate,
+		currency:  currency,
+		schedules: schedules,
+	}
+}
+
+// Run generates reports and retries the failed ones until ctx is cancelled.
+// A run still in progress is waited for.
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.schedules) == 0 {
+		return
+	}
+
+	c := cron.New()
+	for _, schedule := range s.schedules {
+		c.Schedule(schedule.cron, cron.FuncJob(func() {
+			if _, err := s.Generate(schedule, time.Now()); err != nil {
+				slog.Error("Error while generating scheduled report", slog.String("schedule", schedule.Spec), slog.Any("error", err))
+			}
+		}))
+		slog.Info("Scheduled report", slog.String("schedule", schedule.Spec), slog.String("range", string(schedule.Range)))
+	}
+	c.Start()
+
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			<-c.Stop().Done()
+			return
+		case <-ticker.C:
+			if err := s.Retry(time.Now()); err != nil {
+				slog.Error("Error while retrying scheduled reports", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Generate archives the report of schedule for a run at t. It returns nil
+// without an error when the report is already generated or being generated,
+// e.g. by another instance. A failed run, or one running for longer than
+// runTimeout, is taken over. A failed generation is archived with the error.
+func (s *Scheduler) Generate(schedule Schedule, t time.Time) (*models.ArchivedReport, error) {
+	options, err := schedule.Options(t, s.currency)
+	if err != nil {
+		return nil, err
+	}
+
+	archived := &models.ArchivedReport{
+		Schedule:    schedule.Spec,
+		PeriodStart: options.From,
+		PeriodEnd:   options.To,
+		Format:      string(schedule.Format),
+		Granularity: string(options.Granularity),
+		Grouping:    string(options.Grouping),
+		Currency:    options.Currency,
+		FileName:    schedule.Format.FileName(options),
+		Status:      models.ReportRunning,
+		Attempts:    1,
+		StartedAt:   &t,
+	}
+	created, err := s.repo.Create(archived)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		taken, err := s.repo.TakeOver(archived, t.Add(-runTimeout), maxAttempts)
+		if err != nil || !taken {
+			return nil, err
+		}
+	}
+
+	return s.run(archived, options, schedule.Format)
+}
+
+// Retry takes over the failed runs with attempts left and the runs that
+// have been running for longer than runTimeout at t, and generates them
+// again. Failed retries are logged.
+func (s *Scheduler) Retry(t time.Time) error {
+	runs, err := s.repo.ListRetryable(t.Add(-runTimeout), maxAttempts)
+	if err != nil {
+		return err
+	}
+
+	for i := range runs {
+		archived := &runs[i]
+		// PeriodEnd is the first day after the range.
+		options, err := report.NewOptions(archived.PeriodStart, archived.PeriodEnd.AddDate(0, 0, -1),
+			report.Granularity(archived.Granularity), report.Grouping(archived.Grouping), archived.Currency)
+		if err != nil {
+			slog.Error("Error while retrying scheduled report", slog.Uint64("id", uint64(archived.ID)), slog.Any("error", err))
+			continue
+		}
+		archived.StartedAt = &t
+		taken, err := s.repo.TakeOver(archived, t.Add(-runTimeout), maxAttempts)
+		if err != nil {
+			slog.Error("Error while retrying scheduled report", slog.Uint64("id", uint64(archived.ID)), slog.Any("error", err))
+			continue
+		}
+		if !taken {
+			continue
+		}
+		if _, err := s.run(archived, options, report.Format(archived.Format)); err != nil {
+			slog.Error("Error while retrying scheduled report", slog.Uint64("id", uint64(archived.ID)), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// run generates the report of a claimed run and stores the result. A run
+// that was taken over meanwhile, e.g. after overrunning runTimeout, leaves
+// the result to the new attempt and returns nil without an error.
+func (s *Scheduler) run(archived *models.ArchivedReport, options report.Options, format report.Format) (*models.ArchivedReport, error) {
+	var buf bytes.Buffer
+	err := s.generate(options, format, &buf)
+	now := time.Now()
+	archived.FinishedAt = &now
+	if err != nil {
+		archived.Status = models.ReportFailed
+		archived.Error = err.Error()
+		archived.Content = nil
+		archived.Size = 0
+	} else {
+		archived.Status = models.ReportDone
+		archived.Error = ""
+		archived.Content = buf.Bytes()
+		archived.Size = buf.Len()
+	}
+	saveErr := s.repo.Finish(archived)
+	if errors.Is(saveErr, gorm.ErrRecordNotFound) {
+		slog.Warn("Scheduled report was taken over, dropping its result", slog.Uint64("id", uint64(archived.ID)), slog.Int("attempt", archived.Attempts))
+		return nil, nil
+	}
+	if saveErr != nil {
+		return nil, saveErr
+	}
+	if err != nil {
+		return archived, err
+	}
+
+	slog.Info("Archived scheduled report", slog.Uint64("id", uint64(archived.ID)), slog.String("file", archived.FileName), slog.Int("attempt", archived.Attempts))
+	return archived, nil
+}
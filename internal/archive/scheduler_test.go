@@ -0,0 +1,285 @@
+package archive
+
+import (
+	"backend/internal/models"
+	"backend/internal/report"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestParseSchedules(t *testing.T) {
+	schedules, err := ParseSchedules(" 0 6 1 * *; ;@yearly ", report.Month, report.ByClass, report.XLSX)
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+	assert.Equal(t, "0 6 1 * *", schedules[0].Spec)
+	assert.Equal(t, "@yearly", schedules[1].Spec)
+
+	schedules, err = ParseSchedules("", report.Month, report.ByClass, report.XLSX)
+	require.NoError(t, err)
+	assert.Empty(t, schedules)
+
+	_, err = ParseSchedules("0 6 32 * *", report.Month, report.ByClass, report.XLSX)
+	assert.Error(t, err)
+}
+
+func TestScheduleOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		reportRange     report.Granularity
+		at              time.Time
+		wantName        string
+		wantGranularity report.Granularity
+	}{
+		{
+			name:            "first of month reports the previous month",
+			reportRange:     report.Month,
+			at:              time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC),
+			wantName:        "2024-02",
+			wantGranularity: report.Month,
+		},
+		{
+			name:            "last day of month reports the month",
+			reportRange:     report.Month,
+			at:              time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC),
+			wantName:        "2024-03",
+			wantGranularity: report.Month,
+		},
+		{
+			name:            "quarter",
+			reportRange:     report.Quarter,
+			at:              time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+			wantName:        "2024-Q2",
+			wantGranularity: report.Month,
+		},
+		{
+			name:            "year",
+			reportRange:     report.Year,
+			at:              time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantName:        "2024",
+			wantGranularity: report.Quarter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := Schedule{Spec: "@daily", Range: tt.reportRange, Grouping: report.ByClass, Format: report.XLSX}
+			options, err := schedule.Options(tt.at, "USD")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, options.Name())
+			assert.Equal(t, tt.wantGranularity, options.Granularity)
+		})
+	}
+}
+
+type memoryRepository struct {
+	reports []*models.ArchivedReport
+}
+
+func (r *memoryRepository) find(archived *models.ArchivedReport) *models.ArchivedReport {
+	for _, existing := range r.reports {
+		if existing.Schedule == archived.Schedule && existing.PeriodStart.Equal(archived.PeriodStart) &&
+			existing.PeriodEnd.Equal(archived.PeriodEnd) && existing.Format == archived.Format {
+			return existing
+		}
+	}
+	return nil
+}
+
+func retryable(archived *models.ArchivedReport, staleBefore time.Time, maxAttempts int) bool {
+	if archived.Attempts >= maxAttempts {
+		return false
+	}
+	switch archived.Status {
+	case models.ReportFailed:
+		return true
+	case models.ReportRunning:
+		return archived.StartedAt == nil || archived.StartedAt.Before(staleBefore)
+	}
+	return false
+}
+
+func (r *memoryRepository) Create(archived *models.ArchivedReport) (bool, error) {
+	if r.find(archived) != nil {
+		return false, nil
+	}
+	archived.ID = uint(len(r.reports) + 1)
+	stored := *archived
+	r.reports = append(r.reports, &stored)
+	return true, nil
+}
+
+func (r *memoryRepository) TakeOver(archived *models.ArchivedReport, staleBefore time.Time, maxAttempts int) (bool, error) {
+	existing := r.find(archived)
+	if existing == nil || !retryable(existing, staleBefore, maxAttempts) {
+		return false, nil
+	}
+	existing.Status = models.ReportRunning
+	existing.Error = ""
+	existing.Attempts++
+	existing.StartedAt = archived.StartedAt
+	existing.FinishedAt = nil
+	archived.ID = existing.ID
+	archived.Attempts = existing.Attempts
+	archived.Status = models.ReportRunning
+	return true, nil
+}
+
+func (r *memoryRepository) ListRetryable(staleBefore time.Time, maxAttempts int) ([]models.ArchivedReport, error) {
+	var reports []models.ArchivedReport
+	for _, existing := range r.reports {
+		if retryable(existing, staleBefore, maxAttempts) {
+			reports = append(reports, *existing)
+		}
+	}
+	return reports, nil
+}
+
+func (r *memoryRepository) Finish(archived *models.ArchivedReport) error {
+	existing := r.reports[archived.ID-1]
+	if existing.Status != models.ReportRunning || existing.Attempts != archived.Attempts {
+		return gorm.ErrRecordNotFound
+	}
+	*existing = *archived
+	return nil
+}
+
+func (r *memoryRepository) List(offset, limit int) ([]models.ArchivedReport, error) { return nil, nil }
+
+func (r *memoryRepository) GetByID(id uint) (*models.ArchivedReport, error) { return nil, nil }
+
+func newTestScheduler(repo *memoryRepository, fail *bool) *Scheduler {
+	return NewScheduler(repo, func(options report.Options, format report.Format, w io.Writer) error {
+		if *fail {
+			return errors.New("no database")
+		}
+		_, err := w.Write([]byte("report"))
+		return err
+	}, "USD", nil)
+}
+
+func TestGenerate(t *testing.T) {
+	repo := &memoryRepository{}
+	fail := false
+	scheduler := newTestScheduler(repo, &fail)
+	schedule := Schedule{Spec: "0 6 1 * *", Range: report.Month, Grouping: report.ByClass, Format: report.PDF}
+
+	archived, err := scheduler.Generate(schedule, time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.NotNil(t, archived)
+	assert.Equal(t, models.ReportDone, archived.Status)
+	assert.Equal(t, "report_profit_2024-02.pdf", archived.FileName)
+	assert.Equal(t, []byte("report"), archived.Content)
+	assert.Equal(t, 6, archived.Size)
+
+	// The same range again, e.g. from another instance
+	archived, err = scheduler.Generate(schedule, time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Nil(t, archived)
+
+	fail = true
+	archived, err = scheduler.Generate(schedule, time.Date(2024, 4, 1, 6, 0, 0, 0, time.UTC))
+	assert.Error(t, err)
+	require.NotNil(t, archived)
+	assert.Equal(t, models.ReportFailed, archived.Status)
+	assert.Equal(t, "no database", archived.Error)
+	assert.Empty(t, archived.Content)
+	assert.Len(t, repo.reports, 2)
+}
+
+func TestGenerateTakesOver(t *testing.T) {
+	repo := &memoryRepository{}
+	fail := true
+	scheduler := newTestScheduler(repo, &fail)
+	schedule := Schedule{Spec: "0 6 1 * *", Range: report.Month, Grouping: report.ByClass, Format: report.XLSX}
+	at := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+
+	_, err := scheduler.Generate(schedule, at)
+	require.Error(t, err)
+
+	// A failed run is taken over on the same row
+	fail = false
+	archived, err := scheduler.Generate(schedule, at)
+	require.NoError(t, err)
+	require.NotNil(t, archived)
+	assert.Equal(t, uint(1), archived.ID)
+	assert.Equal(t, 2, archived.Attempts)
+	assert.Equal(t, models.ReportDone, archived.Status)
+	assert.Empty(t, archived.Error)
+	require.Len(t, repo.reports, 1)
+	assert.Equal(t, []byte("report"), repo.reports[0].Content)
+
+	// A run left running by a crash is only taken over after runTimeout
+	repo.reports[0].Status = models.ReportRunning
+	archived, err = scheduler.Generate(schedule, at.Add(runTimeout/2))
+	require.NoError(t, err)
+	assert.Nil(t, archived)
+
+	archived, err = scheduler.Generate(schedule, at.Add(2*runTimeout))
+	require.NoError(t, err)
+	require.NotNil(t, archived)
+	assert.Equal(t, models.ReportDone, archived.Status)
+	assert.Equal(t, 3, archived.Attempts)
+
+	// Nor when it used up its attempts
+	repo.reports[0].Status = models.ReportRunning
+	repo.reports[0].Attempts = maxAttempts
+	archived, err = scheduler.Generate(schedule, at.Add(4*runTimeout))
+	require.NoError(t, err)
+	assert.Nil(t, archived)
+	assert.Equal(t, maxAttempts, repo.reports[0].Attempts)
+}
+
+func TestGenerateDropsTakenOverRun(t *testing.T) {
+	repo := &memoryRepository{}
+	// Another instance takes the run over while this one still generates.
+	scheduler := NewScheduler(repo, func(options report.Options, format report.Format, w io.Writer) error {
+		repo.reports[0].Attempts++
+		_, err := w.Write([]byte("late report"))
+		return err
+	}, "USD", nil)
+	schedule := Schedule{Spec: "0 6 1 * *", Range: report.Month, Grouping: report.ByClass, Format: report.PDF}
+
+	archived, err := scheduler.Generate(schedule, time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Nil(t, archived)
+	require.Len(t, repo.reports, 1)
+	assert.Equal(t, models.ReportRunning, repo.reports[0].Status)
+	assert.Equal(t, 2, repo.reports[0].Attempts)
+	assert.Empty(t, repo.reports[0].Content)
+}
+
+func TestRetry(t *testing.T) {
+	repo := &memoryRepository{}
+	fail := true
+	scheduler := newTestScheduler(repo, &fail)
+	schedule := Schedule{Spec: "0 6 1 * *", Range: report.Quarter, Grouping: report.ByClass, Format: report.XLSX}
+	at := time.Date(2024, 4, 1, 6, 0, 0, 0, time.UTC)
+
+	_, err := scheduler.Generate(schedule, at)
+	require.Error(t, err)
+	for attempt := 2; attempt <= maxAttempts; attempt++ {
+		require.NoError(t, scheduler.Retry(at.Add(time.Duration(attempt)*retryInterval)))
+		assert.Equal(t, attempt, repo.reports[0].Attempts)
+		assert.Equal(t, models.ReportFailed, repo.reports[0].Status)
+	}
+
+	// No attempts left
+	fail = false
+	require.NoError(t, scheduler.Retry(at.Add(time.Duration(maxAttempts+1)*retryInterval)))
+	assert.Equal(t, models.ReportFailed, repo.reports[0].Status)
+
+	repo.reports[0].Attempts = 1
+	require.NoError(t, scheduler.Retry(at.Add(time.Duration(maxAttempts+2)*retryInterval)))
+	archived := repo.reports[0]
+	assert.Equal(t, models.ReportDone, archived.Status)
+	assert.Equal(t, []byte("report"), archived.Content)
+	assert.Equal(t, "report_profit_2024-Q1.xlsx", archived.FileName)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), archived.PeriodStart)
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), archived.PeriodEnd)
+}
@@ -0,0 +1,17 @@
+// Package backoff computes retry delays for the background workers.
+package backoff
+
+import "time"
+
+// Max caps every delay.
+const Max = 10 * time.Minute
+
+// Delay returns the wait before the next try after attempts failed tries:
+// base after the first one, doubled after every further one, up to Max.
+func Delay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < Max; i++ {
+		delay *= 2
+	}
+	return min(delay, Max)
+}
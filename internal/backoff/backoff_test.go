@@ -0,0 +1,14 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay(t *testing.T) {
+	assert.Equal(t, 5*time.Second, Delay(5*time.Second, 1))
+	assert.Equal(t, 20*time.Second, Delay(5*time.Second, 3))
+	assert.Equal(t, Max, Delay(5*time.Second, 30))
+}
@@ -0,0 +1,132 @@
+package classifier
+
+import (
+	"backend/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Prediction is a class suggested for a service with its probability.
+type Prediction struct {
+	ClassID     uint    `json:"group_id"`
+	Probability float64 `json:"probability"`
+}
+
+// Result is the ranked output of one classifier call.
+type Result struct {
+	ModelVersion string
+	Predictions  []Prediction
+	// SchemaVersion identifies the features that were sent, ModelSchema
+	// the features the model was trained on. ModelSchema is empty when the
+	// model did not report it.
+	SchemaVersion string
+	ModelSchema   string
+}
+
+// SchemaMismatch reports whether the model was trained on other features
+// than it received. In strict mode a model that does not report its schema
+// counts as a mismatch.
+func (r *Result) SchemaMismatch(strict bool) bool {
+	if r.ModelSchema == "" {
+		return strict
+	}
+	return r.ModelSchema != r.SchemaVersion
+}
+
+// Features is the parameter vector sent to the model: every supported
+// parameter ID mapped to 1 when the service has it and 0 otherwise.
+type Features map[string]int
+
+// Classifier suggests classes for a service, best prediction first.
+// PredictBatch returns one result per features, in the same order.
+type Classifier interface {
+	Name() string
+	Predict(ctx context.Context, features Features) (*Result, error)
+	PredictBatch(ctx context.Context, features []Features) ([]*Result, error)
+}
+
+// predictEach implements PredictBatch for classifiers without a batch call.
+func predictEach(ctx context.Context, c Classifier, features []Features) ([]*Result, error) {
+	results := make([]*Result, 0, len(features))
+	for _, f := range features {
+		result, err := c.Predict(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func BuildFeatures(supportedParameters []string, parameters []models.Parameter) Features {
+	features := make(Features, len(supportedParameters))
+	for _, parameter := range supportedParameters {
+		features[parameter] = 0
+	}
+	for _, parameter := range parameters {
+		if _, ok := features[parameter.ID]; ok {
+			features[parameter.ID] = 1
+		}
+	}
+	return features
+}
+
+type fallback struct {
+	primary   Classifier
+	secondary Classifier
+}
+
+// WithFallback uses secondary whenever primary fails.
+func WithFallback(primary, secondary Classifier) Classifier {
+	return &fallback{primary: primary, secondary: secondary}
+}
+
+func (f *fallback) Name() string {
+	return f.primary.Name()
+}
+
+func (f *fallback) Predict(ctx context.Context, features Features) (*Result, error) {
+	result, err := f.primary.Predict(ctx, features)
+	if err == nil {
+		return result, nil
+	}
+
+	slog.Warn("Classifier failed, using fallback",
+		slog.String("classifier", f.primary.Name()),
+		slog.String("fallback", f.secondary.Name()),
+		slog.Any("error", err))
+	return f.secondary.Predict(ctx, features)
+}
+
+func (f *fallback) PredictBatch(ctx context.Context, features []Features) ([]*Result, error) {
+	results, err := f.primary.PredictBatch(ctx, features)
+	if err == nil {
+		return results, nil
+	}
+
+	slog.Warn("Classifier failed, using fallback",
+		slog.String("classifier", f.primary.Name()),
+		slog.String("fallback", f.secondary.Name()),
+		slog.Int("batch", len(features)),
+		slog.Any("error", err))
+	return f.secondary.PredictBatch(ctx, features)
+}
+
+// Parameters returns the parameter IDs of the features in sorted order.
+func (f Features) Parameters() []string {
+	return slices.Sorted(maps.Keys(f))
+}
+
+// SchemaVersion identifies the feature vector built from the supported
+// parameters, independent of their order.
+func SchemaVersion(supportedParameters []string) string {
+	parameters := slices.Clone(supportedParameters)
+	slices.Sort(parameters)
+	sum := sha256.Sum256([]byte(strings.Join(slices.Compact(parameters), "\n")))
+	return hex.EncodeToString(sum[:8])
+}
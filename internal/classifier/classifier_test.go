@@ -0,0 +1,159 @@
+package classifier
+
+import (
+	"backend/internal/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func approved(classID uint, parameters ...string) models.Service {
+	service := models.Service{ClassID: &classID}
+	for _, parameter := range parameters {
+		service.Parameters = append(service.Parameters, models.Parameter{ID: parameter})
+	}
+	return service
+}
+
+func TestBuildFeatures(t *testing.T) {
+	features := BuildFeatures([]string{"mob_inet", "sms", "iot"}, []models.Parameter{{ID: "sms"}, {ID: "unknown"}})
+	assert.Equal(t, Features{"mob_inet": 0, "sms": 1, "iot": 0}, features)
+}
+
+func TestSchemaVersion(t *testing.T) {
+	version := SchemaVersion([]string{"sms", "mob_inet"})
+	assert.Len(t, version, 16)
+	assert.Equal(t, version, SchemaVersion([]string{"mob_inet", "sms", "sms"}))
+	assert.NotEqual(t, version, SchemaVersion([]string{"mob_inet", "sms", "iot"}))
+}
+
+func TestLocalPredict(t *testing.T) {
+	services := []models.Service{
+		approved(29, "mob_inet", "period_service"),
+		approved(29, "mob_inet", "period_service", "roaming"),
+		approved(9, "sms", "period_service"),
+		approved(3001, "voice_fix"),
+		{Parameters: []models.Parameter{{ID: "mob_inet"}}},
+	}
+	supported := []string{"mob_inet", "sms", "period_service", "roaming", "voice_fix"}
+
+	tests := []struct {
+		name       string
+		k          int
+		parameters []models.Parameter
+		want       []Prediction
+	}{
+		{
+			name:       "Nearest class wins",
+			k:          5,
+			parameters: []models.Parameter{{ID: "mob_inet"}, {ID: "period_service"}},
+			want: []Prediction{
+				{ClassID: 29, Probability: (1 + 2.0/3) / 3},
+				{ClassID: 9, Probability: (1.0 / 3) / 3},
+			},
+		},
+		{
+			name:       "Only k neighbours vote",
+			k:          1,
+			parameters: []models.Parameter{{ID: "sms"}, {ID: "period_service"}},
+			want:       []Prediction{{ClassID: 9, Probability: 1}},
+		},
+		{
+			name:       "One distant neighbour is not confident",
+			k:          1,
+			parameters: []models.Parameter{{ID: "voice_fix"}, {ID: "sms"}, {ID: "roaming"}},
+			want:       []Prediction{{ClassID: 3001, Probability: 1.0 / 3}},
+		},
+		{
+			name:       "No similar services",
+			k:          5,
+			parameters: []models.Parameter{{ID: "iot"}},
+			want:       []Prediction{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := NewLocal(func() ([]models.Service, error) { return services, nil }, tt.k, time.Minute)
+			got, err := local.Predict(context.Background(), BuildFeatures(supported, tt.parameters))
+			require.NoError(t, err)
+			assert.InDeltaSlice(t, probabilities(tt.want), probabilities(got.Predictions), 1e-9)
+			assert.Equal(t, classIDs(tt.want), classIDs(got.Predictions))
+			assert.Contains(t, got.ModelVersion, "local-knn-")
+		})
+	}
+}
+
+func TestLocalRetrains(t *testing.T) {
+	calls := 0
+	local := NewLocal(func() ([]models.Service, error) {
+		calls++
+		return []models.Service{approved(9, "sms")}, nil
+	}, 5, time.Minute)
+	now := time.Now()
+	local.now = func() time.Time { return now }
+
+	features := Features{"sms": 1}
+	_, err := local.Predict(context.Background(), features)
+	require.NoError(t, err)
+	_, err = local.Predict(context.Background(), features)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	now = now.Add(time.Minute)
+	_, err = local.Predict(context.Background(), features)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+type stub struct {
+	result *Result
+	err    error
+}
+
+func (s stub) Name() string { return "stub" }
+
+func (s stub) Predict(context.Context, Features) (*Result, error) {
+	return s.result, s.err
+}
+
+func (s stub) PredictBatch(ctx context.Context, features []Features) ([]*Result, error) {
+	return predictEach(ctx, s, features)
+}
+
+func TestWithFallback(t *testing.T) {
+	remote := &Result{ModelVersion: "v3", Predictions: []Prediction{{ClassID: 29, Probability: 0.9}}}
+	local := &Result{ModelVersion: "local", Predictions: []Prediction{{ClassID: 9, Probability: 1}}}
+
+	got, err := WithFallback(stub{result: remote}, stub{result: local}).Predict(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, remote, got)
+
+	got, err = WithFallback(stub{err: errors.New("timeout")}, stub{result: local}).Predict(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, local, got)
+
+	batch, err := WithFallback(stub{err: errors.New("timeout")}, stub{result: local}).PredictBatch(context.Background(), []Features{nil, nil})
+	require.NoError(t, err)
+	assert.Equal(t, []*Result{local, local}, batch)
+}
+
+func probabilities(predictions []Prediction) []float64 {
+	values := make([]float64, 0, len(predictions))
+	for _, prediction := range predictions {
+		values = append(values, prediction.Probability)
+	}
+	return values
+}
+
+func classIDs(predictions []Prediction) []uint {
+	ids := make([]uint, 0, len(predictions))
+	for _, prediction := range predictions {
+		ids = append(ids, prediction.ClassID)
+	}
+	return ids
+}
@@ -0,0 +1,142 @@
+package classifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SchemaHeader carries the feature schema version in both directions.
+const SchemaHeader = "X-Schema-Version"
+
+// HTTP calls the external model service.
+type HTTP struct {
+	url      string
+	batchURL string
+	token    string
+	client   *http.Client
+}
+
+// NewHTTP returns a classifier calling url for single predictions and
+// batchURL for batches. Without batchURL batches are sent one by one.
+func NewHTTP(url, batchURL, token string, timeout time.Duration) *HTTP {
+	return &HTTP{
+		url:      url,
+		batchURL: batchURL,
+		token:    token,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (h *HTTP) Name() string {
+	return "http"
+}
+
+type modelResponse struct {
+	ModelVersion  string       `json:"model_version"`
+	SchemaVersion string       `json:"schema_version"`
+	Predictions   []Prediction `json:"predictions"`
+	Results       []struct {
+		Predictions []Prediction `json:"predictions"`
+	} `json:"results"`
+}
+
+// Predict sends the features to the model together with their schema version
+// in the X-Schema-Version header. The model may report its version in
+// "model_version", otherwise the classifier name is used, and the schema it
+// was trained on in "schema_version" or the X-Schema-Version header.
+func (h *HTTP) Predict(ctx context.Context, features Features) (*Result, error) {
+	if h.url == "" {
+		return nil, errors.New("model URL is not configured")
+	}
+
+	schemaVersion := SchemaVersion(features.Parameters())
+	response, err := h.post(ctx, h.url, features, schemaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		ModelVersion:  response.ModelVersion,
+		Predictions:   response.Predictions,
+		SchemaVersion: schemaVersion,
+		ModelSchema:   response.SchemaVersion,
+	}, nil
+}
+
+// PredictBatch sends {"instances": [...]} to the batch URL and expects one
+// entry in "results" per instance. All features of a batch share a schema.
+func (h *HTTP) PredictBatch(ctx context.Context, features []Features) ([]*Result, error) {
+	if h.batchURL == "" {
+		return predictEach(ctx, h, features)
+	}
+	if len(features) == 0 {
+		return nil, nil
+	}
+
+	schemaVersion := SchemaVersion(features[0].Parameters())
+	response, err := h.post(ctx, h.batchURL, map[string]any{"instances": features}, schemaVersion)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Results) != len(features) {
+		return nil, fmt.Errorf("model returned %d results for %d instances", len(response.Results), len(features))
+	}
+
+	results := make([]*Result, 0, len(features))
+	for _, result := range response.Results {
+		results = append(results, &Result{
+			ModelVersion:  response.ModelVersion,
+			Predictions:   result.Predictions,
+			SchemaVersion: schemaVersion,
+			ModelSchema:   response.SchemaVersion,
+		})
+	}
+	return results, nil
+}
+
+func (h *HTTP) post(ctx context.Context, url string, body any, schemaVersion string) (*modelResponse, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+h.token)
+	req.Header.Set(SchemaHeader, schemaVersion)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, errors.New(string(bodyBytes))
+	}
+
+	var result modelResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	if result.ModelVersion == "" {
+		result.ModelVersion = h.Name()
+	}
+	if result.SchemaVersion == "" {
+		result.SchemaVersion = resp.Header.Get(SchemaHeader)
+	}
+	return &result, nil
+}
@@ -0,0 +1,81 @@
+package classifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPPredictSchema(t *testing.T) {
+	features := Features{"mob_inet": 1, "sms": 0}
+	schemaVersion := SchemaVersion([]string{"mob_inet", "sms"})
+
+	tests := []struct {
+		name      string
+		body      string
+		header    string
+		wantModel string
+	}{
+		{name: "Reported in body", body: `{"schema_version":"b2","predictions":[{"group_id":29,"probability":0.9}]}`, wantModel: "b2"},
+		{name: "Reported in header", body: `{"predictions":[{"group_id":29,"probability":0.9}]}`, header: schemaVersion, wantModel: schemaVersion},
+		{name: "Not reported", body: `{"predictions":[{"group_id":29,"probability":0.9}]}`, wantModel: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, schemaVersion, r.Header.Get(SchemaHeader))
+				var sent Features
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+				assert.Equal(t, features, sent)
+				if tt.header != "" {
+					w.Header().Set(SchemaHeader, tt.header)
+				}
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			got, err := NewHTTP(server.URL, "", "token", time.Second).Predict(context.Background(), features)
+			require.NoError(t, err)
+			assert.Equal(t, "http", got.ModelVersion)
+			assert.Equal(t, []Prediction{{ClassID: 29, Probability: 0.9}}, got.Predictions)
+			assert.Equal(t, schemaVersion, got.SchemaVersion)
+			assert.Equal(t, tt.wantModel, got.ModelSchema)
+		})
+	}
+}
+
+func TestHTTPPredictBatch(t *testing.T) {
+	features := []Features{{"mob_inet": 1, "sms": 0}, {"mob_inet": 0, "sms": 1}}
+
+	var sent []Features
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Instances []Features `json:"instances"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		sent = body.Instances
+		_, _ = w.Write([]byte(`{"model_version":"v4","results":[
+			{"predictions":[{"group_id":29,"probability":0.9}]},
+			{"predictions":[{"group_id":9,"probability":0.8}]}
+		]}`))
+	}))
+	defer server.Close()
+
+	got, err := NewHTTP("", server.URL, "token", time.Second).PredictBatch(context.Background(), features)
+	require.NoError(t, err)
+	assert.Equal(t, features, sent)
+	require.Len(t, got, 2)
+	assert.Equal(t, "v4", got[1].ModelVersion)
+	assert.Equal(t, []Prediction{{ClassID: 9, Probability: 0.8}}, got[1].Predictions)
+
+	// The model answers with two results for one instance.
+	_, err = NewHTTP("", server.URL, "token", time.Second).PredictBatch(context.Background(), features[:1])
+	assert.Error(t, err)
+}
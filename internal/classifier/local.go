@@ -0,0 +1,150 @@
+package classifier
+
+import (
+	"backend/internal/models"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Local is a k-nearest-neighbour classifier trained on approved services.
+// Similarity is the Jaccard index of the parameter sets, the k most similar
+// services vote for their class with their similarity as weight. The
+// probability of a class is its weight over the number of neighbours, so it
+// is its vote share scaled by how similar the neighbours are: one distant
+// neighbour does not make a confident prediction.
+type Local struct {
+	source  func() ([]models.Service, error)
+	k       int
+	refresh time.Duration
+	now     func() time.Time
+
+	mu        sync.Mutex
+	samples   []sample
+	trainedAt time.Time
+}
+
+type sample struct {
+	classID    uint
+	parameters map[string]struct{}
+}
+
+// NewLocal trains on the services returned by source and retrains once the
+// training set is older than refresh.
+func NewLocal(source func() ([]models.Service, error), k int, refresh time.Duration) *Local {
+	return &Local{
+		source:  source,
+		k:       max(k, 1),
+		refresh: refresh,
+		now:     time.Now,
+	}
+}
+
+func (l *Local) Name() string {
+	return "local"
+}
+
+// Predict votes among the nearest approved services. The model version names
+// the training time, so predictions can be traced to a training set. The
+// model is fitted on the features it receives, so its schema always matches.
+func (l *Local) Predict(_ context.Context, features Features) (*Result, error) {
+	samples, trainedAt, err := l.trainingSet()
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]struct{}, len(features))
+	for parameter, value := range features {
+		if value != 0 {
+			present[parameter] = struct{}{}
+		}
+	}
+
+	type neighbour struct {
+		classID    uint
+		similarity float64
+	}
+	var neighbours []neighbour
+	for _, sample := range samples {
+		// Only the supported parameters take part, exactly like for the
+		// remote model.
+		var common, union int
+		for parameter := range sample.parameters {
+			if _, ok := features[parameter]; !ok {
+				continue
+			}
+			union++
+			if _, ok := present[parameter]; ok {
+				common++
+			}
+		}
+		union += len(present) - common
+		if common == 0 {
+			continue
+		}
+		neighbours = append(neighbours, neighbour{sample.classID, float64(common) / float64(union)})
+	}
+	sort.SliceStable(neighbours, func(i, j int) bool { return neighbours[i].similarity > neighbours[j].similarity })
+	if len(neighbours) > l.k {
+		neighbours = neighbours[:l.k]
+	}
+
+	votes := make(map[uint]float64)
+	for _, neighbour := range neighbours {
+		votes[neighbour.classID] += neighbour.similarity
+	}
+
+	predictions := make([]Prediction, 0, len(votes))
+	for classID, vote := range votes {
+		predictions = append(predictions, Prediction{ClassID: classID, Probability: vote / float64(len(neighbours))})
+	}
+	sort.Slice(predictions, func(i, j int) bool {
+		if predictions[i].Probability == predictions[j].Probability {
+			return predictions[i].ClassID < predictions[j].ClassID
+		}
+		return predictions[i].Probability > predictions[j].Probability
+	})
+
+	schemaVersion := SchemaVersion(features.Parameters())
+	return &Result{
+		ModelVersion:  fmt.Sprintf("%s-knn-%d@%s", l.Name(), l.k, trainedAt.UTC().Format(time.RFC3339)),
+		Predictions:   predictions,
+		SchemaVersion: schemaVersion,
+		ModelSchema:   schemaVersion,
+	}, nil
+}
+
+func (l *Local) PredictBatch(ctx context.Context, features []Features) ([]*Result, error) {
+	return predictEach(ctx, l, features)
+}
+
+func (l *Local) trainingSet() ([]sample, time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.samples != nil && l.now().Sub(l.trainedAt) < l.refresh {
+		return l.samples, l.trainedAt, nil
+	}
+
+	services, err := l.source()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	samples := make([]sample, 0, len(services))
+	for _, service := range services {
+		if service.ClassID == nil {
+			continue
+		}
+		parameters := make(map[string]struct{}, len(service.Parameters))
+		for _, parameter := range service.Parameters {
+			parameters[parameter.ID] = struct{}{}
+		}
+		samples = append(samples, sample{classID: *service.ClassID, parameters: parameters})
+	}
+
+	l.samples = samples
+	l.trainedAt = l.now()
+	return samples, l.trainedAt, nil
+}
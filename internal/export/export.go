@@ -0,0 +1,288 @@
+// Package export writes tables row by row as CSV, NDJSON or XLSX so that
+// large exports do not have to be held in memory.
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+	XLSX   Format = "xlsx"
+)
+
+var contentTypes = map[Format]string{
+	CSV:    "text/csv; charset=utf-8",
+	NDJSON: "application/x-ndjson",
+	XLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	return contentTypes[f]
+}
+
+// ParseFormat accepts a format name.
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case CSV, NDJSON, XLSX:
+		return format, nil
+	case "json", "jsonl":
+		return NDJSON, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, use xlsx, csv or ndjson", value)
+	}
+}
+
+// Negotiate picks the format from an explicit format name or, without one,
+// from the Accept header. XLSX is the default.
+func Negotiate(format, accept string) (Format, error) {
+	if format != "" {
+		return ParseFormat(format)
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return CSV, nil
+		case "application/x-ndjson", "application/jsonl", "application/json":
+			return NDJSON, nil
+		case contentTypes[XLSX]:
+			return XLSX, nil
+		}
+	}
+	return XLSX, nil
+}
+
+// Column is one field of the exported rows. Key names the field in CSV
+// headers and NDJSON objects, Title is the XLSX header.
+type Column struct {
+	Key   string
+	Title string
+	// Width of the XLSX column, 0 keeps the default.
+	Width float64
+}
+
+// Writer writes the rows of one table. Row values follow the order of the
+// columns, Close flushes what is left.
+type Writer interface {
+	Row(values ...any) error
+	Close() error
+}
+
+// NewWriter writes the header and returns a writer for the rows. sheet
+// names the XLSX sheet.
+func NewWriter(w io.Writer, format Format, sheet string, columns []Column) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w, columns)
+	case NDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	case XLSX:
+		return newXLSXWriter(w, sheet, columns)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.Key)
+	}
+	writer := &csvWriter{csv.NewWriter(w)}
+	return writer, writer.w.Write(header)
+}
+
+func (w *csvWriter) Row(values ...any) error {
+	record := make([]string, 0, len(values))
+	for _, value := range values {
+		record = append(record, Text(value))
+	}
+	return w.w.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []Column
+}
+
+// Row writes one JSON object per line with the keys in column order.
+func (w *ndjsonWriter) Row(values ...any) error {
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, column := range w.columns {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		key, err := json.Marshal(column.Key)
+		if err != nil {
+			return err
+		}
+		var value any
+		if i < len(values) {
+			value = values[i]
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		line.Write(key)
+		line.WriteByte(':')
+		line.Write(data)
+	}
+	line.WriteString("}\n")
+	_, err := w.w.Write(line.Bytes())
+	return err
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.w.Flush()
+}
+
+// xlsxWriter streams rows into a sheet. The workbook is only complete after
+// the last row, so it is written to w on Close.
+type xlsxWriter struct {
+	w      io.Writer
+	f      *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXWriter(w io.Writer, sheet string, columns []Column) (*xlsxWriter, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{
+			Bold: true,
+		},
+		Fill: excelize.Fill{
+			Type:    "pattern",
+			Pattern: 1,
+			Color:   []string{"#D9E1F2"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	stream, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]any, 0, len(columns))
+	for i, column := range columns {
+		header = append(header, excelize.Cell{StyleID: headerStyle, Value: column.Title})
+		if column.Width > 0 {
+			if err := stream.SetColWidth(i+1, i+1, column.Width); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := stream.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return nil, err
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		return nil, err
+	}
+
+	return &xlsxWriter{w: w, f: f, stream: stream, row: 1}, nil
+}
+
+func (w *xlsxWriter) Row(values ...any) error {
+	w.row++
+	cells := make([]any, 0, len(values))
+	for _, value := range values {
+		cells = append(cells, cellValue(value))
+	}
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	return w.stream.SetRow(cell, cells)
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.f.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := w.f.WriteTo(w.w)
+	return err
+}
+
+// cellValue keeps numbers numeric in XLSX and formats everything else like
+// CSV.
+func cellValue(value any) any {
+	switch v := value.(type) {
+	case int, uint, float64:
+		return v
+	case *uint:
+		if v == nil {
+			return nil
+		}
+		return *v
+	default:
+		return Text(value)
+	}
+}
+
+// Text formats a value for CSV and XLSX: lists are joined with commas, times
+// use minute precision and nil pointers are empty.
+func Text(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ",")
+	case []uint:
+		parts := make([]string, 0, len(v))
+		for _, id := range v {
+			parts = append(parts, fmt.Sprint(id))
+		}
+		return strings.Join(parts, ",")
+	case time.Time:
+		return v.Format("2006-01-02 15:04")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format("2006-01-02 15:04")
+	case *uint:
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprint(*v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+var columns = []Column{{Key: "id", Title: "ID"}, {Key: "parameters", Title: "Parameters"}, {Key: "approved_at", Title: "Approved At"}}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		accept  string
+		want    Format
+		wantErr bool
+	}{
+		{name: "default", accept: "*/*", want: XLSX},
+		{name: "format wins", format: "csv", accept: "application/x-ndjson", want: CSV},
+		{name: "json alias", format: "json", want: NDJSON},
+		{name: "accept csv", accept: "text/html, text/csv;q=0.9", want: CSV},
+		{name: "accept ndjson", accept: "application/x-ndjson", want: NDJSON},
+		{name: "unknown format", format: "pdf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Negotiate(tt.format, tt.accept)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	approvedAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{
+			format: CSV,
+			want:   "id,parameters,approved_at\n1,\"mob_inet,roaming\",2024-03-01 12:30\n2,,\n",
+		},
+		{
+			format: NDJSON,
+			want: `{"id":1,"parameters":["mob_inet","roaming"],"approved_at":"2024-03-01T12:30:00Z"}` + "\n" +
+				`{"id":2,"parameters":[],"approved_at":null}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, tt.format, "Services", columns)
+			require.NoError(t, err)
+			require.NoError(t, w.Row(1, []string{"mob_inet", "roaming"}, &approvedAt))
+			require.NoError(t, w.Row(2, []string{}, (*time.Time)(nil)))
+			require.NoError(t, w.Close())
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, XLSX, "Services", columns)
+	require.NoError(t, err)
+	require.NoError(t, w.Row(1, []string{"mob_inet", "roaming"}, (*time.Time)(nil)))
+	require.NoError(t, w.Close())
+
+	f, err := excelize.OpenReader(&buf)
+	require.NoError(t, err)
+	rows, err := f.GetRows("Services")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"ID", "Parameters", "Approved At"}, {"1", "mob_inet,roaming"}}, rows)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"backend/internal/models"
+	"backend/internal/reconcile"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListOutbox godoc
+//
+//	@Summary		List knowledge-base outbox events
+//	@Description	Lists outbox events that still have to reach the knowledge base. By default only pending and failed events are returned.
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			status	query		string	false	"Comma-separated statuses (pending, running, failed, done)"	default(pending,failed)
+//	@Param			offset	query		int		false	"Offset"											default(0)
+//	@Param			limit	query		int		false	"Limit"												default(50)
+//	@Success		200		{array}		models.OutboxEvent
+//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/admin/outbox [get]
+func (h *Handler) ListOutbox(c *gin.Context) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	var statuses []models.OutboxStatus
+	for _, status := range strings.Split(c.DefaultQuery("status", "pending,failed"), ",") {
+		switch models.OutboxStatus(status) {
+		case models.OutboxPending, models.OutboxRunning, models.OutboxFailed, models.OutboxDone:
+			statuses = append(statuses, models.OutboxStatus(status))
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status: " + status})
+			return
+		}
+	}
+
+	events, err := h.OutboxRepo.List(statuses, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// RetryOutboxEvent godoc
+//
+//	@Summary		Retry a failed outbox event
+//	@Description	Moves a failed outbox event back to the pending queue and resets its attempts.
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			id	path		int	true	"Event ID"
+//	@Success		200	{object}	models.OutboxEvent
+//	@Failure		400	{object}	map[string]string	"Invalid event ID"
+//	@Failure		404	{object}	map[string]string	"Failed event not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/admin/outbox/{id}/retry [post]
+func (h *Handler) RetryOutboxEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	if err := h.OutboxRepo.Retry(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed event not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	event, err := h.OutboxRepo.GetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// Reconcile godoc
+//
+//	@Summary		Reconcile Postgres with the knowledge base
+//	@Description	Compares classes, parameters and approved services in Postgres with the graph and reports missing, orphaned and mismatched entities. Unless dry_run is true, the differences are repaired using the given direction as the source of truth.
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			dry_run		query		bool	false	"Only report differences"							default(true)
+//	@Param			direction	query		string	false	"kb (Postgres -> graph) or db (graph -> Postgres)"	default(kb)
+//	@Success		200			{object}	reconcile.Report
+//	@Failure		400			{object}	map[string]string	"Invalid input"
+//	@Failure		500			{object}	map[string]string	"Internal server error"
+//	@Router			/admin/reconcile [post]
+func (h *Handler) Reconcile(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run"})
+		return
+	}
+	direction, err := reconcile.ParseDirection(c.DefaultQuery("direction", string(reconcile.ToKnowledgeBase)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.Reconciler.Run(c, reconcile.Options{DryRun: dryRun, Direction: direction})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"backend/internal/models"
+	"backend/internal/report"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListReports godoc
+//
+//	@Summary		List archived reports
+//	@Description	Lists the reports generated on a schedule, newest first, without their content. Failed runs are listed with the error and retried a few times.
+//	@Tags			Reports
+//	@Produce		json
+//	@Param			offset	query		int	false	"Offset"	default(0)
+//	@Param			limit	query		int	false	"Limit"		default(50)
+//	@Success		200		{array}		models.ArchivedReport
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/reports [get]
+func (h *Handler) ListReports(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	reports, err := h.ArchivedReportRepo.List(offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// DownloadReport godoc
+//
+//	@Summary		Download an archived report
+//	@Description	Returns the file of a report generated on a schedule.
+//	@Tags			Reports
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Produce		application/pdf
+//	@Param			id	path		int	true	"Report ID"
+//	@Success		200	{file}		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Failure		404	{object}	map[string]string	"Report not found"
+//	@Failure		409	{object}	map[string]string	"Report is still running or failed"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/reports/{id} [get]
+func (h *Handler) DownloadReport(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	archived, err := h.ArchivedReportRepo.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	switch archived.Status {
+	case models.ReportDone:
+	case models.ReportFailed:
+		c.JSON(http.StatusConflict, gin.H{"error": "Report failed: " + archived.Error})
+		return
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "Report is still running"})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+archived.FileName)
+	c.Header("Access-Control-Expose-Headers", "*")
+	c.Data(http.StatusOK, report.Format(archived.Format).ContentType(), archived.Content)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"backend/internal/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxBatchSize limits the number of services in one upload.
+const maxBatchSize = 1000
+
+type newServiceBatch struct {
+	Services []NewService `json:"services" binding:"required,min=1"`
+}
+
+type batchItemResponse struct {
+	Index      int                 `json:"index"`
+	ServiceID  uint                `json:"service_id,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	Violations []violationResponse `json:"violations,omitempty"`
+}
+
+type batchResponse struct {
+	BatchID uint                `json:"batch_id"`
+	Total   int                 `json:"total"`
+	Created int                 `json:"created"`
+	Invalid int                 `json:"invalid"`
+	Items   []batchItemResponse `json:"items"`
+}
+
+type batchProgressResponse struct {
+	models.ServiceBatch
+	// Statuses counts the created services by their current status.
+	Statuses map[models.ServiceStatus]int `json:"statuses"`
+	// Completed is set once no service of the batch waits for the model.
+	Completed bool             `json:"completed"`
+	Services  []models.Service `json:"services"`
+}
+
+// CreateServiceBatch godoc
+//
+//	@Summary		Create services in bulk
+//	@Description	Validates every service like POST /services and stores the valid ones in one transaction. Invalid items are reported with their index and do not stop the others. The stored services are classified in batches, progress can be polled with the returned batch ID.
+//	@Tags			Services
+//	@Accept			json
+//	@Produce		json
+//	@Param			services	body		newServiceBatch	true	"Services"
+//	@Success		202			{object}	batchResponse
+//	@Failure		400			{object}	map[string]string	"Invalid input"
+//	@Failure		500			{object}	map[string]string	"Internal server error"
+//	@Router			/services/batch [post]
+func (h *Handler) CreateServiceBatch(c *gin.Context) {
+	var req newServiceBatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Services) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d services per batch", maxBatchSize)})
+		return
+	}
+
+	items := make([]batchItemResponse, 0, len(req.Services))
+	services := make([]*models.Service, 0, len(req.Services))
+	for i, newService := range req.Services {
+		item, service, err := h.validateBatchItem(c, i, newService)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		items = append(items, item)
+		if service != nil {
+			services = append(services, service)
+		}
+	}
+
+	batch, err := h.ServiceService.CreateBatch(len(req.Services), services)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	created := 0
+	for i := range items {
+		if items[i].Error != "" {
+			continue
+		}
+		items[i].ServiceID = services[created].ID
+		created++
+	}
+
+	c.JSON(http.StatusAccepted, batchResponse{
+		BatchID: batch.ID,
+		Total:   batch.Total,
+		Created: batch.Created,
+		Invalid: batch.Total - batch.Created,
+		Items:   items,
+	})
+}
+
+// GetServiceBatch godoc
+//
+//	@Summary		Get batch progress
+//	@Description	Returns the services of a batch with their status and class and counts them by status.
+//	@Tags			Services
+//	@Produce		json
+//	@Param			id	path		int	true	"Batch ID"
+//	@Success		200	{object}	batchProgressResponse
+//	@Failure		404	{object}	map[string]string	"Batch not found"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/services/batch/{id} [get]
+func (h *Handler) GetServiceBatch(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	batch, err := h.BatchRepo.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Batch not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	services, err := h.ServiceRepo.FindByBatchID(batch.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := batchProgressResponse{
+		ServiceBatch: *batch,
+		Statuses:     make(map[models.ServiceStatus]int),
+		Completed:    true,
+		Services:     services,
+	}
+	for _, service := range services {
+		resp.Statuses[service.Status]++
+		if service.Status == models.ServiceAwaitingPrediction {
+			resp.Completed = false
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// validateBatchItem runs the checks of CreateService on one item of a batch.
+// Validation problems are reported in the item, err is only set when the
+// checks themselves failed. service is nil for an invalid item.
+func (h *Handler) validateBatchItem(c *gin.Context, index int, newService NewService) (batchItemResponse, *models.Service, error) {
+	item := batchItemResponse{Index: index}
+	if newService.Title == "" {
+		item.Error = "Title is required"
+		return item, nil, nil
+	}
+	if len(newService.Parameters) == 0 {
+		item.Error = "Parameters are required"
+		return item, nil, nil
+	}
+
+	params := make([]models.Parameter, 0, len(newService.Parameters))
+	for _, id := range newService.Parameters {
+		parameter, err := h.ParameterRepo.GetByID(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			item.Error = "Invalid parameter " + id
+			return item, nil, nil
+		}
+		if err != nil {
+			return item, nil, err
+		}
+		params = append(params, *parameter)
+	}
+
+	service := &models.Service{Title: newService.Title, Parameters: params}
+	violations, err := h.knowledgeBase.ValidateService(c, service)
+	if err != nil {
+		return item, nil, err
+	}
+	if len(violations) > 0 {
+		item.Violations, err = h.describeViolations(violations)
+		if err != nil {
+			return item, nil, err
+		}
+		item.Error = "This service contain contradiction parameters"
+		return item, nil, nil
+	}
+
+	return item, service, nil
+}
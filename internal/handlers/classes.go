@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"backend/internal/knowledge_base"
 	"backend/internal/models"
+	"backend/internal/services"
+	"backend/internal/sparql"
 	"errors"
 	"net/http"
 	"strconv"
@@ -64,12 +67,10 @@ func (h *Handler) CreateClass(c *gin.Context) {
 	}
 
 	created, err := h.ClassService.CreateClass(class, true)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if isInvalidClass(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	err = h.jenaService.AddClass(c, class)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -78,15 +79,25 @@ func (h *Handler) CreateClass(c *gin.Context) {
 	c.JSON(http.StatusCreated, created)
 }
 
+// classResponse carries the rules linked to the class itself, so that the
+// body can be sent back with PUT, and separately the rules inherited from
+// its ancestors.
+type classResponse struct {
+	models.ClassView
+	InheritedAllowedParameters   []string `json:"inherited_allowed_parameters"`
+	InheritedRequiredParameters  []string `json:"inherited_required_parameters"`
+	InheritedForbiddenParameters []string `json:"inherited_forbidden_parameters"`
+}
+
 // GetClassByID godoc
 //
 //	@Summary		Get a class by ID
-//	@Description	Retrieves a class by its ID.
+//	@Description	Retrieves a class by its ID with its own parameter rules and, in the inherited_* fields, the rules of its ancestors.
 //	@Tags			Classes
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"Class ID"
-//	@Success		200	{object}	models.ClassView
+//	@Success		200	{object}	classResponse
 //	@Failure		400	{object}	map[string]string
 //	@Failure		404	{object}	map[string]string
 //	@Failure		500	{object}	map[string]string
@@ -109,19 +120,35 @@ func (h *Handler) GetClassByID(c *gin.Context) {
 		return
 	}
 
-	constraints, err := h.jenaService.GetClassConstraints(c, class.ID)
+	rules, err := h.knowledgeBase.GetClassRules(c, class.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	var inherited knowledge_base.ClassConstraints
+	if class.ParentID != nil {
+		inherited, err = h.knowledgeBase.GetClassConstraints(c, *class.ParentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	view := models.ClassView{
-		ID:                class.ID,
-		Title:             class.Title,
-		AllowedParameters: constraints,
+	response := classResponse{
+		ClassView: models.ClassView{
+			ID:                  class.ID,
+			Title:               class.Title,
+			ParentID:            class.ParentID,
+			AllowedParameters:   rules.Allowed,
+			RequiredParameters:  rules.Required,
+			ForbiddenParameters: rules.Forbidden,
+		},
+		InheritedAllowedParameters:   nonNil(inherited.Allowed),
+		InheritedRequiredParameters:  nonNil(inherited.Required),
+		InheritedForbiddenParameters: nonNil(inherited.Forbidden),
 	}
 
-	c.JSON(http.StatusOK, view)
+	c.JSON(http.StatusOK, response)
 }
 
 // UpdateClass godoc
@@ -149,6 +176,10 @@ func (h *Handler) UpdateClass(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := knowledge_base.ValidateClassView(class); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// check if exist any service with this class
 	// if exist return error
@@ -162,29 +193,17 @@ func (h *Handler) UpdateClass(c *gin.Context) {
 		return
 	}
 
-	model := &models.Class{
-		ID:    class.ID,
-		Title: class.Title,
-	}
-	if uint(classID) != class.ID {
-		err := h.ClassRepo.Delete(uint(classID))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		err = h.ClassRepo.Create(model)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	} else {
-		if err := h.ClassRepo.Update(model); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if class.ID != uint(classID) {
+		if ok := h.checkNoSubclasses(c, uint(classID)); !ok {
 			return
 		}
 	}
 
-	err = h.jenaService.UpdateClass(c, class)
+	model, err := h.ClassService.UpdateClass(uint(classID), class)
+	if isInvalidClass(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -196,11 +215,11 @@ func (h *Handler) UpdateClass(c *gin.Context) {
 // DeleteClass godoc
 //
 //	@Summary		Delete a class
-//	@Description	Deletes a class by its ID. If the class is used in any services, it returns an error.
+//	@Description	Deletes a class by its ID. If the class is used in any services or has subclasses, it returns an error.
 //	@Tags			Classes
 //	@Param			id	path	int	true	"Class ID"
 //	@Success		204	"Class deleted successfully"
-//	@Failure		400	{object}	map[string]string	"Class is used in services"
+//	@Failure		400	{object}	map[string]string	"Class is used in services or has subclasses"
 //	@Failure		500	{object}	map[string]string	"Internal server error"
 //	@Router			/classes/{id} [delete]
 func (h *Handler) DeleteClass(c *gin.Context) {
@@ -222,16 +241,55 @@ func (h *Handler) DeleteClass(c *gin.Context) {
 		return
 	}
 
-	if err := h.ClassRepo.Delete(uint(classID)); err != nil {
+	if ok := h.checkNoSubclasses(c, uint(classID)); !ok {
+		return
+	}
+
+	if err := h.ClassService.DeleteClass(uint(classID)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = h.jenaService.DeleteClass(c, uint(classID))
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetClassTree godoc
+//
+//	@Summary		Get the class hierarchy
+//	@Description	Returns all classes nested under their parents. Classes without a parent are roots.
+//	@Tags			Classes
+//	@Produce		json
+//	@Success		200	{array}		models.ClassNode
+//	@Failure		500	{object}	map[string]string
+//	@Router			/classes/tree [get]
+func (h *Handler) GetClassTree(c *gin.Context) {
+	tree, err := h.ClassService.Tree()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	c.JSON(http.StatusOK, tree)
+}
+
+// checkNoSubclasses writes an error response and returns false when the
+// class still has subclasses.
+func (h *Handler) checkNoSubclasses(c *gin.Context, classID uint) bool {
+	children, err := h.ClassRepo.FindByParentID(classID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if len(children) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Class has subclasses"})
+		return false
+	}
+	return true
+}
+
+func isInvalidClass(err error) bool {
+	return errors.Is(err, sparql.ErrInvalidLocalName) ||
+		errors.Is(err, knowledge_base.ErrConflictingRules) ||
+		errors.Is(err, services.ErrParentNotFound) ||
+		errors.Is(err, services.ErrClassCycle)
 }
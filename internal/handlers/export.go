@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"backend/internal/export"
+	"backend/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportBatchSize is the number of rows loaded from the database at once.
+const exportBatchSize = 500
+
+var (
+	serviceExportColumns = []export.Column{
+		{Key: "id", Title: "ID", Width: 10},
+		{Key: "title", Title: "Service", Width: 40},
+		{Key: "status", Title: "Status", Width: 20},
+		{Key: "class_id", Title: "Class ID", Width: 10},
+		{Key: "class_title", Title: "Financial Class", Width: 40},
+		{Key: "parameters", Title: "Parameters", Width: 50},
+		{Key: "created_at", Title: "Created At", Width: 18},
+		{Key: "approved_at", Title: "Approved At", Width: 18},
+		{Key: "approval_overridden", Title: "Overridden", Width: 12},
+	}
+	classExportColumns = []export.Column{
+		{Key: "id", Title: "ID", Width: 10},
+		{Key: "title", Title: "Financial Class", Width: 40},
+		{Key: "parent_id", Title: "Parent ID", Width: 10},
+		{Key: "allowed_parameters", Title: "Allowed Parameters", Width: 50},
+		{Key: "required_parameters", Title: "Required Parameters", Width: 30},
+		{Key: "forbidden_parameters", Title: "Forbidden Parameters", Width: 30},
+	}
+	parameterExportColumns = []export.Column{
+		{Key: "id", Title: "ID", Width: 20},
+		{Key: "title", Title: "Parameter", Width: 40},
+		{Key: "new", Title: "New", Width: 8},
+		{Key: "allowed_classes", Title: "Allowed Classes", Width: 30},
+		{Key: "contradiction_parameters", Title: "Contradicting Parameters", Width: 40},
+	}
+)
+
+// ExportServices godoc
+//
+//	@Summary		Export services
+//	@Description	Streams all services with their status, class, parameters and approval date. The format is taken from the format parameter or else from the Accept header, XLSX by default.
+//	@Tags			Export
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/csv,application/x-ndjson
+//	@Param			format	query		string	false	"xlsx, csv or ndjson"
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	map[string]string	"Unknown format"
+//	@Router			/export/services [get]
+func (h *Handler) ExportServices(c *gin.Context) {
+	w, ok := startExport(c, "services", serviceExportColumns)
+	if !ok {
+		return
+	}
+
+	err := h.ServiceRepo.FindInBatches(exportBatchSize, func(services []models.Service) error {
+		for _, service := range services {
+			classTitle := ""
+			if service.Class != nil {
+				classTitle = service.Class.Title
+			}
+			err := w.Row(service.ID, service.Title, service.Status, service.ClassID, classTitle,
+				parameterIDs(service.Parameters), service.CreatedAt, service.ApprovedAt, service.ApprovalOverridden)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	finishExport(w, err)
+}
+
+// ExportClasses godoc
+//
+//	@Summary		Export classes
+//	@Description	Streams all classes with the parameter rules from the knowledge base, including inherited ones. The format is taken from the format parameter or else from the Accept header, XLSX by default.
+//	@Tags			Export
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/csv,application/x-ndjson
+//	@Param			format	query		string	false	"xlsx, csv or ndjson"
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	map[string]string	"Unknown format"
+//	@Router			/export/classes [get]
+func (h *Handler) ExportClasses(c *gin.Context) {
+	w, ok := startExport(c, "classes", classExportColumns)
+	if !ok {
+		return
+	}
+
+	err := h.ClassRepo.FindInBatches(exportBatchSize, func(classes []models.Class) error {
+		for _, class := range classes {
+			constraints, err := h.knowledgeBase.GetClassConstraints(c, class.ID)
+			if err != nil {
+				return fmt.Errorf("class %d: %w", class.ID, err)
+			}
+			err = w.Row(class.ID, class.Title, class.ParentID,
+				nonNil(constraints.Allowed), nonNil(constraints.Required), nonNil(constraints.Forbidden))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	finishExport(w, err)
+}
+
+// ExportParameters godoc
+//
+//	@Summary		Export parameters
+//	@Description	Streams all parameters with the classes that allow them and the parameters they contradict. The format is taken from the format parameter or else from the Accept header, XLSX by default.
+//	@Tags			Export
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/csv,application/x-ndjson
+//	@Param			format	query		string	false	"xlsx, csv or ndjson"
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	map[string]string	"Unknown format"
+//	@Router			/export/parameters [get]
+func (h *Handler) ExportParameters(c *gin.Context) {
+	w, ok := startExport(c, "parameters", parameterExportColumns)
+	if !ok {
+		return
+	}
+
+	err := h.ParameterRepo.FindInBatches(exportBatchSize, func(parameters []models.Parameter) error {
+		for _, parameter := range parameters {
+			classes, contradictions, err := h.knowledgeBase.GetParameterConstraints(c, parameter.ID)
+			if err != nil {
+				return fmt.Errorf("parameter %s: %w", parameter.ID, err)
+			}
+			err = w.Row(parameter.ID, parameter.Title, parameter.New, nonNil(classes), nonNil(contradictions))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	finishExport(w, err)
+}
+
+// startExport negotiates the format, sets the download headers and writes
+// the table header. It writes a 400 response when the format is unknown.
+func startExport(c *gin.Context, name string, columns []export.Column) (export.Writer, bool) {
+	format, err := export.Negotiate(c.Query("format"), c.GetHeader("Accept"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_%s.%s", name, time.Now().Format(dateLayout), format))
+	c.Header("Access-Control-Expose-Headers", "*")
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(c.Writer, format, name, columns)
+	if err != nil {
+		log.Printf("Failed to start %s export: %v", name, err)
+		return nil, false
+	}
+	return w, true
+}
+
+// finishExport flushes the export. The status is already sent, so a failure
+// can only be logged and leaves a truncated file.
+func finishExport(w export.Writer, err error) {
+	if err != nil {
+		log.Printf("Failed to export rows: %v", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Failed to write export: %v", err)
+	}
+}
+
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
+
+func parameterIDs(parameters []models.Parameter) []string {
+	ids := make([]string, 0, len(parameters))
+	for _, parameter := range parameters {
+		ids = append(ids, parameter.ID)
+	}
+	return ids
+}
@@ -1,28 +1,58 @@
 package handlers
 
 import (
-	"backend/internal/apache_jena"
+	"backend/internal/knowledge_base"
+	"backend/internal/prediction"
+	"backend/internal/reconcile"
 	"backend/internal/repositories"
+	"backend/internal/retrain"
 	"backend/internal/services"
 )
 
 type Handler struct {
-	ServiceRepo   repositories.ServiceRepository
-	ClassRepo     repositories.ClassRepository
-	ParameterRepo repositories.ParameterRepository
+	ServiceRepo           repositories.ServiceRepository
+	ClassRepo             repositories.ClassRepository
+	ParameterRepo         repositories.ParameterRepository
+	OutboxRepo            repositories.OutboxRepository
+	PredictionRepo        repositories.PredictionJobRepository
+	ServicePredictionRepo repositories.ServicePredictionRepository
+	FeedbackRepo          repositories.ApprovalFeedbackRepository
+	SchemaRepo            repositories.FeatureSchemaRepository
+	BatchRepo             repositories.ServiceBatchRepository
+	RevenueRepo           repositories.RevenueRepository
+	ArchivedReportRepo    repositories.ArchivedReportRepository
 
 	ClassService     *services.ClassService
 	ParameterService *services.ParameterService
-	jenaService      *apache_jena.Service
+	ServiceService   *services.ServiceService
+	Reconciler       *reconcile.Reconciler
+	knowledgeBase    knowledge_base.KnowledgeBase
+	policy           prediction.Policy
+	retrain          *retrain.Trigger
+	// reportCurrency is the currency of the fiscal report.
+	reportCurrency string
 }
 
-func NewHandler(serviceRepo repositories.ServiceRepository, classRepository repositories.ClassRepository, paramRepo repositories.ParameterRepository, service *apache_jena.Service, parameterService *services.ParameterService, classService *services.ClassService) *Handler {
+func NewHandler(serviceRepo repositories.ServiceRepository, classRepository repositories.ClassRepository, paramRepo repositories.ParameterRepository, outboxRepo repositories.OutboxRepository, predictionRepo repositories.PredictionJobRepository, servicePredictionRepo repositories.ServicePredictionRepository, feedbackRepo repositories.ApprovalFeedbackRepository, schemaRepo repositories.FeatureSchemaRepository, batchRepo repositories.ServiceBatchRepository, revenueRepo repositories.RevenueRepository, archivedReportRepo repositories.ArchivedReportRepository, knowledgeBase knowledge_base.KnowledgeBase, policy prediction.Policy, retrain *retrain.Trigger, reportCurrency string, parameterService *services.ParameterService, classService *services.ClassService, serviceService *services.ServiceService, reconciler *reconcile.Reconciler) *Handler {
 	return &Handler{
-		ServiceRepo:      serviceRepo,
-		ClassRepo:        classRepository,
-		ParameterRepo:    paramRepo,
-		ClassService:     classService,
-		ParameterService: parameterService,
-		jenaService:      service,
+		ServiceRepo:           serviceRepo,
+		ClassRepo:             classRepository,
+		ParameterRepo:         paramRepo,
+		OutboxRepo:            outboxRepo,
+		PredictionRepo:        predictionRepo,
+		ServicePredictionRepo: servicePredictionRepo,
+		FeedbackRepo:          feedbackRepo,
+		SchemaRepo:            schemaRepo,
+		BatchRepo:             batchRepo,
+		RevenueRepo:           revenueRepo,
+		ArchivedReportRepo:    archivedReportRepo,
+		ClassService:          classService,
+		ParameterService:      parameterService,
+		ServiceService:        serviceService,
+		Reconciler:            reconciler,
+		knowledgeBase:         knowledgeBase,
+		policy:                policy,
+		retrain:               retrain,
+		reportCurrency:        reportCurrency,
 	}
 }
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"backend/internal/importer"
+	"backend/internal/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type importRowResponse struct {
+	importer.Row
+	ServiceID  uint                `json:"service_id,omitempty"`
+	Violations []violationResponse `json:"violations,omitempty"`
+}
+
+type importResponse struct {
+	DryRun bool `json:"dry_run"`
+	// BatchID follows the classification of the imported services, it is
+	// empty on a dry run.
+	BatchID        uint                `json:"batch_id,omitempty"`
+	Columns        map[string]string   `json:"columns"`
+	UnknownColumns []string            `json:"unknown_columns,omitempty"`
+	Total          int                 `json:"total"`
+	Valid          int                 `json:"valid"`
+	Invalid        int                 `json:"invalid"`
+	Rows           []importRowResponse `json:"rows"`
+}
+
+// ImportServices godoc
+//
+//	@Summary		Import services from a spreadsheet
+//	@Description	Reads services from the first sheet of an XLSX file or from a CSV file. The header needs a title column (title, service or name). Parameters are given either as 0/1 flag columns named after a parameter ID or title, or as a "parameters" column with comma-separated IDs. Other headers can be mapped to parameter IDs with the mapping field. Every row is checked like POST /services, valid rows are stored as one batch unless dry_run is set.
+//	@Tags			Services
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"XLSX or CSV file"
+//	@Param			mapping	formData	string	false	"JSON object mapping headers to parameter IDs"
+//	@Param			dry_run	query		bool	false	"Only validate, store nothing"	default(false)
+//	@Success		200		{object}	importResponse	"Dry run"
+//	@Success		202		{object}	importResponse	"Valid rows were stored"
+//	@Failure		400		{object}	map[string]string	"Invalid file or mapping"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/services/import [post]
+func (h *Handler) ImportServices(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+	format, err := importer.DetectFormat(header.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var mapping map[string]string
+	if value := c.PostForm("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping: " + err.Error()})
+			return
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	records, err := importer.ReadRecords(file, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file: " + err.Error()})
+		return
+	}
+	if len(records) > maxBatchSize+1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d services per import", maxBatchSize)})
+		return
+	}
+
+	parameters, err := h.ParameterRepo.List(0, -1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sheet, err := importer.Parse(records, parameters, mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := importResponse{
+		DryRun:         dryRun,
+		Columns:        sheet.Columns,
+		UnknownColumns: sheet.UnknownColumns,
+		Total:          len(sheet.Rows),
+		Rows:           make([]importRowResponse, 0, len(sheet.Rows)),
+	}
+	var services []*models.Service
+	var valid []int
+	for i, row := range sheet.Rows {
+		resp.Rows = append(resp.Rows, importRowResponse{Row: row})
+		if len(row.Errors) > 0 {
+			continue
+		}
+
+		item, service, err := h.validateBatchItem(c, i, NewService{Title: row.Title, Parameters: row.Parameters})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if service == nil {
+			resp.Rows[i].Errors = append(resp.Rows[i].Errors, item.Error)
+			resp.Rows[i].Violations = item.Violations
+			continue
+		}
+		services = append(services, service)
+		valid = append(valid, i)
+	}
+	resp.Valid = len(services)
+	resp.Invalid = resp.Total - resp.Valid
+
+	if dryRun {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	batch, err := h.ServiceService.CreateBatch(resp.Total, services)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp.BatchID = batch.ID
+	for n, i := range valid {
+		resp.Rows[i].ServiceID = services[n].ID
+	}
+
+	c.JSON(http.StatusAccepted, resp)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"backend/internal/metrics"
+	"backend/internal/repositories"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+const dateLayout = "2006-01-02"
+
+// GetClassificationMetrics godoc
+//
+//	@Summary		Classification quality metrics
+//	@Description	Compares the top model prediction with the class experts approved, using the latest approval of every service. Reports accuracy, top-k accuracy, per-class precision and recall, the confusion matrix and the acceptance rate by confidence bucket and time window.
+//	@Tags			Model
+//	@Produce		json
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param			from	query		string	false	"First approval date, YYYY-MM-DD"
+//	@Param			to		query		string	false	"Last approval date, YYYY-MM-DD"
+//	@Param			window	query		string	false	"day, week or month"		default(month)
+//	@Param			top_k	query		string	false	"Comma-separated k values"	default(1,3,5)
+//	@Param			format	query		string	false	"json or xlsx"				default(json)
+//	@Success		200		{object}	metrics.Classification
+//	@Failure		400		{object}	map[string]string	"Invalid query"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/metrics/classification [get]
+func (h *Handler) GetClassificationMetrics(c *gin.Context) {
+	from, to, err := parseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	window, err := metrics.ParseWindow(c.DefaultQuery("window", string(metrics.Month)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	topK := metrics.DefaultTopK
+	if value := c.Query("top_k"); value != "" {
+		topK = nil
+		for _, part := range strings.Split(value, ",") {
+			k, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || k < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid top_k " + part})
+				return
+			}
+			topK = append(topK, k)
+		}
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown format " + format})
+		return
+	}
+
+	result, err := classificationMetrics(h.FeedbackRepo, h.ClassRepo, from, to, metrics.Options{
+		TopK:    topK,
+		Buckets: metrics.DefaultBuckets,
+		Window:  window,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#D9E1F2"}},
+	})
+	if err == nil {
+		err = writeMetricsSheet(f, result, headerStyle)
+	}
+	if err == nil {
+		err = f.DeleteSheet("Sheet1")
+	}
+	if err != nil {
+		log.Printf("Failed to write metrics sheet: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", "attachment; filename=classification_metrics.xlsx")
+	c.Header("Access-Control-Expose-Headers", "*")
+	if _, err := f.WriteTo(c.Writer); err != nil {
+		log.Printf("Failed to write to response: %v", err)
+	}
+}
+
+// classificationMetrics computes the metrics and adds class titles.
+func classificationMetrics(feedbackRepo repositories.ApprovalFeedbackRepository, classRepo repositories.ClassRepository, from, to *time.Time, options metrics.Options) (metrics.Classification, error) {
+	feedback, err := feedbackRepo.ListLatest(from, to)
+	if err != nil {
+		return metrics.Classification{}, err
+	}
+	result := metrics.Compute(feedback, options)
+
+	classes, err := classRepo.List(0, -1)
+	if err != nil {
+		return metrics.Classification{}, err
+	}
+	titles := make(map[uint]string, len(classes))
+	for _, class := range classes {
+		titles[class.ID] = class.Title
+	}
+	for i := range result.Classes {
+		result.Classes[i].Title = titles[result.Classes[i].ClassID]
+	}
+
+	return result, nil
+}
+
+// parseDateRange parses the inclusive dates from and to into [from, to+1d).
+func parseDateRange(fromValue, toValue string) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+	if fromValue != "" {
+		t, err := time.Parse(dateLayout, fromValue)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid from date %q, use YYYY-MM-DD", fromValue)
+		}
+		from = &t
+	}
+	if toValue != "" {
+		t, err := time.Parse(dateLayout, toValue)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid to date %q, use YYYY-MM-DD", toValue)
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, fmt.Errorf("from date must not be after to date")
+	}
+	return from, to, nil
+}
+
+// writeMetricsSheet writes the classification metrics as consecutive
+// tables on one sheet.
+func writeMetricsSheet(f *excelize.File, result metrics.Classification, headerStyle int) error {
+	const sheet = "Classification Metrics"
+	if _, err := f.NewSheet(sheet); err != nil {
+		return err
+	}
+
+	row := 1
+	table := func(header []string, rows [][]any) error {
+		cell := "A" + strconv.Itoa(row)
+		if err := f.SetSheetRow(sheet, cell, &header); err != nil {
+			return err
+		}
+		last, err := excelize.CoordinatesToCellName(len(header), row)
+		if err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(sheet, cell, last, headerStyle); err != nil {
+			return err
+		}
+		row++
+		for _, values := range rows {
+			if err := f.SetSheetRow(sheet, "A"+strconv.Itoa(row), &values); err != nil {
+				return err
+			}
+			row++
+		}
+		row++
+		return nil
+	}
+
+	summary := [][]any{
+		{"Approvals", result.Approvals},
+		{"With prediction", result.Predicted},
+		{"Accuracy", result.Accuracy},
+	}
+	for _, topK := range result.TopK {
+		summary = append(summary, []any{fmt.Sprintf("Top-%d accuracy", topK.K), topK.Accuracy})
+	}
+	if err := table([]string{"Metric", "Value"}, summary); err != nil {
+		return err
+	}
+
+	classes := make([][]any, 0, len(result.Classes))
+	for _, class := range result.Classes {
+		classes = append(classes, []any{class.ClassID, class.Title, class.Support, class.Predictions, class.Correct, class.Precision, class.Recall})
+	}
+	if err := table([]string{"Class ID", "Financial Class", "Approved", "Predicted", "Correct", "Precision", "Recall"}, classes); err != nil {
+		return err
+	}
+
+	confusion := make([][]any, 0, len(result.Confusion))
+	for _, cell := range result.Confusion {
+		confusion = append(confusion, []any{cell.PredictedClassID, cell.FinalClassID, cell.Count})
+	}
+	if err := table([]string{"Predicted Class ID", "Approved Class ID", "Count"}, confusion); err != nil {
+		return err
+	}
+
+	buckets := make([][]any, 0, len(result.Buckets))
+	for _, bucket := range result.Buckets {
+		buckets = append(buckets, []any{fmt.Sprintf("%.2f - %.2f", *bucket.From, *bucket.To), bucket.Total, bucket.Accepted, bucket.Rate})
+	}
+	if err := table([]string{"Confidence", "Predictions", "Accepted", "Acceptance Rate"}, buckets); err != nil {
+		return err
+	}
+
+	windows := make([][]any, 0, len(result.Windows))
+	for _, window := range result.Windows {
+		windows = append(windows, []any{window.Start.Format(dateLayout), window.Total, window.Accepted, window.Rate})
+	}
+	if err := table([]string{"Period Start", "Predictions", "Accepted", "Acceptance Rate"}, windows); err != nil {
+		return err
+	}
+
+	if err := f.SetColWidth(sheet, "A", "A", 20); err != nil {
+		return err
+	}
+	return f.SetColWidth(sheet, "B", "G", 18)
+}
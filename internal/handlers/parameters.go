@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"backend/internal/knowledge_base"
 	"backend/internal/models"
+	"backend/internal/sparql"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -52,6 +55,10 @@ func (h *Handler) CreateParameter(c *gin.Context) {
 	}
 
 	created, err := h.ParameterService.CreateParameter(parameter, true)
+	if errors.Is(err, sparql.ErrInvalidLocalName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -79,7 +86,7 @@ func (h *Handler) GetParameterByID(context *gin.Context) {
 		return
 	}
 
-	classes, contradictParams, err := h.jenaService.GetParameterConstraints(context, parameterID)
+	classes, contradictParams, err := h.knowledgeBase.GetParameterConstraints(context, parameterID)
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -116,6 +123,14 @@ func (h *Handler) UpdateParameter(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := knowledge_base.ValidateParameterID(parameter.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := knowledge_base.ValidateParameterIDs(parameter.ContradictionParameters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// check if exist any service with this parameter
 	// if exist return error
@@ -129,28 +144,7 @@ func (h *Handler) UpdateParameter(c *gin.Context) {
 		return
 	}
 
-	model := &models.Parameter{
-		ID:    parameter.ID,
-		Title: parameter.Title,
-	}
-
-	if parameter.ID != parameterID {
-		if err := h.ParameterRepo.Delete(parameterID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if err := h.ParameterRepo.Create(model); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	} else {
-		if err := h.ParameterRepo.Update(model); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	}
-
-	err = h.jenaService.UpdateParameter(c, parameter)
+	model, err := h.ParameterService.UpdateParameter(parameterID, parameter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -184,13 +178,7 @@ func (h *Handler) DeleteParameter(c *gin.Context) {
 		return
 	}
 
-	err = h.jenaService.DeleteParameter(c, parameterID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if err := h.ParameterRepo.Delete(parameterID); err != nil {
+	if err := h.ParameterService.DeleteParameter(parameterID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"backend/internal/models"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type predictionResponse struct {
+	ServiceStatus models.ServiceStatus `json:"service_status" example:"awaiting_prediction"`
+	ClassID       *uint                `json:"class_id"`
+	Job           models.PredictionJob `json:"job"`
+}
+
+// GetServicePrediction godoc
+//
+//	@Summary		Get the prediction state of a service
+//	@Description	Returns the latest prediction job of the service with its status, attempts, last error and next retry time.
+//	@Tags			Services
+//	@Produce		json
+//	@Param			id	path		int	true	"Service ID"
+//	@Success		200	{object}	predictionResponse
+//	@Failure		400	{object}	map[string]string	"Invalid service ID"
+//	@Failure		404	{object}	map[string]string	"Service not found or no prediction requested"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/services/{id}/prediction [get]
+func (h *Handler) GetServicePrediction(c *gin.Context) {
+	serviceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return
+	}
+
+	service, err := h.ServiceRepo.GetByID(uint(serviceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+
+	job, err := h.PredictionRepo.GetLatestByServiceID(service.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No prediction requested"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, predictionResponse{
+		ServiceStatus: service.Status,
+		ClassID:       service.ClassID,
+		Job:           *job,
+	})
+}
@@ -1,249 +1,218 @@
 package handlers
 
 import (
+	"backend/internal/metrics"
 	"backend/internal/models"
-	_ "embed"
+	"backend/internal/report"
+	"bytes"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-faker/faker/v4"
 	"github.com/xuri/excelize/v2"
 )
 
 // BuildReport godoc
 //
 //	@Summary		Build fiscal report
-//	@Description	Generates a fiscal report in Excel format and returns it as a downloadable file.
+//	@Description	Generates a fiscal report in Excel or PDF format and returns it as a downloadable file. The revenue of approved services in the report currency is split into a column per period and grouped by financial class, by class hierarchy with subtotals or by parameter, with the number of approved services per row and a grand total row. The range covers whole months, the current year by default. A summary with the total revenue and the number of approved and pending services follows the table.
 //	@Tags			Reports
 //	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
-//	@Success		200	{file}		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
-//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Produce		application/pdf
+//	@Param			from		query		string	false	"First day, YYYY-MM-DD"
+//	@Param			to			query		string	false	"Last day, YYYY-MM-DD"
+//	@Param			year		query		int		false	"Shorthand for the whole year when from and to are not given"
+//	@Param			granularity	query		string	false	"month, quarter or year"			default(quarter)
+//	@Param			group_by	query		string	false	"class, hierarchy or parameter"	default(class)
+//	@Param			format		query		string	false	"xlsx or pdf"						default(xlsx)
+//	@Success		200			{file}		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Failure		400			{object}	map[string]string	"Invalid query"
+//	@Failure		500			{object}	map[string]string	"Internal server error"
 //	@Router			/report [get]
 func (h *Handler) BuildReport(c *gin.Context) {
-	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	c.Header("Content-Disposition", "attachment; filename=report_profit_2024.xlsx")
-	c.Header("Access-Control-Expose-Headers", "*")
-
-	f := excelize.NewFile()
-
-	// Create styles
-	titleStyle, err := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Bold: true,
-			Size: 14,
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-			Vertical:   "center",
-		},
-		Fill: excelize.Fill{
-			Type:    "pattern",
-			Pattern: 1,
-			Color:   []string{"#D9E1F2"},
-		},
-	})
+	format, err := report.ParseFormat(c.DefaultQuery("format", string(report.XLSX)))
 	if err != nil {
-		log.Printf("Failed to create title style: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	subtitleStyle, err := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Bold: true,
-			Size: 12,
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-			Vertical:   "center",
-		},
-		Fill: excelize.Fill{
-			Type:    "pattern",
-			Pattern: 1,
-			Color:   []string{"#FCE4D6"},
-		},
-	})
+	options, err := h.reportOptions(c)
 	if err != nil {
-		log.Printf("Failed to create subtitle style: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Write the titles
-	err = f.MergeCell("Sheet1", "A1", "A2")
-	if err != nil {
-		log.Printf("Failed to merge cells: %v", err)
+	var buf bytes.Buffer
+	if err := h.GenerateReport(options, format, &buf); err != nil {
+		log.Printf("Failed to build report: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	err = f.SetCellValue("Sheet1", "A1", "Financial Class")
+
+	c.Header("Content-Disposition", "attachment; filename="+format.FileName(options))
+	c.Header("Access-Control-Expose-Headers", "*")
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
+
+// GenerateReport writes the fiscal report for options to w. The scheduled
+// reports are generated the same way.
+func (h *Handler) GenerateReport(options report.Options, format report.Format, w io.Writer) error {
+	table, err := h.reportTable(options)
 	if err != nil {
-		log.Printf("Failed to set cell value: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
-	err = f.SetCellStyle("Sheet1", "A1", "A2", titleStyle)
-	if err != nil {
-		log.Printf("Failed to set cell style: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if format == report.PDF {
+		return report.WritePDF(w, table)
 	}
 
-	err = f.MergeCell("Sheet1", "B1", "F1")
+	f := excelize.NewFile()
+	defer f.Close()
+
+	titleStyle, err := report.WriteXLSX(f, table)
 	if err != nil {
-		log.Printf("Failed to merge cells: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return fmt.Errorf("write report sheet: %w", err)
 	}
-	err = f.SetCellValue("Sheet1", "B1", "2024")
-	if err != nil {
-		log.Printf("Failed to set cell value: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if err := h.writeOverridesSheet(f, titleStyle); err != nil {
+		return fmt.Errorf("write overrides sheet: %w", err)
+	}
+	classification, err := classificationMetrics(h.FeedbackRepo, h.ClassRepo, nil, nil, metrics.Options{
+		TopK:    metrics.DefaultTopK,
+		Buckets: metrics.DefaultBuckets,
+		Window:  metrics.Month,
+	})
+	if err == nil {
+		err = writeMetricsSheet(f, classification, titleStyle)
 	}
-	err = f.SetCellStyle("Sheet1", "B1", "F1", titleStyle)
 	if err != nil {
-		log.Printf("Failed to set cell style: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return fmt.Errorf("write metrics sheet: %w", err)
 	}
 
-	// Add quartal headers
-	headers := []string{"1 Quarter", "2 Quarter", "3 Quarter", "4 Quarter", "Total"}
-	for i, header := range headers {
-		col := string(rune('A' + i + 1)) // Convert index to column letter
-		cell := col + "2"
-		f.SetCellValue("Sheet1", cell, header)
-		f.SetCellStyle("Sheet1", cell, cell, subtitleStyle)
+	_, err = f.WriteTo(w)
+	return err
+}
+
+// reportOptions reads the report range, granularity and grouping from the
+// query.
+func (h *Handler) reportOptions(c *gin.Context) (report.Options, error) {
+	granularity, err := report.ParseGranularity(c.DefaultQuery("granularity", string(report.Quarter)))
+	if err != nil {
+		return report.Options{}, err
 	}
-	err = f.SetColWidth("Sheet1", "A", "F", 20)
+	grouping, err := report.ParseGrouping(c.DefaultQuery("group_by", string(report.ByClass)))
 	if err != nil {
-		log.Printf("Failed to set column width: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return report.Options{}, err
 	}
 
-	services, err := h.ServiceRepo.List(0, 10_000)
-	if err != nil {
-		log.Printf("Failed to list services: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	year := time.Now().Year()
+	if value := c.Query("year"); value != "" {
+		year, err = strconv.Atoi(value)
+		if err != nil || year < 1 || year > 9999 {
+			return report.Options{}, fmt.Errorf("invalid year %q", value)
+		}
 	}
-	var classes []*models.Class
-	for _, service := range services {
-		if service.ApprovedAt != nil {
-			classes = append(classes, service.Class)
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(dateLayout, value); err != nil {
+			return report.Options{}, fmt.Errorf("invalid from date %q, use YYYY-MM-DD", value)
+		}
+		if c.Query("to") == "" {
+			to = time.Date(from.Year(), time.December, 31, 0, 0, 0, 0, time.UTC)
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(dateLayout, value); err != nil {
+			return report.Options{}, fmt.Errorf("invalid to date %q, use YYYY-MM-DD", value)
+		}
+		if c.Query("from") == "" {
+			from = time.Date(to.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
 		}
 	}
 
-	classTitleStyle, err := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Bold: true,
-		},
-	})
+	return report.NewOptions(from, to, granularity, grouping, h.reportCurrency)
+}
+
+// reportTable loads the revenue needed for the grouping of options.
+func (h *Handler) reportTable(options report.Options) (*report.Table, error) {
+	classRevenue, err := h.RevenueRepo.SumByClassAndMonth(options.From, options.To, options.Currency)
 	if err != nil {
-		log.Printf("Failed to create class title style: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 
-	titles := make([]string, 0, len(classes))
-	maxLength := 0
-	for _, class := range classes {
-		titles = append(titles, class.Title)
-		if len(class.Title) > maxLength {
-			maxLength = len(class.Title)
+	var classes []models.Class
+	var parameterRevenue []models.ParameterRevenue
+	switch options.Grouping {
+	case report.ByHierarchy:
+		if classes, err = h.ClassRepo.List(0, -1); err != nil {
+			return nil, err
+		}
+	case report.ByParameter:
+		if parameterRevenue, err = h.RevenueRepo.SumByParameterAndMonth(options.From, options.To, options.Currency); err != nil {
+			return nil, err
 		}
 	}
-	err = f.SetSheetCol("Sheet1", "A3", &titles)
+
+	counts, err := h.ServiceRepo.CountByStatus()
 	if err != nil {
-		log.Printf("Failed to set sheet column: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
-	// Set column widths for better appearance
-	err = f.SetColWidth("Sheet1", "A", "A", float64(maxLength))
-	if err != nil {
-		log.Printf("Failed to set column width: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+
+	table := report.Build(options, classes, classRevenue, parameterRevenue)
+	table.Summary = report.NewSummary(counts)
+	return table, nil
+}
+
+// writeOverridesSheet lists the approved services whose class rules were
+// overridden by an expert, together with the justification. The rows are
+// streamed in batches, however many services there are.
+func (h *Handler) writeOverridesSheet(f *excelize.File, headerStyle int) error {
+	const sheet = "Overrides"
+	if _, err := f.NewSheet(sheet); err != nil {
+		return err
 	}
-	err = f.SetCellStyle("Sheet1", "A3", "A"+strconv.Itoa(len(classes)+2), classTitleStyle)
+	stream, err := f.NewStreamWriter(sheet)
 	if err != nil {
-		log.Printf("Failed to set cell style: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 
-	customNumberFormat := `"$"#,##0.00`
-	financialStyle, err := f.NewStyle(&excelize.Style{
-		NumFmt:       164,
-		CustomNumFmt: &customNumberFormat,
-		Font: &excelize.Font{
-			Bold: false,
-			Size: 11,
-		},
-	})
-	if err != nil {
-		log.Printf("Failed to create financial style: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if err := stream.SetColWidth(1, 1, 12); err != nil {
+		return err
 	}
-
-	totalStyle, err := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Bold:  true,
-			Size:  11,
-			Color: "#FFFFFF",
-		},
-		Fill: excelize.Fill{
-			Type:    "pattern",
-			Color:   []string{"#FF5733"},
-			Pattern: 1,
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "right",
-			Vertical:   "center",
-		},
-	})
-	if err != nil {
-		log.Printf("Failed to create total style: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if err := stream.SetColWidth(2, 4, 30); err != nil {
+		return err
 	}
-	for i := range classes {
-		row := generateRandomMoneyRow(4)
-		err := f.SetSheetRow("Sheet1", "B"+strconv.Itoa(i+3), &row)
-		if err != nil {
-			log.Printf("Failed to set sheet row: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		f.SetCellFormula("Sheet1", "F"+strconv.Itoa(i+3), "SUM(B"+strconv.Itoa(i+3)+":E"+strconv.Itoa(i+3)+")")
-		f.SetCellStyle("Sheet1", "B"+strconv.Itoa(i+3), "E"+strconv.Itoa(i+3), financialStyle)
-		f.SetCellStyle("Sheet1", "F"+strconv.Itoa(i+3), "F"+strconv.Itoa(i+3), totalStyle)
+	if err := stream.SetColWidth(5, 5, 60); err != nil {
+		return err
 	}
 
-	_, err = f.WriteTo(c.Writer)
-	if err != nil {
-		log.Printf("Failed to write to response: %v", err)
+	header := []any{"Service ID", "Service", "Financial Class", "Approved At", "Justification"}
+	for i, title := range header {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: title}
 	}
-	c.Status(http.StatusOK)
-}
-
-func generateRandomMoneyRow(size int) []float64 {
-	type price struct {
-		Amount float64 `faker:"amount"`
+	if err := stream.SetRow("A1", header); err != nil {
+		return err
 	}
 
-	row := make([]float64, size+1)
-	for i := range row {
-		c := price{}
-		faker.FakeData(&c)
-		row[i] = c.Amount
+	row := 2
+	err = h.ServiceRepo.FindOverriddenInBatches(exportBatchSize, func(services []models.Service) error {
+		for _, service := range services {
+			classTitle := ""
+			if service.Class != nil {
+				classTitle = service.Class.Title
+			}
+			values := []any{service.ID, service.Title, classTitle, service.ApprovedAt.Format("2006-01-02 15:04"), service.OverrideJustification}
+			if err := stream.SetRow("A"+strconv.Itoa(row), values); err != nil {
+				return err
+			}
+			row++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-
-	return row
+	return stream.Flush()
 }
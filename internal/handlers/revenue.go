@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"backend/internal/importer"
+	"backend/internal/models"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type revenueRecordRequest struct {
+	ServiceID uint     `json:"service_id" binding:"required"`
+	Period    string   `json:"period" binding:"required" example:"2024-03"`
+	Amount    *float64 `json:"amount" binding:"required" example:"1250.50"`
+	// Currency defaults to the report currency.
+	Currency string `json:"currency,omitempty" example:"USD"`
+}
+
+type revenueImportResponse struct {
+	DryRun  bool                  `json:"dry_run"`
+	Total   int                   `json:"total"`
+	Valid   int                   `json:"valid"`
+	Invalid int                   `json:"invalid"`
+	Rows    []importer.RevenueRow `json:"rows"`
+}
+
+// CreateRevenue godoc
+//
+//	@Summary		Record revenue
+//	@Description	Stores monthly revenue of services. A record for the same service, month and currency is overwritten. Nothing is stored when a record is invalid.
+//	@Tags			Revenue
+//	@Accept			json
+//	@Produce		json
+//	@Param			records	body		[]revenueRecordRequest	true	"Revenue records"
+//	@Success		200		{array}		models.RevenueRecord
+//	@Failure		400		{object}	map[string]any	"Invalid input, see rows"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/revenue [post]
+func (h *Handler) CreateRevenue(c *gin.Context) {
+	var req []revenueRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d records per request", maxBatchSize)})
+		return
+	}
+
+	rows := make([]importer.RevenueRow, 0, len(req))
+	for i, record := range req {
+		row := importer.RevenueRow{Line: i, ServiceID: record.ServiceID, Amount: *record.Amount, Currency: h.reportCurrency}
+		var err error
+		if row.Period, err = importer.ParsePeriod(record.Period); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		if record.Currency != "" {
+			if row.Currency, err = importer.ParseCurrency(record.Currency); err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err := h.checkRevenueServices(rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	records := revenueRecords(rows)
+	if slices.ContainsFunc(rows, func(row importer.RevenueRow) bool { return len(row.Errors) > 0 }) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revenue records", "rows": rows})
+		return
+	}
+	if err := h.RevenueRepo.Upsert(records); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+// ImportRevenue godoc
+//
+//	@Summary		Import revenue from a spreadsheet
+//	@Description	Reads monthly revenue from the first sheet of an XLSX file or from a CSV file with the columns service_id, period (YYYY-MM), amount and optionally currency. Valid rows are stored unless dry_run is set, a record for the same service, month and currency is overwritten.
+//	@Tags			Revenue
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"XLSX or CSV file"
+//	@Param			dry_run	query		bool	false	"Only validate, store nothing"	default(false)
+//	@Success		200		{object}	revenueImportResponse
+//	@Failure		400		{object}	map[string]string	"Invalid file"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/revenue/import [post]
+func (h *Handler) ImportRevenue(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+	format, err := importer.DetectFormat(header.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	records, err := importer.ReadRecords(file, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file: " + err.Error()})
+		return
+	}
+	rows, err := importer.ParseRevenue(records, h.reportCurrency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.checkRevenueServices(rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	valid := 0
+	for _, row := range rows {
+		if len(row.Errors) == 0 {
+			valid++
+		}
+	}
+	if !dryRun {
+		if err := h.RevenueRepo.Upsert(revenueRecords(rows)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, revenueImportResponse{
+		DryRun:  dryRun,
+		Total:   len(rows),
+		Valid:   valid,
+		Invalid: len(rows) - valid,
+		Rows:    rows,
+	})
+}
+
+// ListRevenue godoc
+//
+//	@Summary		List revenue
+//	@Description	Lists monthly revenue records ordered by period.
+//	@Tags			Revenue
+//	@Produce		json
+//	@Param			service_id	query		int		false	"Service ID"
+//	@Param			from		query		string	false	"First day, YYYY-MM-DD"
+//	@Param			to			query		string	false	"Last day, YYYY-MM-DD"
+//	@Param			offset		query		int		false	"Offset"	default(0)
+//	@Param			limit		query		int		false	"Limit"		default(100)
+//	@Success		200			{array}		models.RevenueRecord
+//	@Failure		400			{object}	map[string]string	"Invalid query"
+//	@Failure		500			{object}	map[string]string	"Internal server error"
+//	@Router			/revenue [get]
+func (h *Handler) ListRevenue(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	from, to, err := parseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var serviceID *uint
+	if value := c.Query("service_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+			return
+		}
+		serviceID = new(uint)
+		*serviceID = uint(id)
+	}
+
+	records, err := h.RevenueRepo.List(serviceID, from, to, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+// checkRevenueServices adds an error to the rows whose service does not
+// exist.
+func (h *Handler) checkRevenueServices(rows []importer.RevenueRow) error {
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		if row.ServiceID != 0 {
+			ids = append(ids, row.ServiceID)
+		}
+	}
+	existing, err := h.ServiceRepo.ExistingIDs(ids)
+	if err != nil {
+		return err
+	}
+	found := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		found[id] = true
+	}
+	for i, row := range rows {
+		if row.ServiceID != 0 && !found[row.ServiceID] {
+			rows[i].Errors = append(rows[i].Errors, fmt.Sprintf("service %d not found", row.ServiceID))
+		}
+	}
+	return nil
+}
+
+// revenueRecords converts the rows without errors. A later row for the same
+// service, period and currency replaces an earlier one, as a second import
+// would.
+func revenueRecords(rows []importer.RevenueRow) []models.RevenueRecord {
+	type key struct {
+		serviceID uint
+		period    time.Time
+		currency  string
+	}
+	records := make([]models.RevenueRecord, 0, len(rows))
+	index := make(map[key]int, len(rows))
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			continue
+		}
+		record := models.RevenueRecord{
+			ServiceID: row.ServiceID,
+			Period:    row.Period,
+			Currency:  row.Currency,
+			Amount:    row.Amount,
+		}
+		k := key{row.ServiceID, row.Period, row.Currency}
+		if i, ok := index[k]; ok {
+			records[i] = record
+			continue
+		}
+		index[k] = len(records)
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"backend/internal/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// reviewStatuses are the statuses in which a service waits for an expert.
+var reviewStatuses = []models.ServiceStatus{models.ServiceNeedsReview, models.ServiceReopened}
+
+type reviewItemResponse struct {
+	ServiceID  uint                 `json:"service_id"`
+	Title      string               `json:"title"`
+	Status     models.ServiceStatus `json:"status" example:"needs_review"`
+	Parameters []models.Parameter   `json:"parameters"`
+	CreatedAt  time.Time            `json:"created_at"`
+	// Confidence is the probability of the top class of the latest
+	// prediction, empty when the model was not asked or failed.
+	Confidence   *float64             `json:"confidence" example:"0.54"`
+	ModelVersion string               `json:"model_version,omitempty"`
+	Suggestions  []suggestionResponse `json:"suggestions"`
+}
+
+type suggestionResponse struct {
+	ClassID     uint    `json:"class_id"`
+	Title       string  `json:"title"`
+	Probability float64 `json:"probability" example:"0.54"`
+}
+
+// ListReviewQueue godoc
+//
+//	@Summary		List services waiting for an expert
+//	@Description	Lists services that need review or were reopened, by the day they were created, oldest first, and by model confidence within the same day. Every service carries the top suggestions of the latest prediction that reach the review floor.
+//	@Tags			Services
+//	@Produce		json
+//	@Param			offset	query		int	false	"Offset"	default(0)
+//	@Param			limit	query		int	false	"Limit"		default(10)
+//	@Success		200		{array}		reviewItemResponse
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/review-queue [get]
+func (h *Handler) ListReviewQueue(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	services, err := h.ServiceRepo.ListForReview(offset, limit, reviewStatuses...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	classTitles := make(map[uint]string)
+	result := make([]reviewItemResponse, 0, len(services))
+	for _, service := range services {
+		item := reviewItemResponse{
+			ServiceID:   service.ID,
+			Title:       service.Title,
+			Status:      service.Status,
+			Parameters:  service.Parameters,
+			CreatedAt:   service.CreatedAt,
+			Suggestions: []suggestionResponse{},
+		}
+
+		predictions, err := h.ServicePredictionRepo.ListLatestByServiceID(service.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(predictions) > 0 {
+			item.Confidence = &predictions[0].Probability
+			item.ModelVersion = predictions[0].ModelVersion
+		}
+
+		for _, prediction := range h.policy.Suggest(predictions) {
+			title, ok := classTitles[prediction.ClassID]
+			if !ok {
+				class, err := h.ClassRepo.GetByID(prediction.ClassID)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				if err == nil {
+					title = class.Title
+				}
+				classTitles[prediction.ClassID] = title
+			}
+			item.Suggestions = append(item.Suggestions, suggestionResponse{
+				ClassID:     prediction.ClassID,
+				Title:       title,
+				Probability: prediction.Probability,
+			})
+		}
+
+		result = append(result, item)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
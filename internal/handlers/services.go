@@ -2,13 +2,14 @@ package handlers
 
 import (
 	"backend/internal/models"
-	"log"
+	"backend/internal/repositories"
+	"backend/internal/services"
+	"errors"
 	"log/slog"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,7 +28,7 @@ type NewService struct {
 //	@Produce		json
 //	@Param			service	body		NewService	true	"Service details"
 //	@Success		201		{object}	models.Service
-//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		400		{object}	map[string]any	"Invalid input or contradicting parameters, see violations"
 //	@Failure		500		{object}	map[string]string	"Internal server error"
 //	@Router			/services [post]
 func (h *Handler) CreateService(c *gin.Context) {
@@ -37,14 +38,9 @@ func (h *Handler) CreateService(c *gin.Context) {
 		return
 	}
 
-	params := make([]models.Parameter, 0, len(newService.Parameters))
-	for _, param := range newService.Parameters {
-		parameter, err := h.ParameterRepo.GetByID(param)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parameter"})
-			return
-		}
-		params = append(params, *parameter)
+	params, ok := h.lookupParameters(c, newService.Parameters)
+	if !ok {
+		return
 	}
 
 	service := &models.Service{
@@ -52,67 +48,15 @@ func (h *Handler) CreateService(c *gin.Context) {
 		Parameters: params,
 	}
 
-	invalidParameters, err := h.jenaService.ValidateService(c, service)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if len(invalidParameters) > 0 {
-		params := make([]string, 0, len(invalidParameters))
-		for _, paramID := range invalidParameters {
-			parameter, err := h.ParameterRepo.GetByID(paramID)
-			if err != nil {
-				log.Println("Invalid parameter:", err)
-				continue
-			}
-			params = append(params, parameter.Title)
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": "This service contain contradiction parameters: " + strings.Join(params, ", ")})
+	if ok := h.checkContradictions(c, service); !ok {
 		return
 	}
 
-	if err := h.ServiceRepo.Create(service); err != nil {
+	if err := h.ServiceService.CreateService(service); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	go func() {
-		predictions, err := h.callMLModel(service.Parameters)
-		if err != nil {
-			log.Println("Error calling ML model:", err)
-			return
-		}
-		if len(predictions) > 0 {
-			if predictions[0].Probability <= 0.7 {
-				slog.Warn("Low probability:", slog.Any("probability", predictions[0].Probability))
-			}
-
-			classID := uint(predictions[0].ClassID)
-			correct, err := h.jenaService.ValidateClass(c, service, classID)
-			if err != nil {
-				log.Println("Error validating class:", err)
-				return
-			}
-			if !correct {
-				log.Println("Invalid class")
-				return
-			}
-
-			class, err := h.ClassRepo.GetByID(classID)
-			if err != nil {
-				log.Println("Class not found:", err)
-				return
-			}
-
-			service.Class = class
-			err = h.ServiceRepo.Update(service)
-			if err != nil {
-				log.Println("Error updating service:", err)
-				return
-			}
-		}
-	}()
-
 	c.JSON(http.StatusCreated, service)
 }
 
@@ -122,16 +66,30 @@ func (h *Handler) CreateService(c *gin.Context) {
 //	@Description	Fetches a list of services with pagination.
 //	@Tags			Services
 //	@Produce		json
-//	@Param			offset	query		int	false	"Offset"	default(0)
-//	@Param			limit	query		int	false	"Limit"		default(10)
+//	@Param			offset	query		int		false	"Offset"	default(0)
+//	@Param			limit	query		int		false	"Limit"		default(10)
+//	@Param			status	query		string	false	"Comma-separated statuses: draft, awaiting_prediction, predicted, needs_review, approved, rejected, reopened"
 //	@Success		200		{array}		models.Service
+//	@Failure		400		{object}	map[string]string	"Invalid status"
 //	@Failure		500		{object}	map[string]string	"Internal server error"
 //	@Router			/services [get]
 func (h *Handler) ListServices(c *gin.Context) {
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 
-	services, err := h.ServiceRepo.List(offset, limit)
+	var statuses []models.ServiceStatus
+	if status := c.Query("status"); status != "" {
+		for _, value := range strings.Split(status, ",") {
+			status := models.ServiceStatus(strings.TrimSpace(value))
+			if !status.Valid() {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status " + string(status)})
+				return
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	services, err := h.ServiceRepo.List(offset, limit, statuses...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -143,7 +101,7 @@ func (h *Handler) ListServices(c *gin.Context) {
 // GetServiceByID godoc
 //
 //	@Summary		Get a service by ID
-//	@Description	Fetches the details of a service by its ID.
+//	@Description	Fetches the details of a service by its ID, including all model predictions, newest first.
 //	@Tags			Services
 //	@Produce		json
 //	@Param			id	path		int	true	"Service ID"
@@ -159,25 +117,138 @@ func (h *Handler) GetServiceByID(c *gin.Context) {
 		return
 	}
 
+	service.Predictions, err = h.ServicePredictionRepo.ListByServiceID(service.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, service)
+}
+
+// UpdateService godoc
+//
+//	@Summary		Update a service
+//	@Description	Changes the title and parameters of a service. Approved services have to be reopened first. A parameter change drops the suggested class and requests a new prediction.
+//	@Tags			Services
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"Service ID"
+//	@Param			service	body		NewService	true	"Service details"
+//	@Success		200		{object}	models.Service
+//	@Failure		400		{object}	map[string]any		"Invalid input, contradicting parameters or approved service"
+//	@Failure		404		{object}	map[string]string	"Service not found"
+//	@Failure		409		{object}	map[string]string	"Service was changed concurrently"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/services/{id} [put]
+func (h *Handler) UpdateService(c *gin.Context) {
+	serviceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return
+	}
+
+	var req NewService
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service, err := h.ServiceRepo.GetByID(uint(serviceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+	if service.Status == models.ServiceApproved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Approved services have to be reopened first"})
+		return
+	}
+
+	params, ok := h.lookupParameters(c, req.Parameters)
+	if !ok {
+		return
+	}
+	if ok := h.checkContradictions(c, &models.Service{Parameters: params}); !ok {
+		return
+	}
+
+	err = h.ServiceService.UpdateService(service, req.Title, params)
+	if errors.Is(err, repositories.ErrStatusConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Service was changed in the meantime, reload it"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, service)
 }
 
+// DeleteService godoc
+//
+//	@Summary		Delete a service
+//	@Description	Deletes a service and removes it from the knowledge base. Approved services have to be reopened first.
+//	@Tags			Services
+//	@Param			id	path	int	true	"Service ID"
+//	@Success		204	"Service deleted successfully"
+//	@Failure		400	{object}	map[string]string	"Invalid service ID or approved service"
+//	@Failure		404	{object}	map[string]string	"Service not found"
+//	@Failure		409	{object}	map[string]string	"Service was changed concurrently"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/services/{id} [delete]
+func (h *Handler) DeleteService(c *gin.Context) {
+	serviceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return
+	}
+
+	service, err := h.ServiceRepo.GetByID(uint(serviceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+
+	err = h.ServiceService.DeleteService(service)
+	if errors.Is(err, services.ErrServiceApproved) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Approved services have to be reopened first"})
+		return
+	}
+	if errors.Is(err, repositories.ErrStatusConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Service was changed in the meantime, reload it"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 type assignClassRequest struct {
 	ClassID *uint `json:"class_id,omitempty"`
+	// Override approves the service even though it violates the class
+	// rules. Justification is mandatory in that case.
+	Override      bool   `json:"override,omitempty"`
+	Justification string `json:"justification,omitempty"`
 }
 
 // ApproveService godoc
 //
 //	@Summary		Approve a service
 //	@Description	Approves a service by its ID. If a class ID is provided in the request body, it assigns the class to the service before approval.
+//	@Description	The class is validated against the service parameters. Violations reject the approval unless override is set together with a justification, which is stored on the service.
 //	@Tags			Services
 //	@Accept			json
 //	@Produce		json
 //	@Param			id		path		int					true	"Service ID"
 //	@Param			class	body		assignClassRequest	true	"Class ID"
 //	@Success		200		{object}	models.Service
-//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		400		{object}	map[string]any		"Invalid input, contradicting parameters or class rule violations, see violations"
 //	@Failure		404		{object}	map[string]string	"Service or class not found"
+//	@Failure		409		{object}	map[string]string	"Service was changed concurrently"
 //	@Failure		500		{object}	map[string]string	"Internal server error"
 //	@Router			/services/{id}/approve [post]
 func (h *Handler) ApproveService(c *gin.Context) {
@@ -192,6 +263,11 @@ func (h *Handler) ApproveService(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON body"})
 		return
 	}
+	req.Justification = strings.TrimSpace(req.Justification)
+	if req.Override && req.Justification == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Justification is required to override validation"})
+		return
+	}
 
 	service, err := h.ServiceRepo.GetByID(uint(serviceID))
 	if err != nil {
@@ -199,10 +275,19 @@ func (h *Handler) ApproveService(c *gin.Context) {
 		return
 	}
 
-	if service.ApprovedAt != nil {
+	if service.Status == models.ServiceApproved {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Service is already approved"})
 		return
 	}
+	if !services.CanTransition(service.Status, models.ServiceApproved) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Service cannot be approved in status " + string(service.Status)})
+		return
+	}
+
+	// The rules may have changed since the service was created.
+	if ok := h.checkContradictions(c, service); !ok {
+		return
+	}
 
 	if req.ClassID == nil && service.Class == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Class ID is required"})
@@ -218,16 +303,135 @@ func (h *Handler) ApproveService(c *gin.Context) {
 		}
 	}
 
-	service.Class = class
-	now := time.Now()
-	service.ApprovedAt = &now
+	violations, err := h.knowledgeBase.ValidateClass(c, service, class.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	justification := ""
+	if len(violations) > 0 {
+		if !req.Override {
+			described, err := h.describeViolations(violations)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":      "Service parameters do not fit the class " + class.Title,
+				"violations": described,
+			})
+			return
+		}
+		justification = req.Justification
+		slog.Warn("Class validation overridden", slog.Uint64("service", uint64(service.ID)), slog.Uint64("class", uint64(class.ID)), slog.Any("violations", violations))
+	}
 
-	if err := h.ServiceRepo.Update(service); err != nil {
+	err = h.ServiceService.ApproveService(service, class, justification)
+	if errors.Is(err, repositories.ErrStatusConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Service was changed in the meantime, reload it"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = h.jenaService.AddService(c, service)
+	c.JSON(http.StatusOK, service)
+}
+
+type rejectServiceRequest struct {
+	Reason string `json:"reason" example:"Duplicate of service 42"`
+}
+
+// RejectService godoc
+//
+//	@Summary		Reject a service
+//	@Description	Closes a service without a class. Approved services have to be reopened first.
+//	@Tags			Services
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Service ID"
+//	@Param			reason	body		rejectServiceRequest	false	"Rejection reason"
+//	@Success		200		{object}	models.Service
+//	@Failure		400		{object}	map[string]string	"Invalid input or status"
+//	@Failure		404		{object}	map[string]string	"Service not found"
+//	@Failure		409		{object}	map[string]string	"Service was changed concurrently"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/services/{id}/reject [post]
+func (h *Handler) RejectService(c *gin.Context) {
+	serviceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return
+	}
+
+	var req rejectServiceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON body"})
+			return
+		}
+	}
+
+	service, err := h.ServiceRepo.GetByID(uint(serviceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+
+	err = h.ServiceService.RejectService(service, strings.TrimSpace(req.Reason))
+	if errors.Is(err, services.ErrInvalidTransition) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Service cannot be rejected in status " + string(service.Status)})
+		return
+	}
+	if errors.Is(err, repositories.ErrStatusConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Service was changed in the meantime, reload it"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, service)
+}
+
+// ReopenService godoc
+//
+//	@Summary		Reopen a service
+//	@Description	Puts an approved or rejected service back under review. An approved service loses its approval and is removed from the knowledge base.
+//	@Tags			Services
+//	@Produce		json
+//	@Param			id	path		int	true	"Service ID"
+//	@Success		200	{object}	models.Service
+//	@Failure		400	{object}	map[string]string	"Invalid input or status"
+//	@Failure		404	{object}	map[string]string	"Service not found"
+//	@Failure		409	{object}	map[string]string	"Service was changed concurrently"
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/services/{id}/reopen [post]
+func (h *Handler) ReopenService(c *gin.Context) {
+	serviceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return
+	}
+
+	service, err := h.ServiceRepo.GetByID(uint(serviceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+
+	status := service.Status
+	err = h.ServiceService.ReopenService(service)
+	if errors.Is(err, services.ErrInvalidTransition) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Service cannot be reopened in status " + string(status)})
+		return
+	}
+	if errors.Is(err, repositories.ErrStatusConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Service was changed in the meantime, reload it"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -239,7 +443,7 @@ func (h *Handler) ApproveService(c *gin.Context) {
 // ListProposedClasses godoc
 //
 //	@Summary		List proposed classes for a service
-//	@Description	Fetches a list of proposed classes for a service based on similar parameters.
+//	@Description	Fetches a list of proposed classes for a service based on similar parameters. Classes forbidding any of the service parameters are left out, classes missing required parameters come last. The probability of the latest model prediction is added, classes suggested only by the model are listed without graph counts.
 //	@Tags			Services
 //	@Produce		json
 //	@Param			id	path		int	true	"Service ID"
@@ -261,36 +465,68 @@ func (h *Handler) ListProposedClasses(c *gin.Context) {
 		return
 	}
 
-	classes, err := h.jenaService.ProposedClasses(c, service)
+	classes, err := h.knowledgeBase.ProposedClasses(c, service)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	result := make([]proposedClassResponse, 0, len(classes))
-	for _, class := range classes {
-		resp := proposedClassResponse{}
+	predictions, err := h.ServicePredictionRepo.ListLatestByServiceID(service.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	predicted := make(map[uint]models.ServicePrediction, len(predictions))
+	for _, prediction := range predictions {
+		predicted[prediction.ClassID] = prediction
+	}
 
-		entityClass, err := h.ClassRepo.GetByID(class.ClassID)
+	result := make([]proposedClassResponse, 0, len(classes)+len(predictions))
+	add := func(resp proposedClassResponse) {
+		entityClass, err := h.ClassRepo.GetByID(resp.ClassID)
 		if err != nil {
 			slog.Error("Category not found:", slog.Any("error", err))
-			continue
+			return
 		}
-		resp.ClassID = entityClass.ID
 		resp.Title = entityClass.Title
-
-		resp.SimilarParameters = class.MatchingParameterNums
-		resp.SimilarServices = len(class.SimilarServices)
-
+		if prediction, ok := predicted[resp.ClassID]; ok {
+			resp.Probability = &prediction.Probability
+			resp.PredictionRank = prediction.Rank
+			resp.ModelVersion = prediction.ModelVersion
+			delete(predicted, resp.ClassID)
+		}
 		result = append(result, resp)
 	}
 
-	// sort result first by similar services and then by similar parameters
-	sort.Slice(result, func(i, j int) bool {
-		if result[i].SimilarServices == result[j].SimilarServices {
+	for _, class := range classes {
+		add(proposedClassResponse{
+			ClassID:           class.ClassID,
+			SimilarParameters: class.MatchingParameterNums,
+			SimilarServices:   len(class.SimilarServices),
+			MissingParameters: class.MissingParameters,
+		})
+	}
+	// Classes only the model suggested have no graph evidence.
+	for _, prediction := range predictions {
+		if _, ok := predicted[prediction.ClassID]; ok {
+			add(proposedClassResponse{ClassID: prediction.ClassID})
+		}
+	}
+
+	// classes missing required parameters go last, the rest is sorted first
+	// by similar services, then by similar parameters and then by the model
+	// probability
+	sort.SliceStable(result, func(i, j int) bool {
+		if len(result[i].MissingParameters) != len(result[j].MissingParameters) {
+			return len(result[i].MissingParameters) < len(result[j].MissingParameters)
+		}
+		if result[i].SimilarServices != result[j].SimilarServices {
+			return result[i].SimilarServices > result[j].SimilarServices
+		}
+		if result[i].SimilarParameters != result[j].SimilarParameters {
 			return result[i].SimilarParameters > result[j].SimilarParameters
 		}
-		return result[i].SimilarServices > result[j].SimilarServices
+		return probability(result[i]) > probability(result[j])
 	})
 
 	c.JSON(http.StatusOK, result)
@@ -301,4 +537,46 @@ type proposedClassResponse struct {
 	Title             string `json:"title"`
 	SimilarParameters int    `json:"similar_parameters"`
 	SimilarServices   int    `json:"similar_services"`
+	// MissingParameters are required by the class but absent on the service.
+	MissingParameters []string `json:"missing_parameters,omitempty"`
+	// Probability, PredictionRank and ModelVersion come from the latest model
+	// prediction and are empty when the model did not suggest the class.
+	Probability    *float64 `json:"probability,omitempty" example:"0.83"`
+	PredictionRank int      `json:"prediction_rank,omitempty" example:"1"`
+	ModelVersion   string   `json:"model_version,omitempty"`
+}
+
+func probability(resp proposedClassResponse) float64 {
+	if resp.Probability == nil {
+		return 0
+	}
+	return *resp.Probability
+}
+
+// checkContradictions writes a 400 response listing the violations when the
+// service parameters contradict each other.
+func (h *Handler) checkContradictions(c *gin.Context, service *models.Service) bool {
+	violations, err := h.knowledgeBase.ValidateService(c, service)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if len(violations) == 0 {
+		return true
+	}
+
+	described, err := h.describeViolations(violations)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	titles := make([]string, 0, len(described))
+	for _, violation := range described {
+		titles = append(titles, violation.ParameterTitle+" / "+violation.OtherParameterTitle)
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      "This service contain contradiction parameters: " + strings.Join(titles, ", "),
+		"violations": described,
+	})
+	return false
 }
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"backend/internal/knowledge_base"
+	"backend/internal/models"
+	"backend/internal/outbox"
+	"backend/internal/repositories"
+	"backend/internal/services"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// connPool lets gorm begin and commit transactions without a database. The
+// repositories are faked, so no statement ever reaches it.
+type connPool struct{}
+
+var errNoDatabase = errors.New("no database")
+
+func (connPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errNoDatabase
+}
+
+func (connPool) ExecContext(context.Context, string, ...any) (sql.Result, error) {
+	return nil, errNoDatabase
+}
+
+func (connPool) QueryContext(context.Context, string, ...any) (*sql.Rows, error) {
+	return nil, errNoDatabase
+}
+
+func (connPool) QueryRowContext(context.Context, string, ...any) *sql.Row {
+	return nil
+}
+
+func (connPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return &txPool{}, nil
+}
+
+type txPool struct{ connPool }
+
+func (*txPool) Commit() error   { return nil }
+func (*txPool) Rollback() error { return nil }
+
+// The fakes embed the repository interfaces and implement what approving
+// a service uses.
+
+type fakeServiceRepo struct {
+	repositories.ServiceRepository
+	services map[uint]models.Service
+}
+
+func (r *fakeServiceRepo) WithTx(*gorm.DB) repositories.ServiceRepository { return r }
+
+func (r *fakeServiceRepo) GetByID(id uint) (*models.Service, error) {
+	service, ok := r.services[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &service, nil
+}
+
+func (r *fakeServiceRepo) UpdateColumns(service *models.Service, _ ...string) error {
+	r.services[service.ID] = *service
+	return nil
+}
+
+func (r *fakeServiceRepo) UpdateColumnsFrom(service *models.Service, from models.ServiceStatus, _ ...string) error {
+	if r.services[service.ID].Status != from {
+		return repositories.ErrStatusConflict
+	}
+	r.services[service.ID] = *service
+	return nil
+}
+
+func (r *fakeServiceRepo) FindOverriddenInBatches(_ int, fn func([]models.Service) error) error {
+	var overridden []models.Service
+	for _, service := range r.services {
+		if service.Status == models.ServiceApproved && service.ApprovalOverridden {
+			overridden = append(overridden, service)
+		}
+	}
+	return fn(overridden)
+}
+
+type fakeClassRepo struct {
+	repositories.ClassRepository
+	classes map[uint]models.Class
+}
+
+func (r *fakeClassRepo) GetByID(id uint) (*models.Class, error) {
+	class, ok := r.classes[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &class, nil
+}
+
+type fakeParameterRepo struct {
+	repositories.ParameterRepository
+	parameters []models.Parameter
+}
+
+func (r *fakeParameterRepo) List(int, int) ([]models.Parameter, error) {
+	return r.parameters, nil
+}
+
+type fakeServicePredictionRepo struct {
+	repositories.ServicePredictionRepository
+}
+
+func (r *fakeServicePredictionRepo) WithTx(*gorm.DB) repositories.ServicePredictionRepository {
+	return r
+}
+
+func (r *fakeServicePredictionRepo) ListLatestByServiceID(uint) ([]models.ServicePrediction, error) {
+	return nil, nil
+}
+
+type fakeFeedbackRepo struct {
+	repositories.ApprovalFeedbackRepository
+	feedback []models.ApprovalFeedback
+}
+
+func (r *fakeFeedbackRepo) WithTx(*gorm.DB) repositories.ApprovalFeedbackRepository { return r }
+
+func (r *fakeFeedbackRepo) Create(feedback *models.ApprovalFeedback) error {
+	r.feedback = append(r.feedback, *feedback)
+	return nil
+}
+
+type fakeOutboxRepo struct {
+	repositories.OutboxRepository
+	events []models.OutboxEvent
+}
+
+func (r *fakeOutboxRepo) WithTx(*gorm.DB) repositories.OutboxRepository { return r }
+
+func (r *fakeOutboxRepo) Create(event *models.OutboxEvent) error {
+	r.events = append(r.events, *event)
+	return nil
+}
+
+type approvalFixture struct {
+	handler    *Handler
+	router     *gin.Engine
+	services   *fakeServiceRepo
+	outboxRepo *fakeOutboxRepo
+}
+
+// newApprovalFixture has service 3 with the parameters sms and voice, under
+// review, and class 1 that only allows sms.
+func newApprovalFixture(t *testing.T) *approvalFixture {
+	ctx := context.Background()
+	kb := knowledge_base.NewMemory()
+	require.NoError(t, kb.AddParameter(ctx, models.ParameterView{ID: "sms"}))
+	require.NoError(t, kb.AddParameter(ctx, models.ParameterView{ID: "voice"}))
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 1, Title: "Messaging", AllowedParameters: []string{"sms"}}))
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: connPool{}}), &gorm.Config{})
+	require.NoError(t, err)
+
+	parameters := []models.Parameter{{ID: "sms", Title: "SMS"}, {ID: "voice", Title: "Voice"}}
+	serviceRepo := &fakeServiceRepo{services: map[uint]models.Service{
+		3: {ID: 3, Title: "Tariff S", Parameters: parameters, Status: models.ServiceNeedsReview},
+	}}
+	outboxRepo := &fakeOutboxRepo{}
+	serviceService := services.NewServiceService(db, serviceRepo, &fakeServicePredictionRepo{}, &fakeFeedbackRepo{}, nil, outbox.New(outboxRepo), nil)
+
+	h := &Handler{
+		ServiceRepo:    serviceRepo,
+		ClassRepo:      &fakeClassRepo{classes: map[uint]models.Class{1: {ID: 1, Title: "Messaging"}}},
+		ParameterRepo:  &fakeParameterRepo{parameters: parameters},
+		ServiceService: serviceService,
+		knowledgeBase:  kb,
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/services/:id/approve", h.ApproveService)
+
+	return &approvalFixture{handler: h, router: router, services: serviceRepo, outboxRepo: outboxRepo}
+}
+
+func (f *approvalFixture) approve(body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/services/3/approve", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestApproveServiceOverride(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		wantCode       int
+		wantError      string
+		wantViolations []violationResponse
+	}{
+		{
+			name:      "Violations reject the approval",
+			body:      `{"class_id": 1}`,
+			wantCode:  http.StatusBadRequest,
+			wantError: "Service parameters do not fit the class Messaging",
+			wantViolations: []violationResponse{{
+				Rule: knowledge_base.RuleNotAllowed, ParameterID: "voice", ParameterTitle: "Voice",
+				ClassID: 1, ClassTitle: "Messaging", Message: `"Voice" is not allowed in class "Messaging"`,
+			}},
+		},
+		{
+			name:      "Override without justification",
+			body:      `{"class_id": 1, "override": true, "justification": "  "}`,
+			wantCode:  http.StatusBadRequest,
+			wantError: "Justification is required to override validation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newApprovalFixture(t)
+
+			w := f.approve(tt.body)
+			require.Equal(t, tt.wantCode, w.Code, w.Body.String())
+			var resp struct {
+				Error      string              `json:"error"`
+				Violations []violationResponse `json:"violations"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantError, resp.Error)
+			assert.Equal(t, tt.wantViolations, resp.Violations)
+
+			service := f.services.services[3]
+			assert.Equal(t, models.ServiceNeedsReview, service.Status)
+			assert.False(t, service.ApprovalOverridden)
+			assert.Empty(t, f.outboxRepo.events)
+		})
+	}
+}
+
+func TestApproveServiceOverrideJustification(t *testing.T) {
+	f := newApprovalFixture(t)
+
+	w := f.approve(`{"class_id": 1, "override": true, "justification": " Legacy tariff, agreed with finance "}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	service := f.services.services[3]
+	assert.Equal(t, models.ServiceApproved, service.Status)
+	assert.True(t, service.ApprovalOverridden)
+	assert.Equal(t, "Legacy tariff, agreed with finance", service.OverrideJustification)
+	require.NotNil(t, service.ApprovedAt)
+	require.Len(t, f.outboxRepo.events, 1)
+	assert.Equal(t, string(outbox.AddService), f.outboxRepo.events[0].Operation)
+
+	file := excelize.NewFile()
+	defer file.Close()
+	require.NoError(t, f.handler.writeOverridesSheet(file, 0))
+	rows, err := file.GetRows("Overrides")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Service ID", "Service", "Financial Class", "Approved At", "Justification"},
+		{"3", "Tariff S", "Messaging", service.ApprovedAt.Format("2006-01-02 15:04"), "Legacy tariff, agreed with finance"},
+	}, rows)
+}
+
+func TestApproveServiceStatusConflict(t *testing.T) {
+	f := newApprovalFixture(t)
+	service := f.services.services[3]
+	loaded := service
+	f.handler.ServiceRepo = &staleServiceRepo{fakeServiceRepo: f.services, stale: loaded}
+	service.Status = models.ServiceRejected
+	f.services.services[3] = service
+
+	w := f.approve(`{"class_id": 1, "override": true, "justification": "Agreed with finance"}`)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+	assert.Equal(t, models.ServiceRejected, f.services.services[3].Status)
+	assert.Empty(t, f.outboxRepo.events)
+}
+
+// staleServiceRepo returns the service as it was loaded before another
+// request changed its status.
+type staleServiceRepo struct {
+	*fakeServiceRepo
+	stale models.Service
+}
+
+func (r *staleServiceRepo) GetByID(uint) (*models.Service, error) {
+	service := r.stale
+	return &service, nil
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"backend/internal/classifier"
+	"backend/internal/models"
+	"backend/internal/prediction"
+	"backend/internal/retrain"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type trainingRow struct {
+	ServiceID     uint                `json:"service_id"`
+	ClassID       uint                `json:"class_id"`
+	SchemaVersion string              `json:"schema_version"`
+	Features      classifier.Features `json:"features"`
+}
+
+// ExportTrainingData godoc
+//
+//	@Summary		Export training data
+//	@Description	Exports approved services as the feature vector sent to the model, one row per service with its approved class. The parameter-schema version identifies the feature set and is also returned in the X-Schema-Version header.
+//	@Tags			Model
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Param			format	query		string	false	"csv or jsonl"	default(csv)
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	map[string]string	"Unknown format"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/ml/training-data [get]
+func (h *Handler) ExportTrainingData(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown format " + format})
+		return
+	}
+
+	supportedParams, err := h.ParameterRepo.ListSupportedParameters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	slices.Sort(supportedParams)
+	schemaVersion := classifier.SchemaVersion(supportedParams)
+
+	services, err := h.ServiceRepo.ListApproved()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header(classifier.SchemaHeader, schemaVersion)
+	c.Header("Access-Control-Expose-Headers", "*")
+
+	if format == "jsonl" {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", "attachment; filename=training_data.jsonl")
+		encoder := json.NewEncoder(c.Writer)
+		for _, service := range services {
+			if service.ClassID == nil {
+				continue
+			}
+			err := encoder.Encode(trainingRow{
+				ServiceID:     service.ID,
+				ClassID:       *service.ClassID,
+				SchemaVersion: schemaVersion,
+				Features:      classifier.BuildFeatures(supportedParams, service.Parameters),
+			})
+			if err != nil {
+				_ = c.Error(err)
+				return
+			}
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=training_data.csv")
+	writer := csv.NewWriter(c.Writer)
+	header := append([]string{"service_id", "class_id", "schema_version"}, supportedParams...)
+	if err := writer.Write(header); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	for _, service := range services {
+		if service.ClassID == nil {
+			continue
+		}
+		features := classifier.BuildFeatures(supportedParams, service.Parameters)
+		record := make([]string, 0, len(header))
+		record = append(record, strconv.FormatUint(uint64(service.ID), 10), strconv.FormatUint(uint64(*service.ClassID), 10), schemaVersion)
+		for _, parameter := range supportedParams {
+			record = append(record, strconv.Itoa(features[parameter]))
+		}
+		if err := writer.Write(record); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+type schemaResponse struct {
+	Current models.FeatureSchema   `json:"current"`
+	Known   []models.FeatureSchema `json:"known"`
+}
+
+// GetFeatureSchema godoc
+//
+//	@Summary		Get the feature schema
+//	@Description	Returns the feature schema built from the supported parameters and all schemas that were sent to the model. The version is sent with every prediction request in the X-Schema-Version header, a model reporting another version gets no auto-assigned classes.
+//	@Tags			Model
+//	@Produce		json
+//	@Success		200	{object}	schemaResponse
+//	@Failure		500	{object}	map[string]string	"Internal server error"
+//	@Router			/ml/schema [get]
+func (h *Handler) GetFeatureSchema(c *gin.Context) {
+	supportedParams, err := h.ParameterRepo.ListSupportedParameters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	current, err := prediction.RegisterSchema(h.SchemaRepo, supportedParams)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	known, err := h.SchemaRepo.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schemaResponse{Current: *current, Known: known})
+}
+
+// TriggerRetraining godoc
+//
+//	@Summary		Trigger model retraining
+//	@Description	Notifies the model service that it should retrain. Class and parameter changes trigger it automatically.
+//	@Tags			Model
+//	@Produce		json
+//	@Success		202	{object}	map[string]string	"Retraining requested"
+//	@Failure		503	{object}	map[string]string	"No model service configured"
+//	@Router			/ml/retrain [post]
+func (h *Handler) TriggerRetraining(c *gin.Context) {
+	if !h.retrain.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No model service configured"})
+		return
+	}
+
+	h.retrain.Notify(retrain.Manual)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Retraining requested"})
+}
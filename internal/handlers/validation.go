@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"backend/internal/knowledge_base"
+	"backend/internal/models"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type validateServiceRequest struct {
+	Parameters []string `json:"parameters" binding:"required,dive,required"`
+	// ClassID additionally checks the parameters against the class rules.
+	ClassID *uint `json:"class_id,omitempty"`
+}
+
+type validationResponse struct {
+	Valid      bool                `json:"valid"`
+	Violations []violationResponse `json:"violations"`
+}
+
+type violationResponse struct {
+	Rule                knowledge_base.Rule `json:"rule" example:"contradiction"`
+	ParameterID         string              `json:"parameter_id" example:"mob_inet"`
+	ParameterTitle      string              `json:"parameter_title" example:"Mobile Internet"`
+	OtherParameterID    string              `json:"other_parameter_id,omitempty" example:"fix_inet"`
+	OtherParameterTitle string              `json:"other_parameter_title,omitempty" example:"Fixed Internet"`
+	ClassID             uint                `json:"class_id,omitempty"`
+	ClassTitle          string              `json:"class_title,omitempty"`
+	Message             string              `json:"message" example:"\"Mobile Internet\" contradicts \"Fixed Internet\""`
+}
+
+// ValidateService godoc
+//
+//	@Summary		Validate service parameters
+//	@Description	Dry-run of the service checks. Reports contradicting parameters and, when a class ID is given, parameters the class does not allow, forbids or requires. Nothing is stored.
+//	@Tags			Services
+//	@Accept			json
+//	@Produce		json
+//	@Param			service	body		validateServiceRequest	true	"Parameters and optional class"
+//	@Success		200		{object}	validationResponse
+//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		404		{object}	map[string]string	"Class not found"
+//	@Failure		500		{object}	map[string]string	"Internal server error"
+//	@Router			/services/validate [post]
+func (h *Handler) ValidateService(c *gin.Context) {
+	var req validateServiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	params, ok := h.lookupParameters(c, req.Parameters)
+	if !ok {
+		return
+	}
+	service := &models.Service{Parameters: params}
+
+	violations, err := h.knowledgeBase.ValidateService(c, service)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ClassID != nil {
+		if _, err := h.ClassRepo.GetByID(*req.ClassID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Class not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		classViolations, err := h.knowledgeBase.ValidateClass(c, service, *req.ClassID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		violations = append(violations, classViolations...)
+	}
+
+	described, err := h.describeViolations(violations)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, validationResponse{Valid: len(described) == 0, Violations: described})
+}
+
+// lookupParameters loads the parameters by ID and writes a 400 response when
+// one of them does not exist.
+func (h *Handler) lookupParameters(c *gin.Context, ids []string) ([]models.Parameter, bool) {
+	params := make([]models.Parameter, 0, len(ids))
+	for _, id := range ids {
+		parameter, err := h.ParameterRepo.GetByID(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parameter"})
+			return nil, false
+		}
+		params = append(params, *parameter)
+	}
+	return params, true
+}
+
+// describeViolations adds parameter and class titles and a readable message
+// to every violation.
+func (h *Handler) describeViolations(violations []knowledge_base.Violation) ([]violationResponse, error) {
+	described := make([]violationResponse, 0, len(violations))
+	if len(violations) == 0 {
+		return described, nil
+	}
+
+	parameters, err := h.ParameterRepo.List(0, -1)
+	if err != nil {
+		return nil, err
+	}
+	parameterTitles := make(map[string]string, len(parameters))
+	for _, parameter := range parameters {
+		parameterTitles[parameter.ID] = parameter.Title
+	}
+	classTitles := make(map[uint]string)
+
+	title := func(id string) string {
+		if title, ok := parameterTitles[id]; ok && title != "" {
+			return title
+		}
+		return id
+	}
+
+	for _, violation := range violations {
+		resp := violationResponse{
+			Rule:           violation.Rule,
+			ParameterID:    violation.ParameterID,
+			ParameterTitle: title(violation.ParameterID),
+			ClassID:        violation.ClassID,
+		}
+		if violation.OtherParameterID != "" {
+			resp.OtherParameterID = violation.OtherParameterID
+			resp.OtherParameterTitle = title(violation.OtherParameterID)
+		}
+		if violation.ClassID != 0 {
+			classTitle, ok := classTitles[violation.ClassID]
+			if !ok {
+				class, err := h.ClassRepo.GetByID(violation.ClassID)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, err
+				}
+				classTitle = fmt.Sprintf("class_%d", violation.ClassID)
+				if err == nil {
+					classTitle = class.Title
+				}
+				classTitles[violation.ClassID] = classTitle
+			}
+			resp.ClassTitle = classTitle
+		}
+
+		switch violation.Rule {
+		case knowledge_base.RuleContradiction:
+			resp.Message = fmt.Sprintf("%q contradicts %q", resp.ParameterTitle, resp.OtherParameterTitle)
+		case knowledge_base.RuleNotAllowed:
+			resp.Message = fmt.Sprintf("%q is not allowed in class %q", resp.ParameterTitle, resp.ClassTitle)
+		case knowledge_base.RuleMissingRequired:
+			resp.Message = fmt.Sprintf("Class %q requires %q", resp.ClassTitle, resp.ParameterTitle)
+		case knowledge_base.RuleForbidden:
+			resp.Message = fmt.Sprintf("%q is forbidden in class %q", resp.ParameterTitle, resp.ClassTitle)
+		}
+
+		described = append(described, resp)
+	}
+
+	return described, nil
+}
@@ -0,0 +1,236 @@
+// Package importer reads service catalogues from spreadsheets.
+//
+// The first row is the header. One column holds the service title, the
+// others describe parameters in one of two ways: a column named after a
+// parameter holds a 0/1 flag, a "parameters" column holds a list of
+// parameter IDs.
+package importer
+
+import (
+	"backend/internal/models"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// DetectFormat picks the format from the file name.
+func DetectFormat(filename string) (Format, error) {
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".csv":
+		return CSV, nil
+	case ".xlsx":
+		return XLSX, nil
+	default:
+		return "", fmt.Errorf("unsupported file type %q, use .xlsx or .csv", ext)
+	}
+}
+
+// ReadRecords returns the rows of a CSV file or of the first sheet of an
+// XLSX file. CSV files may be separated by commas or semicolons.
+func ReadRecords(r io.Reader, format Format) ([][]string, error) {
+	switch format {
+	case XLSX:
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return f.GetRows(f.GetSheetName(0))
+	case CSV:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.Comma = separator(data)
+		return reader.ReadAll()
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// separator guesses the CSV separator from the header line.
+func separator(data []byte) rune {
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		return ';'
+	}
+	return ','
+}
+
+// Headers that are not parameters.
+var (
+	titleHeaders     = []string{"title", "service", "name"}
+	parameterHeaders = []string{"parameters", "parameter_ids"}
+)
+
+// ErrNoTitleColumn is returned when no header names the title column.
+var ErrNoTitleColumn = errors.New("no title column, name it title, service or name")
+
+// Row is one service read from the file.
+type Row struct {
+	// Line is the 1-based row number in the file, the header is line 1.
+	Line       int      `json:"line"`
+	Title      string   `json:"title"`
+	Parameters []string `json:"parameters"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// Sheet is the parsed file.
+type Sheet struct {
+	// Columns maps parameter columns to parameter IDs.
+	Columns map[string]string `json:"columns"`
+	// UnknownColumns are headers that matched no parameter and were ignored.
+	UnknownColumns []string `json:"unknown_columns,omitempty"`
+	Rows           []Row    `json:"rows"`
+}
+
+// Parse maps the header to parameters and reads the rows. A header matches
+// a parameter by mapping, by ID or by title, ignoring case. Empty rows are
+// skipped.
+func Parse(records [][]string, parameters []models.Parameter, mapping map[string]string) (*Sheet, error) {
+	if len(records) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	known := make(map[string]string, 2*len(parameters))
+	for _, parameter := range parameters {
+		if parameter.Title != "" {
+			known[normalize(parameter.Title)] = parameter.ID
+		}
+	}
+	for _, parameter := range parameters {
+		known[normalize(parameter.ID)] = parameter.ID
+	}
+
+	sheet := &Sheet{Columns: make(map[string]string)}
+	titleColumn, listColumn := -1, -1
+	flagColumns := make(map[int]string)
+	for i, header := range records[0] {
+		name := normalize(header)
+		if name == "" {
+			continue
+		}
+		if id, ok := mapping[strings.TrimSpace(header)]; ok {
+			if _, ok := known[normalize(id)]; !ok {
+				return nil, fmt.Errorf("column %q is mapped to unknown parameter %q", header, id)
+			}
+			flagColumns[i] = known[normalize(id)]
+			sheet.Columns[header] = flagColumns[i]
+			continue
+		}
+		switch {
+		case titleColumn < 0 && slices.Contains(titleHeaders, name):
+			titleColumn = i
+		case listColumn < 0 && slices.Contains(parameterHeaders, name):
+			listColumn = i
+		default:
+			id, ok := known[name]
+			if !ok {
+				sheet.UnknownColumns = append(sheet.UnknownColumns, header)
+				continue
+			}
+			flagColumns[i] = id
+			sheet.Columns[header] = id
+		}
+	}
+	if titleColumn < 0 {
+		return nil, ErrNoTitleColumn
+	}
+
+	for n, record := range records[1:] {
+		if blank(record) {
+			continue
+		}
+		row := Row{Line: n + 2, Title: strings.TrimSpace(cell(record, titleColumn)), Parameters: []string{}}
+		if row.Title == "" {
+			row.Errors = append(row.Errors, "title is empty")
+		}
+
+		if listColumn >= 0 {
+			for _, value := range strings.FieldsFunc(cell(record, listColumn), isListSeparator) {
+				id, ok := known[normalize(value)]
+				if !ok {
+					row.Errors = append(row.Errors, fmt.Sprintf("unknown parameter %q", strings.TrimSpace(value)))
+					continue
+				}
+				row.Parameters = append(row.Parameters, id)
+			}
+		}
+
+		for i := 0; i < len(records[0]); i++ {
+			id, ok := flagColumns[i]
+			if !ok {
+				continue
+			}
+			set, err := parseFlag(cell(record, i))
+			if err != nil {
+				row.Errors = append(row.Errors, fmt.Sprintf("column %q: %v", records[0][i], err))
+				continue
+			}
+			if set {
+				row.Parameters = append(row.Parameters, id)
+			}
+		}
+
+		slices.Sort(row.Parameters)
+		row.Parameters = slices.Compact(row.Parameters)
+		if len(row.Parameters) == 0 && len(row.Errors) == 0 {
+			row.Errors = append(row.Errors, "no parameters")
+		}
+		sheet.Rows = append(sheet.Rows, row)
+	}
+
+	return sheet, nil
+}
+
+func parseFlag(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "x", "+", "yes", "true":
+		return true, nil
+	case "", "0", "-", "no", "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%q is not a 0/1 flag", value)
+	}
+}
+
+func isListSeparator(r rune) bool {
+	return r == ',' || r == ';' || r == '\n'
+}
+
+func normalize(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+func cell(record []string, i int) string {
+	if i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+func blank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,160 @@
+package importer
+
+import (
+	"backend/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var parameters = []models.Parameter{
+	{ID: "mob_inet", Title: "Mobile Internet"},
+	{ID: "fix_inet", Title: "Fixed Internet"},
+	{ID: "roaming", Title: "Roaming"},
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		records [][]string
+		mapping map[string]string
+		want    *Sheet
+		wantErr bool
+	}{
+		{
+			name: "flags by id and title",
+			records: [][]string{
+				{"Title", "mob_inet", "Fixed Internet", "Comment"},
+				{"Home", "0", "1", "x"},
+				{"Travel", "1", "", ""},
+				{"", "", "", ""},
+				{"Broken", "2", "1", ""},
+			},
+			want: &Sheet{
+				Columns:        map[string]string{"mob_inet": "mob_inet", "Fixed Internet": "fix_inet"},
+				UnknownColumns: []string{"Comment"},
+				Rows: []Row{
+					{Line: 2, Title: "Home", Parameters: []string{"fix_inet"}},
+					{Line: 3, Title: "Travel", Parameters: []string{"mob_inet"}},
+					{Line: 5, Title: "Broken", Parameters: []string{"fix_inet"}, Errors: []string{`column "mob_inet": "2" is not a 0/1 flag`}},
+				},
+			},
+		},
+		{
+			name: "parameter list",
+			records: [][]string{
+				{"service", "parameters"},
+				{"Travel", "roaming; Mobile Internet"},
+				{"Unknown", "roaming, iot"},
+				{"Empty", ""},
+			},
+			want: &Sheet{
+				Columns: map[string]string{},
+				Rows: []Row{
+					{Line: 2, Title: "Travel", Parameters: []string{"mob_inet", "roaming"}},
+					{Line: 3, Title: "Unknown", Parameters: []string{"roaming"}, Errors: []string{`unknown parameter "iot"`}},
+					{Line: 4, Title: "Empty", Parameters: []string{}, Errors: []string{"no parameters"}},
+				},
+			},
+		},
+		{
+			name: "mapping",
+			records: [][]string{
+				{"Name", "Roam"},
+				{"Travel", "1"},
+			},
+			mapping: map[string]string{"Roam": "roaming"},
+			want: &Sheet{
+				Columns: map[string]string{"Roam": "roaming"},
+				Rows:    []Row{{Line: 2, Title: "Travel", Parameters: []string{"roaming"}}},
+			},
+		},
+		{
+			name:    "mapping to unknown parameter",
+			records: [][]string{{"Title", "Roam"}},
+			mapping: map[string]string{"Roam": "iot"},
+			wantErr: true,
+		},
+		{
+			name:    "no title column",
+			records: [][]string{{"mob_inet"}, {"1"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.records, parameters, tt.mapping)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadRecordsCSV(t *testing.T) {
+	records, err := ReadRecords(strings.NewReader("\xef\xbb\xbftitle;parameters\nHome;\"fix_inet,mob_inet\"\n"), CSV)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"title", "parameters"}, {"Home", "fix_inet,mob_inet"}}, records)
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr string
+	}{
+		{value: "99", want: 99},
+		{value: "1.234", want: 1.234},
+		{value: "1 250,50", want: 1250.5},
+		{value: "12,5", want: 12.5},
+		{value: "1,234.50", want: 1234.5},
+		{value: "1.234,50", want: 1234.5},
+		{value: "1,234,567.8", want: 1234567.8},
+		{value: "1,234", wantErr: `"1,234" is an ambiguous amount, use a decimal point`},
+		{value: "1,234,567", wantErr: `"1,234,567" is an ambiguous amount, use a decimal point`},
+		{value: "1.234,567", wantErr: `"1.234,567" is an ambiguous amount, use a decimal point`},
+		{value: "-", wantErr: `"-" is not an amount`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseAmount(tt.value)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseRevenue(t *testing.T) {
+	rows, err := ParseRevenue([][]string{
+		{"Service_ID", "Period", "Amount", "Currency"},
+		{"1", "2024-03", "1 250,50", ""},
+		{"2", "2024-05-17", "99", "eur"},
+		{"x", "March", "-", "euro"},
+	}, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, []RevenueRow{
+		{Line: 2, ServiceID: 1, Period: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 1250.5, Currency: "USD"},
+		{Line: 3, ServiceID: 2, Period: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Amount: 99, Currency: "EUR"},
+		{Line: 4, Currency: "USD", Errors: []string{
+			`"x" is not a service ID`,
+			`"March" is not a period, use YYYY-MM`,
+			`"-" is not an amount`,
+			`"euro" is not a currency code`,
+		}},
+	}, rows)
+
+	_, err = ParseRevenue([][]string{{"service_id", "amount"}}, "USD")
+	assert.ErrorIs(t, err, ErrRevenueColumns)
+}
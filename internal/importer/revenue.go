@@ -0,0 +1,127 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RevenueRow is one revenue record read from the file. Period is the first
+// day of the month the revenue was earned in.
+type RevenueRow struct {
+	Line      int       `json:"line"`
+	ServiceID uint      `json:"service_id"`
+	Period    time.Time `json:"period"`
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
+	Errors    []string  `json:"errors,omitempty"`
+}
+
+// periodLayouts are the accepted period formats, days are dropped.
+var periodLayouts = []string{"2006-01", "2006-01-02", "2006/01/02", "02.01.2006", "01-02-06", "2006-01-02T15:04:05Z07:00"}
+
+// ParsePeriod returns the first day of the month of value.
+func ParsePeriod(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range periodLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a period, use YYYY-MM", value)
+}
+
+// ParseCurrency checks for a three letter ISO 4217 code.
+func ParseCurrency(value string) (string, error) {
+	currency := strings.ToUpper(strings.TrimSpace(value))
+	if len(currency) != 3 || strings.IndexFunc(currency, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+		return "", fmt.Errorf("%q is not a currency code", value)
+	}
+	return currency, nil
+}
+
+// ParseAmount reads a number with spaces as thousands separator and a
+// decimal point or comma. A comma is only taken as the decimal separator when
+// it is the last separator and followed by one or two digits, "1,234" could
+// mean either and is rejected.
+func ParseAmount(value string) (float64, error) {
+	amount := strings.NewReplacer(" ", "", "\u00a0", "").Replace(strings.TrimSpace(value))
+	if comma := strings.LastIndexByte(amount, ','); comma >= 0 {
+		digits := len(amount) - comma - 1
+		switch {
+		case comma < strings.LastIndexByte(amount, '.'):
+			amount = strings.ReplaceAll(amount, ",", "")
+		case digits >= 1 && digits <= 2 && strings.Count(amount, ",") == 1:
+			amount = strings.ReplaceAll(amount[:comma], ".", "") + "." + amount[comma+1:]
+		default:
+			return 0, fmt.Errorf("%q is an ambiguous amount, use a decimal point", value)
+		}
+	}
+	number, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an amount", value)
+	}
+	return number, nil
+}
+
+// ErrRevenueColumns is returned when a required revenue column is missing.
+var ErrRevenueColumns = errors.New("revenue needs service_id, period and amount columns")
+
+// ParseRevenue reads revenue records with the columns service_id, period,
+// amount and an optional currency, which defaults to currency. Amounts are
+// read by ParseAmount.
+func ParseRevenue(records [][]string, currency string) ([]RevenueRow, error) {
+	if len(records) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	columns := map[string]int{}
+	for i, header := range records[0] {
+		name := normalize(header)
+		if _, ok := columns[name]; !ok && slices.Contains([]string{"service_id", "period", "amount", "currency"}, name) {
+			columns[name] = i
+		}
+	}
+	for _, name := range []string{"service_id", "period", "amount"} {
+		if _, ok := columns[name]; !ok {
+			return nil, ErrRevenueColumns
+		}
+	}
+
+	var rows []RevenueRow
+	for n, record := range records[1:] {
+		if blank(record) {
+			continue
+		}
+		row := RevenueRow{Line: n + 2, Currency: currency}
+
+		id, err := strconv.ParseUint(strings.TrimSpace(cell(record, columns["service_id"])), 10, 0)
+		if err != nil || id == 0 {
+			row.Errors = append(row.Errors, fmt.Sprintf("%q is not a service ID", cell(record, columns["service_id"])))
+		}
+		row.ServiceID = uint(id)
+
+		if row.Period, err = ParsePeriod(cell(record, columns["period"])); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+
+		if row.Amount, err = ParseAmount(cell(record, columns["amount"])); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+
+		if i, ok := columns["currency"]; ok && strings.TrimSpace(cell(record, i)) != "" {
+			currency, err := ParseCurrency(cell(record, i))
+			if err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			} else {
+				row.Currency = currency
+			}
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
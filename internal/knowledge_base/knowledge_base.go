@@ -0,0 +1,156 @@
+package knowledge_base
+
+import (
+	"backend/internal/models"
+	"backend/internal/sparql"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// KnowledgeBase is the ontology store that keeps classes, parameters and
+// approved services together with the rules between them.
+type KnowledgeBase interface {
+	AddClass(ctx context.Context, class models.ClassView) error
+	UpdateClass(ctx context.Context, class models.ClassView) error
+	DeleteClass(ctx context.Context, id uint) error
+	// GetClassConstraints returns the class rules including the ones
+	// inherited from its ancestors.
+	GetClassConstraints(ctx context.Context, classID uint) (ClassConstraints, error)
+	// GetClassRules returns only the rules linked to the class itself.
+	GetClassRules(ctx context.Context, classID uint) (ClassConstraints, error)
+
+	AddParameter(ctx context.Context, parameter models.ParameterView) error
+	UpdateParameter(ctx context.Context, parameter models.ParameterView) error
+	DeleteParameter(ctx context.Context, parameterID string) error
+	GetParameterConstraints(ctx context.Context, parameterID string) ([]uint, []string, error)
+
+	AddService(ctx context.Context, service *models.Service) error
+	DeleteService(ctx context.Context, id uint) error
+	ProposedClasses(ctx context.Context, service *models.Service) ([]ProposedClass, error)
+	// ValidateClass and ValidateService return the broken rules, an empty
+	// result means the service is valid.
+	ValidateClass(ctx context.Context, service *models.Service, chosenClass uint) ([]Violation, error)
+	ValidateService(ctx context.Context, service *models.Service) ([]Violation, error)
+
+	// ListClasses, ListParameters and ListServices dump the graph content.
+	// Services only carry their ID, class ID and parameter IDs.
+	ListClasses(ctx context.Context) ([]models.ClassView, error)
+	ListParameters(ctx context.Context) ([]string, error)
+	ListServices(ctx context.Context) ([]models.Service, error)
+}
+
+type ProposedClass struct {
+	ClassID               uint
+	MatchingParameterNums int
+	SimilarServices       []uint
+	// MissingParameters are required by the class but absent on the service.
+	MissingParameters []string
+}
+
+type ClassConstraints struct {
+	Allowed   []string
+	Required  []string
+	Forbidden []string
+}
+
+// Missing returns the required parameters that are absent.
+func (c ClassConstraints) Missing(parameters []string) []string {
+	present := toSet(parameters)
+	var missing []string
+	for _, parameter := range c.Required {
+		if _, ok := present[parameter]; !ok {
+			missing = append(missing, parameter)
+		}
+	}
+	return missing
+}
+
+// Present returns the forbidden parameters that are present.
+func (c ClassConstraints) Present(parameters []string) []string {
+	present := toSet(parameters)
+	var forbidden []string
+	for _, parameter := range c.Forbidden {
+		if _, ok := present[parameter]; ok {
+			forbidden = append(forbidden, parameter)
+		}
+	}
+	return forbidden
+}
+
+// ApplyClassRules drops candidates that contain forbidden parameters, records
+// the missing required ones and puts complete candidates first.
+func ApplyClassRules(classes []ProposedClass, constraints map[uint]ClassConstraints, parameters []string) []ProposedClass {
+	var result []ProposedClass
+	for _, class := range classes {
+		rules := constraints[class.ClassID]
+		if len(rules.Present(parameters)) > 0 {
+			continue
+		}
+		class.MissingParameters = rules.Missing(parameters)
+		result = append(result, class)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if len(result[i].MissingParameters) != len(result[j].MissingParameters) {
+			return len(result[i].MissingParameters) < len(result[j].MissingParameters)
+		}
+		return result[i].MatchingParameterNums > result[j].MatchingParameterNums
+	})
+	return result
+}
+
+// ServiceParameterIDs returns the IDs of the service parameters.
+func ServiceParameterIDs(service *models.Service) []string {
+	ids := make([]string, 0, len(service.Parameters))
+	for _, parameter := range service.Parameters {
+		ids = append(ids, parameter.ID)
+	}
+	return ids
+}
+
+func toSet(lists ...[]string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, list := range lists {
+		for _, value := range list {
+			set[value] = struct{}{}
+		}
+	}
+	return set
+}
+
+// ValidateParameterID rejects parameter IDs that cannot be stored in the
+// graph as a local name.
+func ValidateParameterID(id string) error {
+	return sparql.ValidateLocalName("param_" + id)
+}
+
+var ErrConflictingRules = errors.New("parameter cannot be both required and forbidden")
+
+// ValidateClassView checks every parameter ID the class refers to and that
+// the rules do not contradict each other.
+func ValidateClassView(class models.ClassView) error {
+	for _, ids := range [][]string{class.AllowedParameters, class.RequiredParameters, class.ForbiddenParameters} {
+		if err := ValidateParameterIDs(ids); err != nil {
+			return err
+		}
+	}
+
+	required := toSet(class.RequiredParameters)
+	for _, parameter := range class.ForbiddenParameters {
+		if _, ok := required[parameter]; ok {
+			return fmt.Errorf("%w: %s", ErrConflictingRules, parameter)
+		}
+	}
+	return nil
+}
+
+func ValidateParameterIDs(ids []string) error {
+	for _, id := range ids {
+		if err := ValidateParameterID(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
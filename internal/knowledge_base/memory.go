@@ -0,0 +1,408 @@
+package knowledge_base
+
+import (
+	"backend/internal/models"
+	"context"
+	"sort"
+	"sync"
+)
+
+// Memory is an in-process KnowledgeBase with the same semantics as the
+// Fuseki-backed one. It is meant for development and tests.
+type Memory struct {
+	mu sync.RWMutex
+
+	classes    map[uint]struct{}
+	parameters map[string]struct{}
+	// parents maps a class to its rdfs:subClassOf parent.
+	parents map[uint]uint
+	// allowed maps a class to the parameters it allows. Links may exist for
+	// classes that are not declared yet, exactly like triples in the graph.
+	allowed map[uint]map[string]struct{}
+	// required and forbidden map a class to its mandatory and excluded
+	// parameters.
+	required  map[uint]map[string]struct{}
+	forbidden map[uint]map[string]struct{}
+	// contradictions keeps the declared direction; lookups are symmetric.
+	contradictions map[string]map[string]struct{}
+	services       map[uint]memoryService
+}
+
+type memoryService struct {
+	classID    *uint
+	parameters map[string]struct{}
+}
+
+var _ KnowledgeBase = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{
+		classes:        make(map[uint]struct{}),
+		parameters:     make(map[string]struct{}),
+		parents:        make(map[uint]uint),
+		allowed:        make(map[uint]map[string]struct{}),
+		required:       make(map[uint]map[string]struct{}),
+		forbidden:      make(map[uint]map[string]struct{}),
+		contradictions: make(map[string]map[string]struct{}),
+		services:       make(map[uint]memoryService),
+	}
+}
+
+func (m *Memory) AddClass(_ context.Context, class models.ClassView) error {
+	if err := ValidateClassView(class); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.addClass(class)
+	return nil
+}
+
+func (m *Memory) addClass(class models.ClassView) {
+	m.classes[class.ID] = struct{}{}
+	if class.ParentID != nil {
+		m.parents[class.ID] = *class.ParentID
+	}
+	for _, parameter := range class.AllowedParameters {
+		m.allow(class.ID, parameter)
+	}
+	for _, parameter := range class.RequiredParameters {
+		link(m.required, class.ID, parameter)
+	}
+	for _, parameter := range class.ForbiddenParameters {
+		link(m.forbidden, class.ID, parameter)
+	}
+}
+
+func (m *Memory) UpdateClass(_ context.Context, class models.ClassView) error {
+	if err := ValidateClassView(class); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteClass(class.ID)
+	m.addClass(class)
+	return nil
+}
+
+func (m *Memory) DeleteClass(_ context.Context, id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteClass(id)
+	return nil
+}
+
+func (m *Memory) deleteClass(id uint) {
+	delete(m.classes, id)
+	delete(m.parents, id)
+	delete(m.allowed, id)
+	delete(m.required, id)
+	delete(m.forbidden, id)
+}
+
+func (m *Memory) GetClassConstraints(_ context.Context, classID uint) (ClassConstraints, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.constraints(classID), nil
+}
+
+func (m *Memory) GetClassRules(_ context.Context, classID uint) (ClassConstraints, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return ClassConstraints{
+		Allowed:   sortedKeys(m.allowed[classID]),
+		Required:  sortedKeys(m.required[classID]),
+		Forbidden: sortedKeys(m.forbidden[classID]),
+	}, nil
+}
+
+func (m *Memory) constraints(classID uint) ClassConstraints {
+	return ClassConstraints{
+		Allowed:   sortedKeys(m.inherited(m.allowed, classID)),
+		Required:  sortedKeys(m.inherited(m.required, classID)),
+		Forbidden: sortedKeys(m.inherited(m.forbidden, classID)),
+	}
+}
+
+// inherited merges the parameters linked to the class and all of its
+// ancestors.
+func (m *Memory) inherited(links map[uint]map[string]struct{}, classID uint) map[string]struct{} {
+	merged := make(map[string]struct{})
+	visited := make(map[uint]struct{})
+	for id, ok := classID, true; ok; id, ok = m.parents[id] {
+		if _, seen := visited[id]; seen {
+			break
+		}
+		visited[id] = struct{}{}
+		for parameter := range links[id] {
+			merged[parameter] = struct{}{}
+		}
+	}
+	return merged
+}
+
+func (m *Memory) AddParameter(_ context.Context, parameter models.ParameterView) error {
+	if err := validateParameterView(parameter); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.addParameter(parameter)
+	return nil
+}
+
+func (m *Memory) addParameter(parameter models.ParameterView) {
+	m.parameters[parameter.ID] = struct{}{}
+	for _, class := range parameter.AllowedClasses {
+		m.allow(class, parameter.ID)
+	}
+	for _, contradiction := range parameter.ContradictionParameters {
+		if m.contradictions[parameter.ID] == nil {
+			m.contradictions[parameter.ID] = make(map[string]struct{})
+		}
+		m.contradictions[parameter.ID][contradiction] = struct{}{}
+	}
+}
+
+func (m *Memory) UpdateParameter(_ context.Context, parameter models.ParameterView) error {
+	if err := validateParameterView(parameter); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteParameter(parameter.ID)
+	m.addParameter(parameter)
+	return nil
+}
+
+func (m *Memory) DeleteParameter(_ context.Context, parameterID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteParameter(parameterID)
+	// Required and forbidden links are owned by classes, so only a deletion
+	// removes them.
+	for _, links := range []map[uint]map[string]struct{}{m.required, m.forbidden} {
+		for _, parameters := range links {
+			delete(parameters, parameterID)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) deleteParameter(parameterID string) {
+	delete(m.parameters, parameterID)
+	delete(m.contradictions, parameterID)
+	for _, parameters := range m.allowed {
+		delete(parameters, parameterID)
+	}
+}
+
+func (m *Memory) GetParameterConstraints(_ context.Context, parameterID string) ([]uint, []string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var classes []uint
+	for class, parameters := range m.allowed {
+		if _, ok := parameters[parameterID]; ok {
+			classes = append(classes, class)
+		}
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+
+	return classes, sortedKeys(m.contradictions[parameterID]), nil
+}
+
+func (m *Memory) AddService(_ context.Context, service *models.Service) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := memoryService{parameters: make(map[string]struct{}, len(service.Parameters))}
+	if service.ClassID != nil {
+		classID := *service.ClassID
+		entry.classID = &classID
+	}
+	for _, parameter := range service.Parameters {
+		entry.parameters[parameter.ID] = struct{}{}
+	}
+	m.services[service.ID] = entry
+
+	return nil
+}
+
+func (m *Memory) DeleteService(_ context.Context, id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.services, id)
+	return nil
+}
+
+func (m *Memory) ProposedClasses(_ context.Context, service *models.Service) ([]ProposedClass, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	serviceParams := parameterSet(service)
+
+	var classes []ProposedClass
+	constraints := make(map[uint]ClassConstraints)
+	for classID := range m.classes {
+		common := make(map[string]struct{})
+		rules := m.constraints(classID)
+		constraints[classID] = rules
+		for parameter := range toSet(rules.Allowed, rules.Required) {
+			if _, ok := serviceParams[parameter]; ok {
+				common[parameter] = struct{}{}
+			}
+		}
+		if len(common) == 0 {
+			continue
+		}
+
+		class := ProposedClass{
+			ClassID:               classID,
+			MatchingParameterNums: len(common),
+		}
+		for id, similar := range m.services {
+			if id == service.ID || similar.classID == nil || *similar.classID != classID {
+				continue
+			}
+			for parameter := range similar.parameters {
+				if _, ok := common[parameter]; ok {
+					class.SimilarServices = append(class.SimilarServices, id)
+					break
+				}
+			}
+		}
+		sort.Slice(class.SimilarServices, func(i, j int) bool {
+			return class.SimilarServices[i] < class.SimilarServices[j]
+		})
+
+		classes = append(classes, class)
+	}
+
+	sort.Slice(classes, func(i, j int) bool { return classes[i].ClassID < classes[j].ClassID })
+
+	return ApplyClassRules(classes, constraints, ServiceParameterIDs(service)), nil
+}
+
+func (m *Memory) ValidateClass(_ context.Context, service *models.Service, chosenClass uint) ([]Violation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.constraints(chosenClass).Violations(chosenClass, ServiceParameterIDs(service)), nil
+}
+
+func (m *Memory) ValidateService(_ context.Context, service *models.Service) ([]Violation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return Contradictions(ServiceParameterIDs(service), m.contradicts), nil
+}
+
+func (m *Memory) ListClasses(_ context.Context) ([]models.ClassView, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	classes := make([]models.ClassView, 0, len(m.classes))
+	for id := range m.classes {
+		class := models.ClassView{
+			ID:                  id,
+			AllowedParameters:   sortedKeys(m.allowed[id]),
+			RequiredParameters:  sortedKeys(m.required[id]),
+			ForbiddenParameters: sortedKeys(m.forbidden[id]),
+		}
+		if parent, ok := m.parents[id]; ok {
+			class.ParentID = &parent
+		}
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i].ID < classes[j].ID })
+
+	return classes, nil
+}
+
+func (m *Memory) ListParameters(_ context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return sortedKeys(m.parameters), nil
+}
+
+func (m *Memory) ListServices(_ context.Context) ([]models.Service, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	services := make([]models.Service, 0, len(m.services))
+	for id, entry := range m.services {
+		service := models.Service{ID: id}
+		if entry.classID != nil {
+			classID := *entry.classID
+			service.ClassID = &classID
+		}
+		for _, parameter := range sortedKeys(entry.parameters) {
+			service.Parameters = append(service.Parameters, models.Parameter{ID: parameter})
+		}
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].ID < services[j].ID })
+
+	return services, nil
+}
+
+func (m *Memory) contradicts(p1, p2 string) bool {
+	if _, ok := m.contradictions[p1][p2]; ok {
+		return true
+	}
+	_, ok := m.contradictions[p2][p1]
+	return ok
+}
+
+func (m *Memory) allow(classID uint, parameterID string) {
+	link(m.allowed, classID, parameterID)
+}
+
+func link(links map[uint]map[string]struct{}, classID uint, parameterID string) {
+	if links[classID] == nil {
+		links[classID] = make(map[string]struct{})
+	}
+	links[classID][parameterID] = struct{}{}
+}
+
+func validateParameterView(parameter models.ParameterView) error {
+	if err := ValidateParameterID(parameter.ID); err != nil {
+		return err
+	}
+	return ValidateParameterIDs(parameter.ContradictionParameters)
+}
+
+func parameterSet(service *models.Service) map[string]struct{} {
+	set := make(map[string]struct{}, len(service.Parameters))
+	for _, parameter := range service.Parameters {
+		set[parameter.ID] = struct{}{}
+	}
+	return set
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
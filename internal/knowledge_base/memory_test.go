@@ -0,0 +1,252 @@
+package knowledge_base
+
+import (
+	"backend/internal/models"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newService(id uint, classID *uint, parameters ...string) *models.Service {
+	service := &models.Service{ID: id, ClassID: classID}
+	for _, parameter := range parameters {
+		service.Parameters = append(service.Parameters, models.Parameter{ID: parameter})
+	}
+	return service
+}
+
+func TestMemoryValidateService(t *testing.T) {
+	ctx := context.Background()
+	kb := NewMemory()
+	require.NoError(t, kb.AddParameter(ctx, models.ParameterView{ID: "mob_inet"}))
+	require.NoError(t, kb.AddParameter(ctx, models.ParameterView{ID: "fix_inet", ContradictionParameters: []string{"mob_inet"}}))
+	require.NoError(t, kb.AddParameter(ctx, models.ParameterView{ID: "sms"}))
+
+	tests := []struct {
+		name       string
+		parameters []string
+		want       []Violation
+	}{
+		{
+			name:       "No contradictions",
+			parameters: []string{"mob_inet", "sms"},
+			want:       nil,
+		},
+		{
+			name:       "Declared direction",
+			parameters: []string{"fix_inet", "mob_inet"},
+			want:       []Violation{{Rule: RuleContradiction, ParameterID: "fix_inet", OtherParameterID: "mob_inet"}},
+		},
+		{
+			name:       "Reverse direction",
+			parameters: []string{"mob_inet", "sms", "fix_inet", "mob_inet"},
+			want:       []Violation{{Rule: RuleContradiction, ParameterID: "mob_inet", OtherParameterID: "fix_inet"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kb.ValidateService(ctx, newService(1, nil, tt.parameters...))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemoryValidateClass(t *testing.T) {
+	ctx := context.Background()
+	kb := NewMemory()
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 29, AllowedParameters: []string{"mob_inet", "period_service"}}))
+	require.NoError(t, kb.AddParameter(ctx, models.ParameterView{ID: "roaming", AllowedClasses: []uint{29}}))
+
+	tests := []struct {
+		name       string
+		parameters []string
+		want       []Violation
+	}{
+		{name: "No parameters", parameters: nil, want: nil},
+		{name: "All allowed", parameters: []string{"mob_inet", "period_service"}, want: nil},
+		{name: "Allowed through parameter", parameters: []string{"mob_inet", "roaming"}, want: nil},
+		{
+			name:       "Not allowed",
+			parameters: []string{"mob_inet", "sms"},
+			want:       []Violation{{Rule: RuleNotAllowed, ParameterID: "sms", ClassID: 29}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kb.ValidateClass(ctx, newService(1, nil, tt.parameters...), 29)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemoryProposedClasses(t *testing.T) {
+	ctx := context.Background()
+	kb := NewMemory()
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 9, AllowedParameters: []string{"sms", "period_service"}}))
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 29, AllowedParameters: []string{"mob_inet", "period_service"}}))
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 1100, AllowedParameters: []string{"mob_inet", "sms", "period_service"}}))
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 3001, AllowedParameters: []string{"voice_fix"}}))
+
+	class29 := uint(29)
+	class1100 := uint(1100)
+	require.NoError(t, kb.AddService(ctx, newService(10, &class29, "mob_inet")))
+	require.NoError(t, kb.AddService(ctx, newService(11, &class29, "voice_fix")))
+	require.NoError(t, kb.AddService(ctx, newService(12, &class1100, "sms")))
+	require.NoError(t, kb.AddService(ctx, newService(13, &class1100, "mob_inet")))
+
+	got, err := kb.ProposedClasses(ctx, newService(13, nil, "mob_inet", "period_service"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []ProposedClass{
+		{ClassID: 29, MatchingParameterNums: 2, SimilarServices: []uint{10}},
+		{ClassID: 1100, MatchingParameterNums: 2},
+		{ClassID: 9, MatchingParameterNums: 1},
+	}, got)
+}
+
+func TestMemoryRequiredAndForbiddenParameters(t *testing.T) {
+	ctx := context.Background()
+	kb := NewMemory()
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{
+		ID:                  1004,
+		AllowedParameters:   []string{"sms", "roaming", "mn_roam", "period_service"},
+		RequiredParameters:  []string{"mn_roam"},
+		ForbiddenParameters: []string{"iot"},
+	}))
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 9, AllowedParameters: []string{"sms", "period_service", "iot"}}))
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 1124, AllowedParameters: []string{"sms", "iot"}, RequiredParameters: []string{"iot"}}))
+
+	tests := []struct {
+		name       string
+		parameters []string
+		want       []Violation
+	}{
+		{name: "Required present", parameters: []string{"sms", "mn_roam"}, want: nil},
+		{
+			name:       "Required missing",
+			parameters: []string{"sms", "roaming"},
+			want:       []Violation{{Rule: RuleMissingRequired, ParameterID: "mn_roam", ClassID: 1004}},
+		},
+		{
+			name:       "Forbidden present",
+			parameters: []string{"sms", "mn_roam", "iot"},
+			want:       []Violation{{Rule: RuleForbidden, ParameterID: "iot", ClassID: 1004}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kb.ValidateClass(ctx, newService(1, nil, tt.parameters...), 1004)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	got, err := kb.ProposedClasses(ctx, newService(1, nil, "sms", "period_service", "roaming"))
+	require.NoError(t, err)
+	assert.Equal(t, []ProposedClass{
+		{ClassID: 9, MatchingParameterNums: 2},
+		{ClassID: 1004, MatchingParameterNums: 3, MissingParameters: []string{"mn_roam"}},
+		{ClassID: 1124, MatchingParameterNums: 1, MissingParameters: []string{"iot"}},
+	}, got)
+
+	got, err = kb.ProposedClasses(ctx, newService(1, nil, "sms", "iot"))
+	require.NoError(t, err)
+	assert.Equal(t, []ProposedClass{
+		{ClassID: 9, MatchingParameterNums: 2},
+		{ClassID: 1124, MatchingParameterNums: 2},
+	}, got)
+
+	require.NoError(t, kb.DeleteParameter(ctx, "mn_roam"))
+	constraints, err := kb.GetClassConstraints(ctx, 1004)
+	require.NoError(t, err)
+	assert.Empty(t, constraints.Required)
+	assert.Equal(t, []string{"iot"}, constraints.Forbidden)
+}
+
+func TestMemoryClassHierarchy(t *testing.T) {
+	ctx := context.Background()
+	kb := NewMemory()
+	telephony := uint(3000)
+	fixed := uint(3001)
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 3000, AllowedParameters: []string{"period_service"}}))
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 3001, ParentID: &telephony, AllowedParameters: []string{"voice_fix"}}))
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 3002, ParentID: &fixed, AllowedParameters: []string{"fix_inet"}}))
+
+	constraints, err := kb.GetClassConstraints(ctx, 3002)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fix_inet", "period_service", "voice_fix"}, constraints.Allowed)
+
+	rules, err := kb.GetClassRules(ctx, 3002)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fix_inet"}, rules.Allowed)
+
+	violations, err := kb.ValidateClass(ctx, newService(1, nil, "fix_inet", "period_service"), 3002)
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = kb.ValidateClass(ctx, newService(1, nil, "fix_inet", "period_service"), 3001)
+	require.NoError(t, err)
+	assert.Equal(t, []Violation{{Rule: RuleNotAllowed, ParameterID: "fix_inet", ClassID: 3001}}, violations)
+
+	got, err := kb.ProposedClasses(ctx, newService(1, nil, "voice_fix", "period_service"))
+	require.NoError(t, err)
+	assert.Equal(t, []ProposedClass{
+		{ClassID: 3001, MatchingParameterNums: 2},
+		{ClassID: 3002, MatchingParameterNums: 2},
+		{ClassID: 3000, MatchingParameterNums: 1},
+	}, got)
+
+	classes, err := kb.ListClasses(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &fixed, classes[2].ParentID)
+	assert.Equal(t, []string{"fix_inet"}, classes[2].AllowedParameters)
+}
+
+func TestMemoryUpdateAndDeleteParameter(t *testing.T) {
+	ctx := context.Background()
+	kb := NewMemory()
+	require.NoError(t, kb.AddClass(ctx, models.ClassView{ID: 1}))
+	require.NoError(t, kb.AddParameter(ctx, models.ParameterView{ID: "sms", AllowedClasses: []uint{1}, ContradictionParameters: []string{"mms"}}))
+
+	require.NoError(t, kb.UpdateParameter(ctx, models.ParameterView{ID: "sms", AllowedClasses: []uint{2}}))
+	classes, contradictions, err := kb.GetParameterConstraints(ctx, "sms")
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2}, classes)
+	assert.Empty(t, contradictions)
+
+	constraints, err := kb.GetClassConstraints(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, constraints.Allowed)
+
+	require.NoError(t, kb.DeleteParameter(ctx, "sms"))
+	classes, _, err = kb.GetParameterConstraints(ctx, "sms")
+	require.NoError(t, err)
+	assert.Empty(t, classes)
+}
+
+func TestMemoryListAndDeleteServices(t *testing.T) {
+	ctx := context.Background()
+	kb := NewMemory()
+	class9 := uint(9)
+	require.NoError(t, kb.AddService(ctx, newService(2, &class9, "sms", "period_service")))
+	require.NoError(t, kb.AddService(ctx, newService(1, nil, "mob_inet")))
+
+	services, err := kb.ListServices(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Service{
+		*newService(1, nil, "mob_inet"),
+		*newService(2, &class9, "period_service", "sms"),
+	}, services)
+
+	require.NoError(t, kb.DeleteService(ctx, 2))
+	services, err = kb.ListServices(ctx)
+	require.NoError(t, err)
+	assert.Len(t, services, 1)
+}
@@ -0,0 +1,72 @@
+package knowledge_base
+
+// Rule names the kind of constraint a service breaks.
+type Rule string
+
+const (
+	RuleContradiction   Rule = "contradiction"
+	RuleNotAllowed      Rule = "not_allowed"
+	RuleMissingRequired Rule = "missing_required"
+	RuleForbidden       Rule = "forbidden"
+)
+
+// Violation is a single broken rule. OtherParameterID is only set for
+// contradictions and ClassID only for class rules.
+type Violation struct {
+	Rule             Rule   `json:"rule"`
+	ParameterID      string `json:"parameter_id"`
+	OtherParameterID string `json:"other_parameter_id,omitempty"`
+	ClassID          uint   `json:"class_id,omitempty"`
+}
+
+// Violations checks the service parameters against the rules of classID.
+// Required parameters count as allowed, forbidden ones are reported once as
+// forbidden even when the class does not allow them either.
+func (c ClassConstraints) Violations(classID uint, parameters []string) []Violation {
+	allowed := toSet(c.Allowed, c.Required)
+	forbidden := toSet(c.Forbidden)
+
+	var violations []Violation
+	seen := make(map[string]struct{}, len(parameters))
+	for _, parameter := range parameters {
+		if _, ok := seen[parameter]; ok {
+			continue
+		}
+		seen[parameter] = struct{}{}
+
+		if _, ok := forbidden[parameter]; ok {
+			violations = append(violations, Violation{Rule: RuleForbidden, ParameterID: parameter, ClassID: classID})
+		} else if _, ok := allowed[parameter]; !ok {
+			violations = append(violations, Violation{Rule: RuleNotAllowed, ParameterID: parameter, ClassID: classID})
+		}
+	}
+	for _, parameter := range c.Missing(parameters) {
+		violations = append(violations, Violation{Rule: RuleMissingRequired, ParameterID: parameter, ClassID: classID})
+	}
+
+	return violations
+}
+
+// Contradictions reports every pair of parameters that contradict each
+// other once, in the order the parameters are given. contradicts is called
+// with the pair in both directions, so it may follow the declared one only.
+func Contradictions(parameters []string, contradicts func(p1, p2 string) bool) []Violation {
+	var distinct []string
+	seen := make(map[string]struct{}, len(parameters))
+	for _, parameter := range parameters {
+		if _, ok := seen[parameter]; !ok {
+			seen[parameter] = struct{}{}
+			distinct = append(distinct, parameter)
+		}
+	}
+
+	var violations []Violation
+	for i, p1 := range distinct {
+		for _, p2 := range distinct[i+1:] {
+			if contradicts(p1, p2) || contradicts(p2, p1) {
+				violations = append(violations, Violation{Rule: RuleContradiction, ParameterID: p1, OtherParameterID: p2})
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"backend/internal/models"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Window is the length of the periods acceptance is reported for.
+type Window string
+
+const (
+	Day   Window = "day"
+	Week  Window = "week"
+	Month Window = "month"
+)
+
+func ParseWindow(value string) (Window, error) {
+	switch window := Window(value); window {
+	case Day, Week, Month:
+		return window, nil
+	default:
+		return "", fmt.Errorf("unknown window %q, use day, week or month", value)
+	}
+}
+
+// Start returns the beginning of the window containing t, weeks start on
+// Monday.
+func (w Window) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch w {
+	case Day:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	case Week:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// DefaultBuckets split the probability of the top prediction.
+var DefaultBuckets = []float64{0, 0.3, 0.5, 0.7, 0.9, 1}
+
+// DefaultTopK are the k for which top-k accuracy is reported.
+var DefaultTopK = []int{1, 3, 5}
+
+type Options struct {
+	TopK []int
+	// Buckets are the ascending bucket boundaries, the last bucket includes
+	// its upper bound.
+	Buckets []float64
+	Window  Window
+}
+
+// Classification describes how well the model predictions matched the
+// classes experts approved. Approvals without a prediction only count
+// towards Approvals.
+type Classification struct {
+	Approvals int     `json:"approvals"`
+	Predicted int     `json:"predicted"`
+	Accuracy  float64 `json:"accuracy"`
+	TopK      []TopK  `json:"top_k"`
+	Classes   []Class `json:"classes"`
+	// Confusion lists the non-empty cells of the confusion matrix.
+	Confusion []ConfusionCell `json:"confusion"`
+	Buckets   []Acceptance    `json:"buckets"`
+	Windows   []Acceptance    `json:"windows"`
+}
+
+type TopK struct {
+	K        int     `json:"k"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+type Class struct {
+	ClassID uint   `json:"class_id"`
+	Title   string `json:"title,omitempty"`
+	// Support is the number of approvals into the class, Predictions the
+	// number of times the model put it first.
+	Support     int     `json:"support"`
+	Predictions int     `json:"predictions"`
+	Correct     int     `json:"correct"`
+	Precision   float64 `json:"precision"`
+	Recall      float64 `json:"recall"`
+}
+
+type ConfusionCell struct {
+	PredictedClassID uint `json:"predicted_class_id"`
+	FinalClassID     uint `json:"final_class_id"`
+	Count            int  `json:"count"`
+}
+
+// Acceptance is the share of top predictions experts kept, either within a
+// probability bucket [From, To) or a time window starting at Start.
+type Acceptance struct {
+	From     *float64   `json:"from,omitempty"`
+	To       *float64   `json:"to,omitempty"`
+	Start    *time.Time `json:"start,omitempty"`
+	Total    int        `json:"total"`
+	Accepted int        `json:"accepted"`
+	Rate     float64    `json:"rate"`
+}
+
+func Compute(feedback []models.ApprovalFeedback, options Options) Classification {
+	result := Classification{
+		Approvals: len(feedback),
+		TopK:      make([]TopK, 0, len(options.TopK)),
+		Classes:   []Class{},
+		Confusion: []ConfusionCell{},
+		Buckets:   make([]Acceptance, 0, len(options.Buckets)),
+		Windows:   []Acceptance{},
+	}
+
+	classes := make(map[uint]*Class)
+	class := func(id uint) *Class {
+		if classes[id] == nil {
+			classes[id] = &Class{ClassID: id}
+		}
+		return classes[id]
+	}
+	confusion := make(map[[2]uint]int)
+	topK := make([]int, len(options.TopK))
+	for i := 1; i < len(options.Buckets); i++ {
+		from, to := options.Buckets[i-1], options.Buckets[i]
+		result.Buckets = append(result.Buckets, Acceptance{From: &from, To: &to})
+	}
+	windows := make(map[time.Time]*Acceptance)
+
+	accepted := 0
+	for _, item := range feedback {
+		if item.PredictedClassID == nil {
+			continue
+		}
+		result.Predicted++
+		predicted := *item.PredictedClassID
+
+		class(item.FinalClassID).Support++
+		class(predicted).Predictions++
+		if item.Accepted {
+			accepted++
+			class(predicted).Correct++
+		}
+		confusion[[2]uint{predicted, item.FinalClassID}]++
+
+		for i, k := range options.TopK {
+			if item.FinalClassRank > 0 && item.FinalClassRank <= k {
+				topK[i]++
+			}
+		}
+
+		if item.PredictedProbability != nil {
+			if i := bucket(options.Buckets, *item.PredictedProbability); i >= 0 {
+				count(&result.Buckets[i], item.Accepted)
+			}
+		}
+
+		start := options.Window.Start(item.ApprovedAt)
+		if windows[start] == nil {
+			windows[start] = &Acceptance{Start: &start}
+		}
+		count(windows[start], item.Accepted)
+	}
+
+	result.Accuracy = ratio(accepted, result.Predicted)
+	for i, k := range options.TopK {
+		result.TopK = append(result.TopK, TopK{K: k, Accuracy: ratio(topK[i], result.Predicted)})
+	}
+
+	for _, class := range classes {
+		class.Precision = ratio(class.Correct, class.Predictions)
+		class.Recall = ratio(class.Correct, class.Support)
+		result.Classes = append(result.Classes, *class)
+	}
+	sort.Slice(result.Classes, func(i, j int) bool { return result.Classes[i].ClassID < result.Classes[j].ClassID })
+
+	for cell, n := range confusion {
+		result.Confusion = append(result.Confusion, ConfusionCell{PredictedClassID: cell[0], FinalClassID: cell[1], Count: n})
+	}
+	sort.Slice(result.Confusion, func(i, j int) bool {
+		if result.Confusion[i].PredictedClassID != result.Confusion[j].PredictedClassID {
+			return result.Confusion[i].PredictedClassID < result.Confusion[j].PredictedClassID
+		}
+		return result.Confusion[i].FinalClassID < result.Confusion[j].FinalClassID
+	})
+
+	for i := range result.Buckets {
+		result.Buckets[i].Rate = ratio(result.Buckets[i].Accepted, result.Buckets[i].Total)
+	}
+	for _, window := range windows {
+		window.Rate = ratio(window.Accepted, window.Total)
+		result.Windows = append(result.Windows, *window)
+	}
+	sort.Slice(result.Windows, func(i, j int) bool { return result.Windows[i].Start.Before(*result.Windows[j].Start) })
+
+	return result
+}
+
+func bucket(boundaries []float64, probability float64) int {
+	for i := 1; i < len(boundaries); i++ {
+		if probability < boundaries[i] || (i == len(boundaries)-1 && probability <= boundaries[i]) {
+			if probability >= boundaries[i-1] {
+				return i - 1
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+func count(acceptance *Acceptance, accepted bool) {
+	acceptance.Total++
+	if accepted {
+		acceptance.Accepted++
+	}
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
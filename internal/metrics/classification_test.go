@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"backend/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func feedback(predicted, final uint, rank int, probability float64, approvedAt time.Time) models.ApprovalFeedback {
+	return models.ApprovalFeedback{
+		FinalClassID:         final,
+		PredictedClassID:     &predicted,
+		PredictedProbability: &probability,
+		FinalClassRank:       rank,
+		Accepted:             predicted == final,
+		ApprovedAt:           approvedAt,
+	}
+}
+
+func TestCompute(t *testing.T) {
+	march := time.Date(2024, 3, 14, 10, 0, 0, 0, time.UTC)
+	april := time.Date(2024, 4, 2, 10, 0, 0, 0, time.UTC)
+
+	got := Compute([]models.ApprovalFeedback{
+		feedback(29, 29, 1, 0.95, march),
+		feedback(29, 9, 2, 0.6, march),
+		feedback(9, 9, 1, 1, april),
+		feedback(1100, 9, 0, 0.2, april),
+		{FinalClassID: 9, ApprovedAt: april},
+	}, Options{TopK: []int{1, 3}, Buckets: []float64{0, 0.5, 1}, Window: Month})
+
+	assert.Equal(t, 5, got.Approvals)
+	assert.Equal(t, 4, got.Predicted)
+	assert.Equal(t, 0.5, got.Accuracy)
+	assert.Equal(t, []TopK{{K: 1, Accuracy: 0.5}, {K: 3, Accuracy: 0.75}}, got.TopK)
+	assert.Equal(t, []Class{
+		{ClassID: 9, Support: 3, Predictions: 1, Correct: 1, Precision: 1, Recall: 1.0 / 3},
+		{ClassID: 29, Support: 1, Predictions: 2, Correct: 1, Precision: 0.5, Recall: 1},
+		{ClassID: 1100, Predictions: 1},
+	}, got.Classes)
+	assert.Equal(t, []ConfusionCell{
+		{PredictedClassID: 9, FinalClassID: 9, Count: 1},
+		{PredictedClassID: 29, FinalClassID: 9, Count: 1},
+		{PredictedClassID: 29, FinalClassID: 29, Count: 1},
+		{PredictedClassID: 1100, FinalClassID: 9, Count: 1},
+	}, got.Confusion)
+
+	assert.Len(t, got.Buckets, 2)
+	assert.Equal(t, 1, got.Buckets[0].Total)
+	assert.Equal(t, 0, got.Buckets[0].Accepted)
+	assert.Equal(t, 3, got.Buckets[1].Total)
+	assert.InDelta(t, 2.0/3, got.Buckets[1].Rate, 1e-9)
+
+	assert.Len(t, got.Windows, 2)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *got.Windows[0].Start)
+	assert.Equal(t, 0.5, got.Windows[0].Rate)
+	assert.Equal(t, 0.5, got.Windows[1].Rate)
+}
+
+func TestWindowStart(t *testing.T) {
+	sunday := time.Date(2024, 3, 17, 18, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC), Day.Start(sunday))
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), Week.Start(sunday))
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Month.Start(sunday))
+}
@@ -13,18 +13,41 @@ type Parameter struct {
 }
 
 type Service struct {
-	ID         uint        `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title      string      `json:"title"`
-	Parameters []Parameter `gorm:"many2many:service_parameters;" json:"parameters"`
-	ClassID    *uint       `gorm:"default:null" json:"class_id"`
-	Class      *Class      `gorm:"foreignKey:ClassID" json:"class"`
-	CreatedAt  time.Time   `gorm:"autoCreateTime" json:"created_at"`
-	ApprovedAt *time.Time  `json:"approved_at"`
+	ID         uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title      string        `json:"title"`
+	Parameters []Parameter   `gorm:"many2many:service_parameters;" json:"parameters"`
+	Status     ServiceStatus `gorm:"index" json:"status"`
+	ClassID    *uint         `gorm:"default:null" json:"class_id"`
+	Class      *Class        `gorm:"foreignKey:ClassID" json:"class"`
+	CreatedAt  time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	ApprovedAt *time.Time    `json:"approved_at"`
+	// ApprovalOverridden is set when an expert approved the service into a
+	// class whose rules it violates, OverrideJustification explains why.
+	ApprovalOverridden    bool   `json:"approval_overridden"`
+	OverrideJustification string `json:"override_justification,omitempty"`
+	// RejectionReason is set while the service is rejected.
+	RejectionReason string `json:"rejection_reason,omitempty"`
+	// BatchID is set on services created through a batch upload.
+	BatchID *uint `gorm:"index" json:"batch_id,omitempty"`
+	// Predictions are the classes suggested by the model, newest run first.
+	Predictions []ServicePrediction `gorm:"foreignKey:ServiceID;constraint:OnDelete:CASCADE" json:"predictions,omitempty"`
+}
+
+// ServiceBatch groups services uploaded in one request so that their
+// classification progress can be followed.
+type ServiceBatch struct {
+	ID uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	// Total is the number of submitted items, Created of them passed
+	// validation and were stored.
+	Total     int       `json:"total"`
+	Created   int       `json:"created"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 type Class struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Title     string    `json:"title"`
+	ParentID  *uint     `gorm:"default:null;index" json:"parent_id"`
 	New       bool      `json:"new"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdateAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
@@ -33,7 +56,19 @@ type Class struct {
 type ClassView struct {
 	ID                uint     `json:"id" example:"3042"`
 	Title             string   `json:"title"`
+	ParentID          *uint    `json:"parent_id,omitempty" example:"3000"`
 	AllowedParameters []string `json:"allowed_parameters" example:"mob_inet,fix_ctv,voice_fix"`
+	// RequiredParameters must all be present on a service of the class and
+	// ForbiddenParameters must all be absent.
+	RequiredParameters  []string `json:"required_parameters,omitempty" example:"roaming,mn_roam"`
+	ForbiddenParameters []string `json:"forbidden_parameters,omitempty" example:"iot"`
+}
+
+// ClassNode is a class with its subclasses.
+type ClassNode struct {
+	ID       uint         `json:"id"`
+	Title    string       `json:"title"`
+	Children []*ClassNode `json:"children"`
 }
 
 type ParameterView struct {
@@ -42,3 +77,172 @@ type ParameterView struct {
 	AllowedClasses          []uint   `json:"allowed_classes" example:"1,1033,3023"`
 	ContradictionParameters []string `json:"contradiction_parameters" example:"mob_inet,fix_ctv,voice_fix"`
 }
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxRunning OutboxStatus = "running"
+	OutboxDone    OutboxStatus = "done"
+	OutboxFailed  OutboxStatus = "failed"
+)
+
+// OutboxEvent is a knowledge-base mutation recorded in the same transaction
+// as the relational change and applied to the graph later.
+type OutboxEvent struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Operation string `json:"operation"`
+	// Aggregate names the changed entity, e.g. "class:9". Events of one
+	// aggregate are applied in order.
+	Aggregate     string       `gorm:"index" json:"aggregate"`
+	Payload       JSON         `gorm:"type:jsonb" json:"payload" swaggertype:"object"`
+	Status        OutboxStatus `gorm:"index;default:pending" json:"status"`
+	Attempts      int          `json:"attempts"`
+	LastError     string       `json:"last_error,omitempty"`
+	NextAttemptAt time.Time    `json:"next_attempt_at"`
+	// ClaimedAt is when a dispatcher started the current attempt.
+	ClaimedAt   *time.Time `json:"claimed_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ProcessedAt *time.Time `json:"processed_at"`
+}
+
+type PredictionJobStatus string
+
+const (
+	PredictionPending PredictionJobStatus = "pending"
+	PredictionRunning PredictionJobStatus = "running"
+	PredictionDone    PredictionJobStatus = "done"
+	PredictionFailed  PredictionJobStatus = "failed"
+)
+
+// PredictionJob asks the classifier for the class of a service. Jobs are
+// stored in the same transaction as the status change to
+// awaiting_prediction and survive restarts.
+type PredictionJob struct {
+	ID            uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	ServiceID     uint                `gorm:"index" json:"service_id"`
+	Status        PredictionJobStatus `gorm:"index;default:pending" json:"status"`
+	Attempts      int                 `json:"attempts"`
+	LastError     string              `json:"last_error,omitempty"`
+	NextAttemptAt time.Time           `gorm:"index" json:"next_attempt_at"`
+	// ClaimedAt is when a worker started the current attempt. A running job
+	// is leased to its worker until claimTimeout after it.
+	ClaimedAt  *time.Time `json:"claimed_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// ServicePrediction is one ranked class suggestion from a prediction job.
+// All predictions of a job share JobID and ModelVersion.
+type ServicePrediction struct {
+	ID           uint    `gorm:"primaryKey;autoIncrement" json:"-"`
+	ServiceID    uint    `gorm:"index" json:"service_id"`
+	JobID        uint    `gorm:"index" json:"job_id"`
+	Rank         int     `json:"rank"`
+	ClassID      uint    `json:"class_id"`
+	Probability  float64 `json:"probability"`
+	ModelVersion string  `json:"model_version"`
+	// SchemaVersion identifies the features sent to the model,
+	// SchemaMismatch is set when the model was trained on other ones.
+	SchemaVersion  string    `json:"schema_version"`
+	SchemaMismatch bool      `json:"schema_mismatch,omitempty"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// FeatureSchema is a set of supported parameters the model input was built
+// from. Version is the hash of the sorted parameter IDs.
+type FeatureSchema struct {
+	Version    string    `gorm:"primaryKey" json:"version"`
+	Parameters JSON      `gorm:"type:jsonb" json:"parameters" swaggertype:"array,string"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ApprovalFeedback compares the class an expert approved with the latest
+// model prediction. One row is written per approval.
+type ApprovalFeedback struct {
+	ID           uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	ServiceID    uint `gorm:"index" json:"service_id"`
+	FinalClassID uint `json:"final_class_id"`
+	// PredictedClassID and PredictedProbability describe the top prediction,
+	// they are empty when the model was not asked or failed.
+	PredictedClassID     *uint    `json:"predicted_class_id"`
+	PredictedProbability *float64 `json:"predicted_probability"`
+	ModelVersion         string   `json:"model_version,omitempty"`
+	// FinalClassRank is the rank of the final class among the predictions, 0
+	// when the model did not suggest it.
+	FinalClassRank int `json:"final_class_rank"`
+	// Accepted is set when the expert kept the top prediction.
+	Accepted   bool      `json:"accepted"`
+	Overridden bool      `json:"overridden"`
+	ApprovedAt time.Time `gorm:"index" json:"approved_at"`
+}
+
+// RevenueRecord is the revenue a service earned in one month. There is one
+// record per service, month and currency, imports overwrite it.
+type RevenueRecord struct {
+	ID        uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ServiceID uint     `gorm:"uniqueIndex:idx_revenue_service_period" json:"service_id"`
+	Service   *Service `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	// Period is the first day of the month.
+	Period    time.Time `gorm:"type:date;uniqueIndex:idx_revenue_service_period;index" json:"period"`
+	Currency  string    `gorm:"size:3;uniqueIndex:idx_revenue_service_period" json:"currency"`
+	Amount    float64   `gorm:"type:numeric(18,2)" json:"amount"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ClassRevenue is the revenue of the approved services of a class in one
+// month. Month is nil for classes without revenue in the requested range.
+type ClassRevenue struct {
+	ClassID      uint       `json:"class_id"`
+	ClassTitle   string     `json:"class_title"`
+	ServiceCount int        `json:"service_count"`
+	Month        *time.Time `json:"month"`
+	Amount       float64    `json:"amount"`
+}
+
+// ParameterRevenue is the revenue of the approved services with a parameter
+// in one month. A service counts towards each of its parameters.
+type ParameterRevenue struct {
+	ParameterID    string     `json:"parameter_id"`
+	ParameterTitle string     `json:"parameter_title"`
+	ServiceCount   int        `json:"service_count"`
+	Month          *time.Time `json:"month"`
+	Amount         float64    `json:"amount"`
+}
+
+type ArchivedReportStatus string
+
+const (
+	ReportRunning ArchivedReportStatus = "running"
+	ReportDone    ArchivedReportStatus = "done"
+	ReportFailed  ArchivedReportStatus = "failed"
+)
+
+// ArchivedReport is a report generated on a schedule. A schedule generates
+// one report per range and format, Content is kept in the database and
+// only set when the run is done. A failed run, or one running for too long,
+// is retried on the same row.
+type ArchivedReport struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Schedule string `gorm:"uniqueIndex:idx_archived_report_run" json:"schedule"`
+	// PeriodStart is the first day of the range, PeriodEnd the first day
+	// after it.
+	PeriodStart time.Time            `gorm:"type:date;uniqueIndex:idx_archived_report_run" json:"period_start"`
+	PeriodEnd   time.Time            `gorm:"type:date;uniqueIndex:idx_archived_report_run" json:"period_end"`
+	Format      string               `gorm:"size:8;uniqueIndex:idx_archived_report_run" json:"format"`
+	Granularity string               `json:"granularity"`
+	Grouping    string               `json:"grouping"`
+	Currency    string               `gorm:"size:3" json:"currency"`
+	FileName    string               `json:"file_name"`
+	Status      ArchivedReportStatus `gorm:"index;default:running" json:"status"`
+	Error       string               `json:"error,omitempty"`
+	Size        int                  `json:"size"`
+	Content     []byte               `json:"-"`
+	Attempts    int                  `json:"attempts"`
+	CreatedAt   time.Time            `gorm:"autoCreateTime;index" json:"created_at"`
+	// StartedAt is when the current attempt started.
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// ServiceStatus is the lifecycle state of a service. The allowed transitions
+// live in the services package.
+type ServiceStatus string
+
+const (
+	ServiceDraft              ServiceStatus = "draft"
+	ServiceAwaitingPrediction ServiceStatus = "awaiting_prediction"
+	ServicePredicted          ServiceStatus = "predicted"
+	ServiceNeedsReview        ServiceStatus = "needs_review"
+	ServiceApproved           ServiceStatus = "approved"
+	ServiceRejected           ServiceStatus = "rejected"
+	ServiceReopened           ServiceStatus = "reopened"
+)
+
+var ServiceStatuses = []ServiceStatus{
+	ServiceDraft,
+	ServiceAwaitingPrediction,
+	ServicePredicted,
+	ServiceNeedsReview,
+	ServiceApproved,
+	ServiceRejected,
+	ServiceReopened,
+}
+
+func (s ServiceStatus) Valid() bool {
+	for _, status := range ServiceStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
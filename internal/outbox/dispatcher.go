@@ -0,0 +1,164 @@
+package outbox
+
+import (
+	"backend/internal/backoff"
+	"backend/internal/knowledge_base"
+	"backend/internal/models"
+	"backend/internal/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// claimTimeout is how long a claimed event may stay running before another
+// dispatcher takes it over. It is above the knowledge base request timeout.
+const claimTimeout = 10 * time.Minute
+
+// Dispatcher applies pending outbox events to the knowledge base. Events of
+// one aggregate (a class, parameter or service) are applied in the order
+// they were recorded, also across instances; events of different aggregates
+// may be applied in any order.
+//
+// An event is claimed in a short transaction, applied without holding any
+// lock and its outcome stored in a second one. Events are safe to apply more
+// than once: additions are INSERT DATA (the graph is a set), updates replace
+// the entity's triples and deletions are no-ops when nothing matches. A
+// crash between the SPARQL call and storing the outcome, or a takeover after
+// claimTimeout, therefore only repeats the same change.
+type Dispatcher struct {
+	outbox        *Outbox
+	repo          repositories.OutboxRepository
+	knowledgeBase knowledge_base.KnowledgeBase
+	interval      time.Duration
+	maxAttempts   int
+}
+
+func NewDispatcher(outbox *Outbox, knowledgeBase knowledge_base.KnowledgeBase, interval time.Duration, maxAttempts int) *Dispatcher {
+	return &Dispatcher{
+		outbox:        outbox,
+		repo:          outbox.repo,
+		knowledgeBase: knowledgeBase,
+		interval:      interval,
+		maxAttempts:   maxAttempts,
+	}
+}
+
+// Run dispatches events until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.DispatchPending(ctx); err != nil {
+			slog.Error("Error while dispatching outbox", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.outbox.wake:
+		}
+	}
+}
+
+// DispatchPending applies due events until none is left that can be applied
+// now.
+func (d *Dispatcher) DispatchPending(ctx context.Context) error {
+	for ctx.Err() == nil {
+		now := time.Now()
+		event, err := d.repo.ClaimNext(now, now.Add(-claimTimeout))
+		if err != nil {
+			return err
+		}
+		if event == nil {
+			return nil
+		}
+
+		d.process(ctx, event)
+		if err := d.repo.Finish(event); err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			slog.Warn("Outbox event was taken over by another dispatcher", slog.Uint64("id", uint64(event.ID)))
+		}
+	}
+	return nil
+}
+
+// process applies a claimed event and sets its outcome. The attempt has
+// already been counted by the claim.
+func (d *Dispatcher) process(ctx context.Context, event *models.OutboxEvent) {
+	err := d.apply(ctx, event)
+	if err == nil {
+		now := time.Now()
+		event.Status = models.OutboxDone
+		event.LastError = ""
+		event.ProcessedAt = &now
+		return
+	}
+
+	event.LastError = err.Error()
+	if event.Attempts >= d.maxAttempts {
+		event.Status = models.OutboxFailed
+		slog.Error("Outbox event failed", slog.Uint64("id", uint64(event.ID)), slog.String("operation", event.Operation), slog.Any("error", err))
+		return
+	}
+	event.Status = models.OutboxPending
+	event.NextAttemptAt = time.Now().Add(backoff.Delay(d.interval, event.Attempts))
+	slog.Warn("Outbox event will be retried", slog.Uint64("id", uint64(event.ID)), slog.Int("attempts", event.Attempts), slog.Any("error", err))
+}
+
+func (d *Dispatcher) apply(ctx context.Context, event *models.OutboxEvent) error {
+	switch Operation(event.Operation) {
+	case AddClass, UpdateClass:
+		var class models.ClassView
+		if err := json.Unmarshal(event.Payload, &class); err != nil {
+			return err
+		}
+		if Operation(event.Operation) == AddClass {
+			return d.knowledgeBase.AddClass(ctx, class)
+		}
+		return d.knowledgeBase.UpdateClass(ctx, class)
+	case DeleteClass:
+		var id uint
+		if err := json.Unmarshal(event.Payload, &id); err != nil {
+			return err
+		}
+		return d.knowledgeBase.DeleteClass(ctx, id)
+	case AddParameter, UpdateParameter:
+		var parameter models.ParameterView
+		if err := json.Unmarshal(event.Payload, &parameter); err != nil {
+			return err
+		}
+		if Operation(event.Operation) == AddParameter {
+			return d.knowledgeBase.AddParameter(ctx, parameter)
+		}
+		return d.knowledgeBase.UpdateParameter(ctx, parameter)
+	case DeleteParameter:
+		var id string
+		if err := json.Unmarshal(event.Payload, &id); err != nil {
+			return err
+		}
+		return d.knowledgeBase.DeleteParameter(ctx, id)
+	case AddService:
+		var service models.Service
+		if err := json.Unmarshal(event.Payload, &service); err != nil {
+			return err
+		}
+		return d.knowledgeBase.AddService(ctx, &service)
+	case DeleteService:
+		var id uint
+		if err := json.Unmarshal(event.Payload, &id); err != nil {
+			return err
+		}
+		return d.knowledgeBase.DeleteService(ctx, id)
+	default:
+		return fmt.Errorf("unknown outbox operation %q", event.Operation)
+	}
+}
@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	"backend/internal/knowledge_base"
+	"backend/internal/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustEvent(t *testing.T, operation Operation, payload any) *models.OutboxEvent {
+	event, err := newEvent(operation, payload)
+	require.NoError(t, err)
+	return event
+}
+
+func TestDispatcherProcess(t *testing.T) {
+	ctx := context.Background()
+	kb := knowledge_base.NewMemory()
+	d := &Dispatcher{knowledgeBase: kb, interval: time.Second, maxAttempts: 2}
+
+	events := []*models.OutboxEvent{
+		mustEvent(t, AddParameter, models.ParameterView{ID: "sms"}),
+		mustEvent(t, AddClass, models.ClassView{ID: 9, AllowedParameters: []string{"sms"}}),
+		mustEvent(t, UpdateClass, models.ClassView{ID: 9, AllowedParameters: []string{"sms", "mms"}}),
+	}
+	for _, event := range events {
+		d.process(ctx, event)
+		// Applying the same event twice must not change the outcome.
+		d.process(ctx, event)
+		assert.Equal(t, models.OutboxDone, event.Status)
+		assert.Empty(t, event.LastError)
+	}
+
+	constraints, err := kb.GetClassConstraints(ctx, 9)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mms", "sms"}, constraints.Allowed)
+
+	// The claim marks the event running and counts the attempt.
+	unknown := &models.OutboxEvent{Operation: "drop_graph", Payload: []byte("{}"), Status: models.OutboxRunning, Attempts: 1}
+	d.process(ctx, unknown)
+	assert.Equal(t, models.OutboxPending, unknown.Status)
+	assert.NotEmpty(t, unknown.LastError)
+
+	unknown.Status, unknown.Attempts = models.OutboxRunning, 2
+	d.process(ctx, unknown)
+	assert.Equal(t, models.OutboxFailed, unknown.Status)
+}
+
+func TestAggregate(t *testing.T) {
+	assert.Equal(t, "class:9", aggregate(AddClass, models.ClassView{ID: 9}))
+	assert.Equal(t, "class:9", aggregate(DeleteClass, uint(9)))
+	assert.Equal(t, "parameter:sms", aggregate(UpdateParameter, models.ParameterView{ID: "sms"}))
+	assert.Equal(t, "parameter:sms", aggregate(DeleteParameter, "sms"))
+	assert.Equal(t, "service:5", aggregate(AddService, &models.Service{ID: 5}))
+	assert.Equal(t, "service:5", aggregate(DeleteService, uint(5)))
+}
@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"backend/internal/models"
+	"backend/internal/repositories"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Operation string
+
+const (
+	AddClass        Operation = "add_class"
+	UpdateClass     Operation = "update_class"
+	DeleteClass     Operation = "delete_class"
+	AddParameter    Operation = "add_parameter"
+	UpdateParameter Operation = "update_parameter"
+	DeleteParameter Operation = "delete_parameter"
+	AddService      Operation = "add_service"
+	DeleteService   Operation = "delete_service"
+)
+
+// Outbox records knowledge-base mutations next to the relational changes
+// that cause them.
+type Outbox struct {
+	repo repositories.OutboxRepository
+	wake chan struct{}
+}
+
+func New(repo repositories.OutboxRepository) *Outbox {
+	return &Outbox{
+		repo: repo,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Change is a mutation recorded without a relational change.
+type Change struct {
+	Operation Operation
+	Payload   any
+}
+
+// Enqueue stores the mutation within tx. Call Notify once tx is committed.
+func (o *Outbox) Enqueue(tx *gorm.DB, operation Operation, payload any) error {
+	event, err := newEvent(operation, payload)
+	if err != nil {
+		return err
+	}
+	return o.repo.WithTx(tx).Create(event)
+}
+
+// Record stores mutations that have no relational counterpart, e.g. repairs
+// of the graph, all or none, and wakes the dispatcher.
+func (o *Outbox) Record(changes ...Change) error {
+	events := make([]models.OutboxEvent, 0, len(changes))
+	for _, change := range changes {
+		event, err := newEvent(change.Operation, change.Payload)
+		if err != nil {
+			return err
+		}
+		events = append(events, *event)
+	}
+	if err := o.repo.CreateAll(events); err != nil {
+		return err
+	}
+	o.Notify()
+	return nil
+}
+
+// Unapplied returns the aggregates with events that are not applied yet or
+// were recorded after the event afterID.
+func (o *Outbox) Unapplied(afterID uint) (map[string]struct{}, error) {
+	aggregates, err := o.repo.UnappliedAggregates(afterID)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]struct{}, len(aggregates))
+	for _, aggregate := range aggregates {
+		set[aggregate] = struct{}{}
+	}
+	return set, nil
+}
+
+// LastID returns the ID of the latest event, 0 when there is none.
+func (o *Outbox) LastID() (uint, error) {
+	return o.repo.LastID()
+}
+
+func newEvent(operation Operation, payload any) (*models.OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", operation, err)
+	}
+	return &models.OutboxEvent{
+		Operation:     string(operation),
+		Aggregate:     aggregate(operation, payload),
+		Payload:       data,
+		Status:        models.OutboxPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
+
+// Entities of the graph, the first part of an aggregate.
+const (
+	ClassEntity     = "class"
+	ParameterEntity = "parameter"
+	ServiceEntity   = "service"
+)
+
+// Aggregate names an entity of the graph, e.g. "class:9".
+func Aggregate(entity string, id any) string {
+	return fmt.Sprintf("%s:%v", entity, id)
+}
+
+// aggregate names the entity changed by the operation, e.g. "class:9" for
+// add_class, update_class and delete_class of class 9.
+func aggregate(operation Operation, payload any) string {
+	var id any
+	switch p := payload.(type) {
+	case models.ClassView:
+		id = p.ID
+	case models.ParameterView:
+		id = p.ID
+	case *models.Service:
+		id = p.ID
+	default:
+		id = p
+	}
+	_, entity, _ := strings.Cut(string(operation), "_")
+	return Aggregate(entity, id)
+}
+
+// Notify wakes the dispatcher up without waiting for the next poll.
+func (o *Outbox) Notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
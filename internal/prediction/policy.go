@@ -0,0 +1,74 @@
+package prediction
+
+import (
+	"backend/internal/classifier"
+	"backend/internal/models"
+	"fmt"
+)
+
+// Decision is what happens to a service after a prediction.
+type Decision string
+
+const (
+	// DecisionAssign sets the top class on the service.
+	DecisionAssign Decision = "assign"
+	// DecisionReview sends the service to an expert with suggestions.
+	DecisionReview Decision = "review"
+	// DecisionUnassigned sends the service to an expert without a class,
+	// the model was not confident enough to suggest one.
+	DecisionUnassigned Decision = "unassigned"
+	// DecisionSchemaMismatch sends the service to an expert because the
+	// model was trained on other features than it received.
+	DecisionSchemaMismatch Decision = "schema_mismatch"
+)
+
+// Policy routes predictions by the probability of the top class.
+type Policy struct {
+	// AutoAssign is the probability from which the top class is set.
+	AutoAssign float64
+	// Floor is the probability below which no class is suggested.
+	Floor float64
+	// AutoApprove approves services whose class was set automatically.
+	AutoApprove bool
+	// Suggestions is the number of classes shown to the expert.
+	Suggestions int
+	// StrictSchema treats a model that does not report its feature schema
+	// as trained on other features.
+	StrictSchema bool
+}
+
+func (p Policy) Validate() error {
+	if p.Floor < 0 || p.AutoAssign > 1 || p.Floor > p.AutoAssign {
+		return fmt.Errorf("prediction thresholds must satisfy 0 <= floor (%v) <= auto-assign (%v) <= 1", p.Floor, p.AutoAssign)
+	}
+	return nil
+}
+
+func (p Policy) Decide(result *classifier.Result) Decision {
+	if result.SchemaMismatch(p.StrictSchema) {
+		return DecisionSchemaMismatch
+	}
+	predictions := result.Predictions
+	if len(predictions) == 0 || predictions[0].Probability < p.Floor {
+		return DecisionUnassigned
+	}
+	if predictions[0].Probability >= p.AutoAssign {
+		return DecisionAssign
+	}
+	return DecisionReview
+}
+
+// Suggest returns the classes worth showing to an expert: at most
+// Suggestions ranked predictions that reach the floor.
+func (p Policy) Suggest(predictions []models.ServicePrediction) []models.ServicePrediction {
+	suggestions := make([]models.ServicePrediction, 0, min(len(predictions), p.Suggestions))
+	for _, prediction := range predictions {
+		if len(suggestions) == p.Suggestions {
+			break
+		}
+		if prediction.Probability >= p.Floor {
+			suggestions = append(suggestions, prediction)
+		}
+	}
+	return suggestions
+}
@@ -0,0 +1,73 @@
+package prediction
+
+import (
+	"backend/internal/classifier"
+	"backend/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyDecide(t *testing.T) {
+	policy := Policy{AutoAssign: 0.8, Floor: 0.4}
+
+	tests := []struct {
+		name        string
+		predictions []classifier.Prediction
+		want        Decision
+	}{
+		{name: "No predictions", predictions: nil, want: DecisionUnassigned},
+		{name: "Below floor", predictions: []classifier.Prediction{{ClassID: 9, Probability: 0.39}}, want: DecisionUnassigned},
+		{name: "At floor", predictions: []classifier.Prediction{{ClassID: 9, Probability: 0.4}}, want: DecisionReview},
+		{name: "Middle band", predictions: []classifier.Prediction{{ClassID: 9, Probability: 0.79}}, want: DecisionReview},
+		{name: "At auto-assign", predictions: []classifier.Prediction{{ClassID: 9, Probability: 0.8}}, want: DecisionAssign},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.Decide(&classifier.Result{Predictions: tt.predictions}))
+		})
+	}
+}
+
+func TestPolicyDecideSchemaMismatch(t *testing.T) {
+	confident := []classifier.Prediction{{ClassID: 9, Probability: 0.99}}
+
+	tests := []struct {
+		name   string
+		strict bool
+		result classifier.Result
+		want   Decision
+	}{
+		{name: "Same schema", result: classifier.Result{SchemaVersion: "a1", ModelSchema: "a1"}, want: DecisionAssign},
+		{name: "Other schema", result: classifier.Result{SchemaVersion: "a1", ModelSchema: "b2"}, want: DecisionSchemaMismatch},
+		{name: "Not reported", result: classifier.Result{SchemaVersion: "a1"}, want: DecisionAssign},
+		{name: "Not reported in strict mode", strict: true, result: classifier.Result{SchemaVersion: "a1"}, want: DecisionSchemaMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.result.Predictions = confident
+			policy := Policy{AutoAssign: 0.8, Floor: 0.4, StrictSchema: tt.strict}
+			assert.Equal(t, tt.want, policy.Decide(&tt.result))
+		})
+	}
+}
+
+func TestPolicySuggest(t *testing.T) {
+	predictions := []models.ServicePrediction{
+		{Rank: 1, ClassID: 29, Probability: 0.5},
+		{Rank: 2, ClassID: 9, Probability: 0.3},
+		{Rank: 3, ClassID: 1100, Probability: 0.1},
+	}
+
+	assert.Equal(t, predictions[:1], Policy{Floor: 0.2, Suggestions: 1}.Suggest(predictions))
+	assert.Equal(t, predictions[:2], Policy{Floor: 0.2, Suggestions: 3}.Suggest(predictions))
+	assert.Empty(t, Policy{Floor: 0.6, Suggestions: 3}.Suggest(predictions))
+}
+
+func TestPolicyValidate(t *testing.T) {
+	assert.NoError(t, Policy{AutoAssign: 0.9, Floor: 0.5}.Validate())
+	assert.Error(t, Policy{AutoAssign: 0.5, Floor: 0.9}.Validate())
+	assert.Error(t, Policy{AutoAssign: 1.2, Floor: 0.5}.Validate())
+}
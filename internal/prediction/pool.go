@@ -0,0 +1,363 @@
+package prediction
+
+import (
+	"backend/internal/backoff"
+	"backend/internal/classifier"
+	"backend/internal/knowledge_base"
+	"backend/internal/models"
+	"backend/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// claimTimeout is how long a claimed job may stay running before it is
+// requeued as left by a crashed process. It is well above the classifier
+// timeout. A worker that overran it anyway notices when renewing the claim
+// and leaves the job to its new worker.
+const claimTimeout = 10 * time.Minute
+
+// Applier stores the outcome of a prediction on the service.
+type Applier interface {
+	ApplyPrediction(service *models.Service, class *models.Class) error
+	ApproveService(service *models.Service, class *models.Class, justification string) error
+	MarkNeedsReview(service *models.Service) error
+}
+
+// Pool runs prediction jobs with a fixed number of workers. A job that fails
+// is retried with exponential backoff, after the last attempt the service
+// goes to review. Successful predictions are routed by the policy.
+type Pool struct {
+	queue         *Queue
+	repo          repositories.PredictionJobRepository
+	resultRepo    repositories.ServicePredictionRepository
+	schemaRepo    repositories.FeatureSchemaRepository
+	services      Applier
+	serviceRepo   repositories.ServiceRepository
+	classRepo     repositories.ClassRepository
+	parameterRepo repositories.ParameterRepository
+	knowledgeBase knowledge_base.KnowledgeBase
+	classifier    classifier.Classifier
+	policy        Policy
+	workers       int
+	batchSize     int
+	interval      time.Duration
+	maxAttempts   int
+}
+
+func NewPool(queue *Queue, resultRepo repositories.ServicePredictionRepository, schemaRepo repositories.FeatureSchemaRepository, services Applier, serviceRepo repositories.ServiceRepository, classRepo repositories.ClassRepository, parameterRepo repositories.ParameterRepository, knowledgeBase knowledge_base.KnowledgeBase, classifier classifier.Classifier, policy Policy, workers, batchSize int, interval time.Duration, maxAttempts int) *Pool {
+	return &Pool{
+		queue:         queue,
+		repo:          queue.repo,
+		resultRepo:    resultRepo,
+		schemaRepo:    schemaRepo,
+		services:      services,
+		serviceRepo:   serviceRepo,
+		classRepo:     classRepo,
+		parameterRepo: parameterRepo,
+		knowledgeBase: knowledgeBase,
+		classifier:    classifier,
+		policy:        policy,
+		workers:       max(workers, 1),
+		batchSize:     max(batchSize, 1),
+		interval:      interval,
+		maxAttempts:   maxAttempts,
+	}
+}
+
+// Run works on jobs until ctx is cancelled. Jobs of crashed processes are
+// requeued on start and whenever their claim may have expired since.
+func (p *Pool) Run(ctx context.Context) {
+	p.requeue()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(claimTimeout)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.requeue()
+			}
+		}
+	}()
+	for range p.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) requeue() {
+	now := time.Now()
+	requeued, err := p.repo.Requeue(now, now.Add(-claimTimeout))
+	if err != nil {
+		slog.Error("Error while requeueing prediction jobs", slog.Any("error", err))
+	} else if requeued > 0 {
+		slog.Info("Requeued prediction jobs", slog.Int64("jobs", requeued))
+		p.queue.Notify()
+	}
+}
+
+func (p *Pool) work(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.ProcessDue(ctx); err != nil {
+			slog.Error("Error while processing prediction jobs", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.queue.wake:
+		}
+	}
+}
+
+// ProcessDue runs due jobs until there is nothing left to do. Jobs are
+// claimed in batches so that the model is called once per batch.
+func (p *Pool) ProcessDue(ctx context.Context) error {
+	for ctx.Err() == nil {
+		jobs, err := p.repo.ClaimDue(time.Now(), p.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		p.process(ctx, jobs)
+		for i := range jobs {
+			err := p.repo.Finish(&jobs[i])
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				slog.Warn("Prediction job was taken over, dropping its outcome", slog.Uint64("id", uint64(jobs[i].ID)), slog.Uint64("service_id", uint64(jobs[i].ServiceID)))
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Pool) process(ctx context.Context, jobs []models.PredictionJob) {
+	var pending []*models.PredictionJob
+	var services []*models.Service
+	for i := range jobs {
+		job := &jobs[i]
+		service, err := p.serviceRepo.GetByID(job.ServiceID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			finish(job, models.PredictionFailed, "service not found")
+			continue
+		}
+		if err != nil {
+			// The conditional status update leaves the service alone
+			// unless it still waits for this prediction.
+			p.fail(job, &models.Service{ID: job.ServiceID, Status: models.ServiceAwaitingPrediction}, err)
+			continue
+		}
+		// An expert decision or a newer job got there first.
+		if service.Status != models.ServiceAwaitingPrediction {
+			finish(job, models.PredictionDone, "")
+			continue
+		}
+		pending = append(pending, job)
+		services = append(services, service)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	results, err := p.predict(ctx, services)
+	if err != nil {
+		for i, job := range pending {
+			if p.renew(job, services[i]) {
+				p.fail(job, services[i], err)
+			}
+		}
+		return
+	}
+
+	for i, job := range pending {
+		if !p.renew(job, services[i]) {
+			continue
+		}
+		if err := p.resultRepo.ReplaceJob(job.ID, records(job, results[i], p.policy.StrictSchema)); err != nil {
+			p.fail(job, services[i], fmt.Errorf("failed to store predictions: %w", err))
+			continue
+		}
+		err := p.apply(ctx, services[i], results[i])
+		// An expert decided while the model was running, their decision stands.
+		if errors.Is(err, repositories.ErrStatusConflict) {
+			slog.Info("Service was changed during prediction, keeping its status", slog.Uint64("service_id", uint64(job.ServiceID)))
+			finish(job, models.PredictionDone, "")
+			continue
+		}
+		if err != nil {
+			p.fail(job, services[i], fmt.Errorf("failed to update service: %w", err))
+			continue
+		}
+		finish(job, models.PredictionDone, "")
+	}
+}
+
+// renew extends the claim of the job before it changes anything, the model
+// call may have outlasted claimTimeout. A job whose claim was lost is left
+// to its new worker, Finish then drops its outcome.
+func (p *Pool) renew(job *models.PredictionJob, service *models.Service) bool {
+	err := p.repo.Renew(job, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false
+	}
+	if err != nil {
+		p.fail(job, service, fmt.Errorf("failed to renew claim: %w", err))
+		return false
+	}
+	return true
+}
+
+// fail retries the job and hands the service to an expert once the job is
+// out of attempts. Every final failure goes through here, a service left
+// awaiting a prediction would get a new job from Requeue forever.
+func (p *Pool) fail(job *models.PredictionJob, service *models.Service, err error) {
+	p.retry(job, err)
+	if job.Status != models.PredictionFailed {
+		return
+	}
+	err = p.services.MarkNeedsReview(service)
+	if errors.Is(err, repositories.ErrStatusConflict) {
+		return
+	}
+	if err != nil {
+		slog.Error("Error updating service", slog.Uint64("service_id", uint64(service.ID)), slog.Any("error", err))
+	}
+}
+
+// retry schedules the job again or fails it after the last attempt.
+func (p *Pool) retry(job *models.PredictionJob, err error) {
+	job.LastError = err.Error()
+	if job.Attempts >= p.maxAttempts {
+		finish(job, models.PredictionFailed, job.LastError)
+		slog.Error("Prediction job failed", slog.Uint64("id", uint64(job.ID)), slog.Uint64("service_id", uint64(job.ServiceID)), slog.Any("error", err))
+		return
+	}
+	job.Status = models.PredictionPending
+	job.NextAttemptAt = time.Now().Add(backoff.Delay(p.interval, job.Attempts))
+	slog.Warn("Prediction job will be retried", slog.Uint64("id", uint64(job.ID)), slog.Int("attempts", job.Attempts), slog.Any("error", err))
+}
+
+func finish(job *models.PredictionJob, status models.PredictionJobStatus, lastError string) {
+	now := time.Now()
+	job.Status = status
+	job.LastError = lastError
+	job.FinishedAt = &now
+}
+
+// records turns the classifier result into rows ranked from 1.
+func records(job *models.PredictionJob, result *classifier.Result, strictSchema bool) []models.ServicePrediction {
+	mismatch := result.SchemaMismatch(strictSchema)
+	predictions := make([]models.ServicePrediction, 0, len(result.Predictions))
+	for i, prediction := range result.Predictions {
+		predictions = append(predictions, models.ServicePrediction{
+			ServiceID:      job.ServiceID,
+			JobID:          job.ID,
+			Rank:           i + 1,
+			ClassID:        prediction.ClassID,
+			Probability:    prediction.Probability,
+			ModelVersion:   result.ModelVersion,
+			SchemaVersion:  result.SchemaVersion,
+			SchemaMismatch: mismatch,
+		})
+	}
+	return predictions
+}
+
+// predict builds the feature vectors of the services, registers their
+// schema and asks the classifier for classes in one call.
+func (p *Pool) predict(ctx context.Context, services []*models.Service) ([]*classifier.Result, error) {
+	supportedParams, err := p.parameterRepo.ListSupportedParameters()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build payload: %w", err)
+	}
+	if _, err := RegisterSchema(p.schemaRepo, supportedParams); err != nil {
+		return nil, fmt.Errorf("failed to register feature schema: %w", err)
+	}
+
+	features := make([]classifier.Features, 0, len(services))
+	for _, service := range services {
+		features = append(features, classifier.BuildFeatures(supportedParams, service.Parameters))
+	}
+	results, err := p.classifier.PredictBatch(ctx, features)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != len(services) {
+		return nil, fmt.Errorf("classifier returned %d results for %d services", len(results), len(services))
+	}
+	return results, nil
+}
+
+// apply routes the service by the policy. A confident prediction is only
+// assigned when the class fits the service, otherwise an expert decides.
+func (p *Pool) apply(ctx context.Context, service *models.Service, result *classifier.Result) error {
+	decision := p.policy.Decide(result)
+	if decision == DecisionSchemaMismatch {
+		slog.Warn("Model was trained on another feature schema",
+			slog.Uint64("service_id", uint64(service.ID)),
+			slog.String("schema_version", result.SchemaVersion),
+			slog.String("model_schema", result.ModelSchema))
+	}
+	if decision != DecisionAssign {
+		slog.Info("Prediction needs review", slog.Uint64("service_id", uint64(service.ID)), slog.String("decision", string(decision)))
+		return p.services.MarkNeedsReview(service)
+	}
+	predictions := result.Predictions
+
+	class, ok := p.validPrediction(ctx, service, predictions[0].ClassID)
+	if !ok {
+		return p.services.MarkNeedsReview(service)
+	}
+	if err := p.services.ApplyPrediction(service, class); err != nil {
+		return err
+	}
+	if !p.policy.AutoApprove {
+		return nil
+	}
+	return p.services.ApproveService(service, class, "")
+}
+
+// validPrediction returns the predicted class when it fits the service.
+func (p *Pool) validPrediction(ctx context.Context, service *models.Service, classID uint) (*models.Class, bool) {
+	violations, err := p.knowledgeBase.ValidateClass(ctx, service, classID)
+	if err != nil {
+		slog.Error("Error validating class", slog.Any("error", err))
+		return nil, false
+	}
+	if len(violations) > 0 {
+		slog.Warn("Invalid class", slog.Uint64("class_id", uint64(classID)), slog.Any("violations", violations))
+		return nil, false
+	}
+
+	class, err := p.classRepo.GetByID(classID)
+	if err != nil {
+		slog.Warn("Class not found", slog.Uint64("class_id", uint64(classID)), slog.Any("error", err))
+		return nil, false
+	}
+	return class, true
+}
@@ -0,0 +1,70 @@
+package prediction
+
+import (
+	"backend/internal/classifier"
+	"backend/internal/models"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolRetry(t *testing.T) {
+	p := &Pool{interval: time.Second, maxAttempts: 2}
+	job := &models.PredictionJob{Status: models.PredictionRunning, Attempts: 1}
+
+	before := time.Now()
+	p.retry(job, errors.New("model timeout"))
+	assert.Equal(t, models.PredictionPending, job.Status)
+	assert.Equal(t, "model timeout", job.LastError)
+	assert.False(t, job.NextAttemptAt.Before(before.Add(time.Second)))
+	assert.Nil(t, job.FinishedAt)
+
+	job.Attempts++
+	p.retry(job, errors.New("model timeout"))
+	assert.Equal(t, models.PredictionFailed, job.Status)
+	assert.Equal(t, "model timeout", job.LastError)
+	assert.NotNil(t, job.FinishedAt)
+}
+
+type fakeApplier struct {
+	Applier
+	needsReview []uint
+}
+
+func (a *fakeApplier) MarkNeedsReview(service *models.Service) error {
+	a.needsReview = append(a.needsReview, service.ID)
+	return nil
+}
+
+func TestPoolFail(t *testing.T) {
+	services := &fakeApplier{}
+	p := &Pool{services: services, interval: time.Second, maxAttempts: 2}
+	service := &models.Service{ID: 3, Status: models.ServiceAwaitingPrediction}
+	job := &models.PredictionJob{ServiceID: 3, Status: models.PredictionRunning, Attempts: 1}
+
+	p.fail(job, service, errors.New("failed to store predictions"))
+	assert.Equal(t, models.PredictionPending, job.Status)
+	assert.Empty(t, services.needsReview)
+
+	job.Attempts++
+	p.fail(job, service, errors.New("failed to store predictions"))
+	assert.Equal(t, models.PredictionFailed, job.Status)
+	assert.Equal(t, []uint{3}, services.needsReview)
+}
+
+func TestRecords(t *testing.T) {
+	job := &models.PredictionJob{ID: 7, ServiceID: 3}
+	result := &classifier.Result{
+		ModelVersion:  "v2",
+		Predictions:   []classifier.Prediction{{ClassID: 29, Probability: 0.8}, {ClassID: 9, Probability: 0.15}},
+		SchemaVersion: "a1",
+		ModelSchema:   "b2",
+	}
+
+	assert.Equal(t, []models.ServicePrediction{
+		{ServiceID: 3, JobID: 7, Rank: 1, ClassID: 29, Probability: 0.8, ModelVersion: "v2", SchemaVersion: "a1", SchemaMismatch: true},
+		{ServiceID: 3, JobID: 7, Rank: 2, ClassID: 9, Probability: 0.15, ModelVersion: "v2", SchemaVersion: "a1", SchemaMismatch: true},
+	}, records(job, result, false))
+}
@@ -0,0 +1,42 @@
+package prediction
+
+import (
+	"backend/internal/models"
+	"backend/internal/repositories"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Queue stores prediction jobs next to the status change that requests
+// them.
+type Queue struct {
+	repo repositories.PredictionJobRepository
+	wake chan struct{}
+}
+
+func NewQueue(repo repositories.PredictionJobRepository) *Queue {
+	return &Queue{
+		repo: repo,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Enqueue stores a job for the service within tx. Call Notify once tx is
+// committed.
+func (q *Queue) Enqueue(tx *gorm.DB, serviceID uint) error {
+	job := &models.PredictionJob{
+		ServiceID:     serviceID,
+		Status:        models.PredictionPending,
+		NextAttemptAt: time.Now(),
+	}
+	return q.repo.WithTx(tx).Create(job)
+}
+
+// Notify wakes a worker up without waiting for the next poll.
+func (q *Queue) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,26 @@
+package prediction
+
+import (
+	"backend/internal/classifier"
+	"backend/internal/models"
+	"backend/internal/repositories"
+	"encoding/json"
+	"slices"
+)
+
+// RegisterSchema records the feature schema built from the supported
+// parameters, so every version sent to the model can be looked up later.
+func RegisterSchema(repo repositories.FeatureSchemaRepository, supportedParameters []string) (*models.FeatureSchema, error) {
+	parameters := slices.Clone(supportedParameters)
+	slices.Sort(parameters)
+	parameters = slices.Compact(parameters)
+
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, err
+	}
+	return repo.Register(&models.FeatureSchema{
+		Version:    classifier.SchemaVersion(parameters),
+		Parameters: data,
+	})
+}
@@ -0,0 +1,570 @@
+package reconcile
+
+import (
+	"backend/internal/knowledge_base"
+	"backend/internal/models"
+	"backend/internal/outbox"
+	"backend/internal/repositories"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// Direction tells which store is the source of truth when repairing.
+type Direction string
+
+const (
+	// ToKnowledgeBase makes the graph match Postgres.
+	ToKnowledgeBase Direction = "kb"
+	// ToDatabase makes Postgres match the graph.
+	ToDatabase Direction = "db"
+)
+
+func ParseDirection(value string) (Direction, error) {
+	switch Direction(value) {
+	case ToKnowledgeBase, ToDatabase:
+		return Direction(value), nil
+	default:
+		return "", fmt.Errorf("unknown direction %q, expected %q or %q", value, ToKnowledgeBase, ToDatabase)
+	}
+}
+
+type Options struct {
+	DryRun    bool
+	Direction Direction
+}
+
+type Report struct {
+	DryRun     bool         `json:"dry_run"`
+	Direction  Direction    `json:"direction"`
+	Classes    EntityReport `json:"classes"`
+	Parameters EntityReport `json:"parameters"`
+	Services   EntityReport `json:"services"`
+	Repaired   int          `json:"repaired"`
+	Errors     []string     `json:"errors,omitempty"`
+}
+
+type EntityReport struct {
+	// Missing entities exist in Postgres but not in the graph.
+	Missing []string `json:"missing"`
+	// Orphaned entities exist in the graph but not in Postgres.
+	Orphaned   []string   `json:"orphaned"`
+	Mismatched []Mismatch `json:"mismatched"`
+	// Pending entities have changes in the outbox that are not applied to
+	// the graph yet, they are not compared.
+	Pending []string `json:"pending"`
+}
+
+type Mismatch struct {
+	ID            string `json:"id"`
+	Field         string `json:"field"`
+	Database      any    `json:"database"`
+	KnowledgeBase any    `json:"knowledge_base"`
+}
+
+func newEntityReport() EntityReport {
+	return EntityReport{Missing: []string{}, Orphaned: []string{}, Mismatched: []Mismatch{}, Pending: []string{}}
+}
+
+type repair struct {
+	entity string
+	apply  func() error
+}
+
+// Approver approves services like an expert does, checking the status and
+// recording the approval.
+type Approver interface {
+	ApproveService(service *models.Service, class *models.Class, justification string) error
+}
+
+// Reconciler compares Postgres with the graph. The graph is only read, its
+// repairs go through the outbox like every other change so that they are
+// applied in order with the changes already waiting there.
+type Reconciler struct {
+	serviceRepo   repositories.ServiceRepository
+	classRepo     repositories.ClassRepository
+	parameterRepo repositories.ParameterRepository
+	outbox        *outbox.Outbox
+	knowledgeBase knowledge_base.KnowledgeBase
+	approver      Approver
+}
+
+func NewReconciler(serviceRepo repositories.ServiceRepository, classRepo repositories.ClassRepository, parameterRepo repositories.ParameterRepository, outbox *outbox.Outbox, knowledgeBase knowledge_base.KnowledgeBase, approver Approver) *Reconciler {
+	return &Reconciler{
+		serviceRepo:   serviceRepo,
+		classRepo:     classRepo,
+		parameterRepo: parameterRepo,
+		outbox:        outbox,
+		knowledgeBase: knowledgeBase,
+		approver:      approver,
+	}
+}
+
+// Run compares Postgres with the graph and, unless opts.DryRun is set,
+// repairs every difference in opts.Direction. Entities with outbox events
+// that are not applied yet are reported as pending and left alone. Repairs
+// are best effort: a failed repair is reported and the remaining ones still
+// run.
+func (r *Reconciler) Run(ctx context.Context, opts Options) (*Report, error) {
+	report := &Report{
+		DryRun:     opts.DryRun,
+		Direction:  opts.Direction,
+		Classes:    newEntityReport(),
+		Parameters: newEntityReport(),
+		Services:   newEntityReport(),
+	}
+
+	// An entity changed after startID may differ between the two listings,
+	// so do entities whose events are applied while the graph is listed.
+	// Both are caught by collecting the unapplied aggregates between the
+	// listings and again after them.
+	startID, err := r.outbox.LastID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the outbox: %w", err)
+	}
+
+	dbParameters, err := r.parameterRepo.List(0, -1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list parameters: %w", err)
+	}
+	dbClasses, err := r.classRepo.List(0, -1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list classes: %w", err)
+	}
+	dbServices, err := r.serviceRepo.ListApproved()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list approved services: %w", err)
+	}
+
+	pending, err := r.outbox.Unapplied(startID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the outbox: %w", err)
+	}
+
+	kbParameters, err := r.knowledgeBase.ListParameters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list graph parameters: %w", err)
+	}
+	kbClasses, err := r.knowledgeBase.ListClasses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list graph classes: %w", err)
+	}
+	kbServices, err := r.knowledgeBase.ListServices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list graph services: %w", err)
+	}
+
+	late, err := r.outbox.Unapplied(startID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the outbox: %w", err)
+	}
+	for aggregate := range late {
+		pending[aggregate] = struct{}{}
+	}
+
+	// Parameters created while repairing, so that classes referencing the
+	// same unknown parameter do not create it twice.
+	createdParameters := make(map[string]struct{})
+
+	var repairs []repair
+	repairs = append(repairs, r.compareParameters(report, opts.Direction, dbParameters, kbParameters, pending, createdParameters)...)
+	repairs = append(repairs, r.compareClasses(report, opts.Direction, dbClasses, kbClasses, dbParameters, pending, createdParameters)...)
+	repairs = append(repairs, r.compareServices(ctx, report, opts.Direction, dbServices, kbServices, pending)...)
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, repair := range repairs {
+		if err := repair.apply(); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", repair.entity, err))
+			continue
+		}
+		report.Repaired++
+	}
+
+	return report, nil
+}
+
+func (r *Reconciler) compareParameters(report *Report, direction Direction, db []models.Parameter, kb []string, pending, created map[string]struct{}) []repair {
+	inDB := make(map[string]models.Parameter, len(db))
+	for _, parameter := range db {
+		inDB[parameter.ID] = parameter
+	}
+	inKB := make(map[string]struct{}, len(kb))
+	for _, id := range kb {
+		inKB[id] = struct{}{}
+	}
+
+	var repairs []repair
+	for _, parameter := range db {
+		id := parameter.ID
+		if isPending(pending, outbox.ParameterEntity, id) {
+			report.Parameters.Pending = append(report.Parameters.Pending, id)
+			continue
+		}
+		if _, ok := inKB[parameter.ID]; ok {
+			continue
+		}
+		report.Parameters.Missing = append(report.Parameters.Missing, id)
+		if direction == ToKnowledgeBase {
+			repairs = append(repairs, repair{"parameter " + id, func() error {
+				return r.outbox.Record(outbox.Change{Operation: outbox.AddParameter, Payload: models.ParameterView{ID: id}})
+			}})
+		} else {
+			repairs = append(repairs, repair{"parameter " + id, func() error {
+				return r.deleteUnusedParameter(id)
+			}})
+		}
+	}
+
+	for _, id := range kb {
+		if _, ok := inDB[id]; ok {
+			continue
+		}
+		if isPending(pending, outbox.ParameterEntity, id) {
+			report.Parameters.Pending = append(report.Parameters.Pending, id)
+			continue
+		}
+		report.Parameters.Orphaned = append(report.Parameters.Orphaned, id)
+		if direction == ToKnowledgeBase {
+			repairs = append(repairs, repair{"parameter " + id, func() error {
+				return r.outbox.Record(outbox.Change{Operation: outbox.DeleteParameter, Payload: id})
+			}})
+		} else {
+			created[id] = struct{}{}
+			repairs = append(repairs, repair{"parameter " + id, func() error {
+				return r.parameterRepo.Create(&models.Parameter{ID: id, Title: id, New: true})
+			}})
+		}
+	}
+
+	return repairs
+}
+
+func (r *Reconciler) deleteUnusedParameter(id string) error {
+	services, err := r.serviceRepo.FindByParameterID(id)
+	if err != nil {
+		return err
+	}
+	if len(services) > 0 {
+		return fmt.Errorf("parameter is used in %d services", len(services))
+	}
+	return r.parameterRepo.Delete(id)
+}
+
+func (r *Reconciler) compareClasses(report *Report, direction Direction, db []models.Class, kb []models.ClassView, parameters []models.Parameter, pending, createdParameters map[string]struct{}) []repair {
+	inDB := make(map[uint]models.Class, len(db))
+	for _, class := range db {
+		inDB[class.ID] = class
+	}
+	inKB := make(map[uint]models.ClassView, len(kb))
+	for _, class := range kb {
+		inKB[class.ID] = class
+	}
+	knownParameters := make(map[string]struct{}, len(parameters))
+	for _, parameter := range parameters {
+		knownParameters[parameter.ID] = struct{}{}
+	}
+
+	var repairs []repair
+	for _, class := range db {
+		id := strconv.FormatUint(uint64(class.ID), 10)
+		if isPending(pending, outbox.ClassEntity, class.ID) {
+			report.Classes.Pending = append(report.Classes.Pending, id)
+			continue
+		}
+		view, ok := inKB[class.ID]
+		if !ok {
+			report.Classes.Missing = append(report.Classes.Missing, id)
+			if direction == ToKnowledgeBase {
+				// The parameter rules only live in the graph, so the
+				// class comes back without constraints.
+				view := models.ClassView{ID: class.ID, Title: class.Title, ParentID: class.ParentID}
+				repairs = append(repairs, repair{"class " + id, func() error {
+					return r.outbox.Record(outbox.Change{Operation: outbox.AddClass, Payload: view})
+				}})
+			} else {
+				classID := class.ID
+				repairs = append(repairs, repair{"class " + id, func() error {
+					return r.deleteUnusedClass(classID)
+				}})
+			}
+			continue
+		}
+
+		fixed := models.ClassView{ID: class.ID, Title: class.Title, ParentID: class.ParentID}
+		var unknown []string
+		rules := []struct {
+			field string
+			graph []string
+			fixed *[]string
+		}{
+			{"allowed_parameters", view.AllowedParameters, &fixed.AllowedParameters},
+			{"required_parameters", view.RequiredParameters, &fixed.RequiredParameters},
+			{"forbidden_parameters", view.ForbiddenParameters, &fixed.ForbiddenParameters},
+		}
+		for _, rule := range rules {
+			var ruleUnknown []string
+			for _, parameter := range rule.graph {
+				if _, ok := knownParameters[parameter]; ok {
+					*rule.fixed = append(*rule.fixed, parameter)
+				} else {
+					ruleUnknown = append(ruleUnknown, parameter)
+				}
+			}
+			if len(ruleUnknown) == 0 {
+				continue
+			}
+			unknown = append(unknown, ruleUnknown...)
+			report.Classes.Mismatched = append(report.Classes.Mismatched, Mismatch{
+				ID:            id,
+				Field:         rule.field,
+				Database:      *rule.fixed,
+				KnowledgeBase: rule.graph,
+			})
+		}
+		parentMismatch := !equalClassID(class.ParentID, view.ParentID)
+		if len(unknown) == 0 && !parentMismatch {
+			continue
+		}
+		if parentMismatch {
+			report.Classes.Mismatched = append(report.Classes.Mismatched, Mismatch{
+				ID:            id,
+				Field:         "parent_id",
+				Database:      class.ParentID,
+				KnowledgeBase: view.ParentID,
+			})
+		}
+		if direction == ToKnowledgeBase {
+			repairs = append(repairs, repair{"class " + id, func() error {
+				return r.outbox.Record(outbox.Change{Operation: outbox.UpdateClass, Payload: fixed})
+			}})
+		} else {
+			if parentMismatch {
+				model := class
+				model.ParentID = view.ParentID
+				repairs = append(repairs, repair{"class " + id, func() error {
+					return r.classRepo.Update(&model)
+				}})
+			}
+			for _, parameter := range unknown {
+				if _, ok := createdParameters[parameter]; ok {
+					continue
+				}
+				createdParameters[parameter] = struct{}{}
+				parameterID := parameter
+				repairs = append(repairs, repair{"parameter " + parameterID, func() error {
+					return r.parameterRepo.Create(&models.Parameter{ID: parameterID, Title: parameterID, New: true})
+				}})
+			}
+		}
+	}
+
+	for _, view := range kb {
+		if _, ok := inDB[view.ID]; ok {
+			continue
+		}
+		id := strconv.FormatUint(uint64(view.ID), 10)
+		if isPending(pending, outbox.ClassEntity, view.ID) {
+			report.Classes.Pending = append(report.Classes.Pending, id)
+			continue
+		}
+		report.Classes.Orphaned = append(report.Classes.Orphaned, id)
+		classID, parentID := view.ID, view.ParentID
+		if direction == ToKnowledgeBase {
+			repairs = append(repairs, repair{"class " + id, func() error {
+				return r.outbox.Record(outbox.Change{Operation: outbox.DeleteClass, Payload: classID})
+			}})
+		} else {
+			repairs = append(repairs, repair{"class " + id, func() error {
+				return r.classRepo.Create(&models.Class{ID: classID, Title: "class_" + id, ParentID: parentID, New: true})
+			}})
+		}
+	}
+
+	return repairs
+}
+
+func (r *Reconciler) deleteUnusedClass(id uint) error {
+	services, err := r.serviceRepo.FindByClassID(id)
+	if err != nil {
+		return err
+	}
+	if len(services) > 0 {
+		return fmt.Errorf("class is used in %d services", len(services))
+	}
+	return r.classRepo.Delete(id)
+}
+
+func (r *Reconciler) compareServices(ctx context.Context, report *Report, direction Direction, db []models.Service, kb []models.Service, pending map[string]struct{}) []repair {
+	inDB := make(map[uint]models.Service, len(db))
+	for _, service := range db {
+		inDB[service.ID] = service
+	}
+	inKB := make(map[uint]models.Service, len(kb))
+	for _, service := range kb {
+		inKB[service.ID] = service
+	}
+
+	var repairs []repair
+	for _, service := range db {
+		id := strconv.FormatUint(uint64(service.ID), 10)
+		if isPending(pending, outbox.ServiceEntity, service.ID) {
+			report.Services.Pending = append(report.Services.Pending, id)
+			continue
+		}
+		graph, ok := inKB[service.ID]
+		if !ok {
+			report.Services.Missing = append(report.Services.Missing, id)
+			if direction == ToKnowledgeBase {
+				repairs = append(repairs, repair{"service " + id, func() error {
+					return r.outbox.Record(outbox.Change{Operation: outbox.AddService, Payload: &service})
+				}})
+			} else {
+				serviceID := service.ID
+				repairs = append(repairs, repair{"service " + id, func() error {
+					return r.serviceRepo.Unapprove(serviceID)
+				}})
+			}
+			continue
+		}
+
+		classMismatch := !equalClassID(service.ClassID, graph.ClassID)
+		if classMismatch {
+			report.Services.Mismatched = append(report.Services.Mismatched, Mismatch{
+				ID:            id,
+				Field:         "class_id",
+				Database:      service.ClassID,
+				KnowledgeBase: graph.ClassID,
+			})
+		}
+		dbParameters, kbParameters := parameterIDs(service.Parameters), parameterIDs(graph.Parameters)
+		parametersMismatch := !slices.Equal(dbParameters, kbParameters)
+		if parametersMismatch {
+			report.Services.Mismatched = append(report.Services.Mismatched, Mismatch{
+				ID:            id,
+				Field:         "parameters",
+				Database:      dbParameters,
+				KnowledgeBase: kbParameters,
+			})
+		}
+		if !classMismatch && !parametersMismatch {
+			continue
+		}
+
+		if direction == ToKnowledgeBase {
+			repairs = append(repairs, repair{"service " + id, func() error {
+				return r.outbox.Record(
+					outbox.Change{Operation: outbox.DeleteService, Payload: service.ID},
+					outbox.Change{Operation: outbox.AddService, Payload: &service},
+				)
+			}})
+			continue
+		}
+		if classMismatch {
+			classID := graph.ClassID
+			repairs = append(repairs, repair{"service " + id, func() error {
+				return r.setServiceClass(service.ID, classID)
+			}})
+		}
+		if parametersMismatch {
+			repairs = append(repairs, repair{"service " + id, func() error {
+				return fmt.Errorf("parameters can only be repaired in the %q direction", ToKnowledgeBase)
+			}})
+		}
+	}
+
+	for _, graph := range kb {
+		if _, ok := inDB[graph.ID]; ok {
+			continue
+		}
+		id := strconv.FormatUint(uint64(graph.ID), 10)
+		if isPending(pending, outbox.ServiceEntity, graph.ID) {
+			report.Services.Pending = append(report.Services.Pending, id)
+			continue
+		}
+		report.Services.Orphaned = append(report.Services.Orphaned, id)
+		serviceID, classID := graph.ID, graph.ClassID
+		if direction == ToKnowledgeBase {
+			repairs = append(repairs, repair{"service " + id, func() error {
+				return r.outbox.Record(outbox.Change{Operation: outbox.DeleteService, Payload: serviceID})
+			}})
+		} else {
+			repairs = append(repairs, repair{"service " + id, func() error {
+				return r.approveFromGraph(ctx, serviceID, classID)
+			}})
+		}
+	}
+
+	return repairs
+}
+
+func (r *Reconciler) setServiceClass(serviceID uint, classID *uint) error {
+	if classID == nil {
+		return fmt.Errorf("the graph has no class for the service")
+	}
+	service, err := r.serviceRepo.GetByID(serviceID)
+	if err != nil {
+		return err
+	}
+	class, err := r.classRepo.GetByID(*classID)
+	if err != nil {
+		return err
+	}
+	service.ClassID = classID
+	service.Class = class
+	return r.serviceRepo.Update(service)
+}
+
+// approveFromGraph approves a service that the graph already knows about
+// the way an expert would. A service that cannot be approved in its status
+// or whose parameters break the class rules is reported, not forced.
+// Services deleted from Postgres cannot be restored from the graph.
+func (r *Reconciler) approveFromGraph(ctx context.Context, serviceID uint, classID *uint) error {
+	if classID == nil {
+		return fmt.Errorf("the graph has no class for the service")
+	}
+	service, err := r.serviceRepo.GetByID(serviceID)
+	if err != nil {
+		return err
+	}
+	class, err := r.classRepo.GetByID(*classID)
+	if err != nil {
+		return err
+	}
+	violations, err := r.knowledgeBase.ValidateClass(ctx, service, class.ID)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("conflict: the parameters break %d rules of class %d, approve the service by hand", len(violations), class.ID)
+	}
+	if err := r.approver.ApproveService(service, class, ""); err != nil {
+		return fmt.Errorf("conflict: %w", err)
+	}
+	return nil
+}
+
+func isPending(pending map[string]struct{}, entity string, id any) bool {
+	_, ok := pending[outbox.Aggregate(entity, id)]
+	return ok
+}
+
+func equalClassID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func parameterIDs(parameters []models.Parameter) []string {
+	ids := make([]string, 0, len(parameters))
+	for _, parameter := range parameters {
+		ids = append(ids, parameter.ID)
+	}
+	sort.Strings(ids)
+	return slices.Compact(ids)
+}
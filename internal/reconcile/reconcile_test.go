@@ -0,0 +1,418 @@
+package reconcile
+
+import (
+	"backend/internal/knowledge_base"
+	"backend/internal/models"
+	"backend/internal/outbox"
+	"backend/internal/repositories"
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// The fakes embed the repository interfaces and implement what the
+// reconciler uses.
+
+type fakeParameterRepo struct {
+	repositories.ParameterRepository
+	parameters map[string]models.Parameter
+}
+
+func (r *fakeParameterRepo) List(int, int) ([]models.Parameter, error) {
+	var parameters []models.Parameter
+	for _, parameter := range r.parameters {
+		parameters = append(parameters, parameter)
+	}
+	slices.SortFunc(parameters, func(a, b models.Parameter) int { return cmp.Compare(a.ID, b.ID) })
+	return parameters, nil
+}
+
+func (r *fakeParameterRepo) Create(parameter *models.Parameter) error {
+	r.parameters[parameter.ID] = *parameter
+	return nil
+}
+
+func (r *fakeParameterRepo) Delete(id string) error {
+	delete(r.parameters, id)
+	return nil
+}
+
+type fakeClassRepo struct {
+	repositories.ClassRepository
+	classes map[uint]models.Class
+}
+
+func (r *fakeClassRepo) List(int, int) ([]models.Class, error) {
+	var classes []models.Class
+	for _, class := range r.classes {
+		classes = append(classes, class)
+	}
+	slices.SortFunc(classes, func(a, b models.Class) int { return cmp.Compare(a.ID, b.ID) })
+	return classes, nil
+}
+
+func (r *fakeClassRepo) GetByID(id uint) (*models.Class, error) {
+	class, ok := r.classes[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &class, nil
+}
+
+func (r *fakeClassRepo) Create(class *models.Class) error {
+	r.classes[class.ID] = *class
+	return nil
+}
+
+func (r *fakeClassRepo) Update(class *models.Class) error {
+	r.classes[class.ID] = *class
+	return nil
+}
+
+func (r *fakeClassRepo) Delete(id uint) error {
+	delete(r.classes, id)
+	return nil
+}
+
+type fakeServiceRepo struct {
+	repositories.ServiceRepository
+	services map[uint]models.Service
+}
+
+func (r *fakeServiceRepo) all() []models.Service {
+	var services []models.Service
+	for _, service := range r.services {
+		services = append(services, service)
+	}
+	slices.SortFunc(services, func(a, b models.Service) int { return cmp.Compare(a.ID, b.ID) })
+	return services
+}
+
+func (r *fakeServiceRepo) ListApproved() ([]models.Service, error) {
+	var services []models.Service
+	for _, service := range r.all() {
+		if service.Status == models.ServiceApproved {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+func (r *fakeServiceRepo) FindByClassID(classID uint) ([]models.Service, error) {
+	var services []models.Service
+	for _, service := range r.all() {
+		if service.ClassID != nil && *service.ClassID == classID {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+func (r *fakeServiceRepo) FindByParameterID(parameterID string) ([]models.Service, error) {
+	var services []models.Service
+	for _, service := range r.all() {
+		if slices.Contains(parameterIDs(service.Parameters), parameterID) {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+func (r *fakeServiceRepo) GetByID(id uint) (*models.Service, error) {
+	service, ok := r.services[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &service, nil
+}
+
+func (r *fakeServiceRepo) Update(service *models.Service) error {
+	r.services[service.ID] = *service
+	return nil
+}
+
+func (r *fakeServiceRepo) Unapprove(id uint) error {
+	service := r.services[id]
+	service.ApprovedAt = nil
+	service.Status = models.ServiceNeedsReview
+	r.services[id] = service
+	return nil
+}
+
+// fakeApprover approves like the service layer, without the feedback.
+type fakeApprover struct {
+	services *fakeServiceRepo
+	outbox   *outbox.Outbox
+}
+
+func (a *fakeApprover) ApproveService(service *models.Service, class *models.Class, justification string) error {
+	if service.Status != models.ServiceNeedsReview {
+		return errors.New("invalid status transition")
+	}
+	now := time.Now()
+	service.Status = models.ServiceApproved
+	service.ClassID = &class.ID
+	service.ApprovedAt = &now
+	service.OverrideJustification = justification
+	a.services.services[service.ID] = *service
+	return a.outbox.Record(outbox.Change{Operation: outbox.AddService, Payload: service})
+}
+
+// fakeOutboxRepo keeps events in memory. pending lists aggregates of
+// changes that are waiting in the outbox.
+type fakeOutboxRepo struct {
+	repositories.OutboxRepository
+	events  []models.OutboxEvent
+	pending []string
+}
+
+func (r *fakeOutboxRepo) CreateAll(events []models.OutboxEvent) error {
+	for _, event := range events {
+		event.ID = uint(len(r.events) + 1)
+		r.events = append(r.events, event)
+	}
+	return nil
+}
+
+func (r *fakeOutboxRepo) LastID() (uint, error) {
+	return uint(len(r.events)), nil
+}
+
+func (r *fakeOutboxRepo) UnappliedAggregates(afterID uint) ([]string, error) {
+	aggregates := slices.Clone(r.pending)
+	for _, event := range r.events {
+		if event.Status != models.OutboxDone || event.ID > afterID {
+			aggregates = append(aggregates, event.Aggregate)
+		}
+	}
+	return aggregates, nil
+}
+
+func (r *fakeOutboxRepo) ClaimNext(time.Time, time.Time) (*models.OutboxEvent, error) {
+	for i := range r.events {
+		if r.events[i].Status == models.OutboxPending {
+			r.events[i].Status = models.OutboxRunning
+			r.events[i].Attempts++
+			event := r.events[i]
+			return &event, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeOutboxRepo) Finish(event *models.OutboxEvent) error {
+	r.events[event.ID-1] = *event
+	return nil
+}
+
+type fixture struct {
+	parameters *fakeParameterRepo
+	classes    *fakeClassRepo
+	services   *fakeServiceRepo
+	outboxRepo *fakeOutboxRepo
+	outbox     *outbox.Outbox
+	kb         *knowledge_base.Memory
+	reconciler *Reconciler
+}
+
+// newFixture sets up both stores with one entity of each kind in sync and
+// the following differences:
+//   - parameter voice and class 3 only exist in Postgres (missing),
+//   - parameter iot and class 4 only exist in the graph (orphaned),
+//   - class 2 has a parent in the graph only (mismatched),
+//   - approved service 11 is missing from the graph, service 12 is in the
+//     graph but not approved in Postgres,
+//   - class 5 was just created and its event is still in the outbox.
+func newFixture(t *testing.T) *fixture {
+	ctx := context.Background()
+	classID := uint(1)
+	now := time.Now()
+	sms := models.Parameter{ID: "sms", Title: "SMS"}
+
+	f := &fixture{
+		parameters: &fakeParameterRepo{parameters: map[string]models.Parameter{
+			"sms":   sms,
+			"voice": {ID: "voice", Title: "Voice"},
+		}},
+		classes: &fakeClassRepo{classes: map[uint]models.Class{
+			1: {ID: 1, Title: "SMS"},
+			2: {ID: 2, Title: "Voice"},
+			3: {ID: 3, Title: "Unused"},
+			5: {ID: 5, Title: "New"},
+		}},
+		services: &fakeServiceRepo{services: map[uint]models.Service{
+			10: {ID: 10, ClassID: &classID, Parameters: []models.Parameter{sms}, Status: models.ServiceApproved, ApprovedAt: &now},
+			11: {ID: 11, ClassID: &classID, Parameters: []models.Parameter{sms}, Status: models.ServiceApproved, ApprovedAt: &now},
+			12: {ID: 12, Parameters: []models.Parameter{sms}, Status: models.ServiceNeedsReview},
+		}},
+		outboxRepo: &fakeOutboxRepo{pending: []string{"class:5"}},
+		kb:         knowledge_base.NewMemory(),
+	}
+	f.outbox = outbox.New(f.outboxRepo)
+	f.reconciler = NewReconciler(f.services, f.classes, f.parameters, f.outbox, f.kb, &fakeApprover{services: f.services, outbox: f.outbox})
+
+	require.NoError(t, f.kb.AddParameter(ctx, models.ParameterView{ID: "sms"}))
+	require.NoError(t, f.kb.AddParameter(ctx, models.ParameterView{ID: "iot"}))
+	require.NoError(t, f.kb.AddClass(ctx, models.ClassView{ID: 1, AllowedParameters: []string{"sms"}}))
+	require.NoError(t, f.kb.AddClass(ctx, models.ClassView{ID: 2, ParentID: &classID}))
+	require.NoError(t, f.kb.AddClass(ctx, models.ClassView{ID: 4}))
+	require.NoError(t, f.kb.AddService(ctx, &models.Service{ID: 10, ClassID: &classID, Parameters: []models.Parameter{sms}}))
+	require.NoError(t, f.kb.AddService(ctx, &models.Service{ID: 12, ClassID: &classID, Parameters: []models.Parameter{sms}}))
+	return f
+}
+
+func wantReport(dryRun bool, direction Direction) *Report {
+	parent := uint(1)
+	return &Report{
+		DryRun:    dryRun,
+		Direction: direction,
+		Parameters: EntityReport{
+			Missing:    []string{"voice"},
+			Orphaned:   []string{"iot"},
+			Mismatched: []Mismatch{},
+			Pending:    []string{},
+		},
+		Classes: EntityReport{
+			Missing:    []string{"3"},
+			Orphaned:   []string{"4"},
+			Mismatched: []Mismatch{{ID: "2", Field: "parent_id", Database: (*uint)(nil), KnowledgeBase: &parent}},
+			Pending:    []string{"5"},
+		},
+		Services: EntityReport{
+			Missing:    []string{"11"},
+			Orphaned:   []string{"12"},
+			Mismatched: []Mismatch{},
+			Pending:    []string{},
+		},
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	for _, direction := range []Direction{ToKnowledgeBase, ToDatabase} {
+		t.Run(string(direction), func(t *testing.T) {
+			f := newFixture(t)
+			report, err := f.reconciler.Run(context.Background(), Options{DryRun: true, Direction: direction})
+			require.NoError(t, err)
+			assert.Equal(t, wantReport(true, direction), report)
+
+			assert.Empty(t, f.outboxRepo.events)
+			assert.Len(t, f.parameters.parameters, 2)
+			assert.Len(t, f.classes.classes, 4)
+			assert.Equal(t, models.ServiceApproved, f.services.services[11].Status)
+		})
+	}
+}
+
+func TestRunToKnowledgeBase(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+
+	report, err := f.reconciler.Run(ctx, Options{Direction: ToKnowledgeBase})
+	require.NoError(t, err)
+	want := wantReport(false, ToKnowledgeBase)
+	want.Repaired = 7
+	assert.Equal(t, want, report)
+
+	// The graph is only changed through the outbox.
+	var aggregates []string
+	for _, event := range f.outboxRepo.events {
+		aggregates = append(aggregates, event.Operation+" "+event.Aggregate)
+	}
+	assert.Equal(t, []string{
+		"add_parameter parameter:voice",
+		"delete_parameter parameter:iot",
+		"update_class class:2",
+		"add_class class:3",
+		"delete_class class:4",
+		"add_service service:11",
+		"delete_service service:12",
+	}, aggregates)
+	parameters, err := f.kb.ListParameters(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"iot", "sms"}, parameters)
+
+	dispatcher := outbox.NewDispatcher(f.outbox, f.kb, time.Second, 1)
+	require.NoError(t, dispatcher.DispatchPending(ctx))
+
+	report, err = f.reconciler.Run(ctx, Options{DryRun: true, Direction: ToKnowledgeBase})
+	require.NoError(t, err)
+	assert.Empty(t, report.Parameters.Missing)
+	assert.Empty(t, report.Parameters.Orphaned)
+	assert.Empty(t, report.Classes.Missing)
+	assert.Empty(t, report.Classes.Orphaned)
+	assert.Empty(t, report.Classes.Mismatched)
+	assert.Equal(t, []string{"5"}, report.Classes.Pending)
+	assert.Empty(t, report.Services.Missing)
+	assert.Empty(t, report.Services.Orphaned)
+}
+
+func TestRunToDatabase(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+
+	report, err := f.reconciler.Run(ctx, Options{Direction: ToDatabase})
+	require.NoError(t, err)
+	want := wantReport(false, ToDatabase)
+	want.Repaired = 7
+	assert.Equal(t, want, report)
+	// Only the approval goes to the outbox, like one made by an expert.
+	require.Len(t, f.outboxRepo.events, 1)
+	assert.Equal(t, "add_service service:12", f.outboxRepo.events[0].Operation+" "+f.outboxRepo.events[0].Aggregate)
+
+	assert.NotContains(t, f.parameters.parameters, "voice")
+	assert.Contains(t, f.parameters.parameters, "iot")
+	assert.NotContains(t, f.classes.classes, uint(3))
+	assert.Equal(t, "class_4", f.classes.classes[4].Title)
+	require.NotNil(t, f.classes.classes[2].ParentID)
+	assert.Equal(t, uint(1), *f.classes.classes[2].ParentID)
+	// The class created seconds ago is left alone.
+	assert.Contains(t, f.classes.classes, uint(5))
+
+	assert.Equal(t, models.ServiceNeedsReview, f.services.services[11].Status)
+	assert.Nil(t, f.services.services[11].ApprovedAt)
+	assert.Equal(t, models.ServiceApproved, f.services.services[12].Status)
+	require.NotNil(t, f.services.services[12].ClassID)
+	assert.Equal(t, uint(1), *f.services.services[12].ClassID)
+}
+
+func TestRunToDatabaseApprovalConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		service models.Service
+		want    string
+	}{
+		{
+			name:    "Rejected service",
+			service: models.Service{ID: 12, Parameters: []models.Parameter{{ID: "sms"}}, Status: models.ServiceRejected},
+			want:    "service 12: conflict: invalid status transition",
+		},
+		{
+			name:    "Parameters break the class rules",
+			service: models.Service{ID: 12, Parameters: []models.Parameter{{ID: "sms"}, {ID: "iot"}}, Status: models.ServiceNeedsReview},
+			want:    "service 12: conflict: the parameters break 1 rules of class 1, approve the service by hand",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.services.services[12] = tt.service
+
+			report, err := f.reconciler.Run(context.Background(), Options{Direction: ToDatabase})
+			require.NoError(t, err)
+			assert.Equal(t, []string{tt.want}, report.Errors)
+			assert.Equal(t, 6, report.Repaired)
+
+			assert.Equal(t, tt.service.Status, f.services.services[12].Status)
+			assert.Nil(t, f.services.services[12].ClassID)
+			assert.Empty(t, f.outboxRepo.events)
+		})
+	}
+}
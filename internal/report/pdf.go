@@ -0,0 +1,133 @@
+package report
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// PDF layout in millimetres on landscape A4.
+const (
+	pdfMargin       = 10.0
+	pdfTitleWidth   = 70.0
+	pdfAmountWidth  = 28.0
+	pdfRowHeight    = 6.0
+	pdfLevelIndent  = 4.0
+	pdfHeaderHeight = 8.0
+)
+
+// pdfColors follow the fills of the XLSX styles.
+var (
+	pdfTitleFill    = [3]int{0xD9, 0xE1, 0xF2}
+	pdfSubtitleFill = [3]int{0xFC, 0xE4, 0xD6}
+	pdfTotalFill    = [3]int{0xFF, 0x57, 0x33}
+)
+
+// WritePDF renders the table as a PDF with the same rows and columns as the
+// XLSX sheet, followed by the summary page. Periods that do not fit on one
+// page continue on the next pages, the total column is on the last one. The
+// built-in fonts only cover Windows-1252, other characters are replaced.
+func WritePDF(w io.Writer, table *Table) error {
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	perPage := max(int((pageWidth-2*pdfMargin-pdfTitleWidth)/pdfAmountWidth), 2)
+
+	// Columns after the title column: the service count, the periods and
+	// the total.
+	header := table.Header()
+	columns := len(header) - 1
+	for first := 0; first < columns; first += perPage {
+		last := min(first+perPage, columns)
+
+		drawHeader := func() {
+			pdf.AddPage()
+			pdf.SetFont("Helvetica", "B", 14)
+			fill(pdf, pdfTitleFill)
+			pdf.CellFormat(0, pdfHeaderHeight, tr(table.Title), "1", 1, "C", true, 0, "")
+			pdf.SetFont("Helvetica", "B", 10)
+			fill(pdf, pdfSubtitleFill)
+			pdf.CellFormat(pdfTitleWidth, pdfRowHeight, tr(header[0]), "1", 0, "L", true, 0, "")
+			for _, title := range header[first+1 : last+1] {
+				pdf.CellFormat(pdfAmountWidth, pdfRowHeight, tr(title), "1", 0, "C", true, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+		drawHeader()
+
+		for _, row := range table.Rows {
+			if pdf.GetY()+pdfRowHeight > pageHeight-pdfMargin {
+				drawHeader()
+			}
+
+			style, filled, colors := "", false, [3]int{255, 255, 255}
+			switch row.Kind {
+			case Group:
+				style = "B"
+			case Subtotal:
+				style, filled, colors = "B", true, pdfSubtitleFill
+			case Total:
+				style, filled, colors = "B", true, pdfTotalFill
+			}
+			pdf.SetFont("Helvetica", style, 9)
+			fill(pdf, colors)
+			if row.Kind == Total {
+				pdf.SetTextColor(255, 255, 255)
+			}
+
+			indent := float64(row.Level) * pdfLevelIndent
+			pdf.CellFormat(pdfTitleWidth, pdfRowHeight, tr(pad(indent, pdf)+row.Title), "1", 0, "L", filled, 0, "")
+			for column := first; column < last; column++ {
+				text := ""
+				switch {
+				case row.Amounts == nil:
+				case column == 0:
+					text = fmt.Sprint(row.Services)
+				case column == columns-1:
+					text = FormatAmount(row.Sum(), table.Currency)
+				default:
+					text = FormatAmount(row.Amounts[column-1], table.Currency)
+				}
+				pdf.CellFormat(pdfAmountWidth, pdfRowHeight, tr(text), "1", 0, "R", filled, 0, "")
+			}
+			pdf.Ln(-1)
+			pdf.SetTextColor(0, 0, 0)
+		}
+	}
+
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 14)
+	fill(pdf, pdfTitleFill)
+	pdf.CellFormat(0, pdfHeaderHeight, "Summary", "1", 1, "C", true, 0, "")
+	pdf.Ln(2)
+	for _, line := range table.SummaryLines() {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(pdfTitleWidth, pdfHeaderHeight, tr(line[0]), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.CellFormat(0, pdfHeaderHeight, tr(line[1]), "", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+func fill(pdf *fpdf.Fpdf, color [3]int) {
+	pdf.SetFillColor(color[0], color[1], color[2])
+}
+
+// pad returns spaces about width millimetres wide in the current font.
+func pad(width float64, pdf *fpdf.Fpdf) string {
+	if width <= 0 {
+		return ""
+	}
+	space := pdf.GetStringWidth(" ")
+	n := int(width / space)
+	text := make([]byte, n)
+	for i := range text {
+		text[i] = ' '
+	}
+	return string(text)
+}
@@ -0,0 +1,486 @@
+// Package report builds the fiscal report table: revenue of approved
+// services grouped by class or parameter and split into periods. The table
+// is the layout every renderer writes.
+package report
+
+import (
+	"backend/internal/models"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Granularity is the length of the period columns.
+type Granularity string
+
+const (
+	Month   Granularity = "month"
+	Quarter Granularity = "quarter"
+	Year    Granularity = "year"
+)
+
+func ParseGranularity(value string) (Granularity, error) {
+	switch granularity := Granularity(value); granularity {
+	case Month, Quarter, Year:
+		return granularity, nil
+	default:
+		return "", fmt.Errorf("unknown granularity %q, use month, quarter or year", value)
+	}
+}
+
+// start returns the beginning of the period containing t.
+func (g Granularity) start(t time.Time) time.Time {
+	month := t.Month()
+	switch g {
+	case Quarter:
+		month = (month-1)/3*3 + 1
+	case Year:
+		month = time.January
+	}
+	return time.Date(t.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (g Granularity) next(t time.Time) time.Time {
+	switch g {
+	case Quarter:
+		return t.AddDate(0, 3, 0)
+	case Year:
+		return t.AddDate(1, 0, 0)
+	default:
+		return t.AddDate(0, 1, 0)
+	}
+}
+
+func (g Granularity) label(t time.Time) string {
+	switch g {
+	case Quarter:
+		return fmt.Sprintf("Q%d %d", (int(t.Month())+2)/3, t.Year())
+	case Year:
+		return t.Format("2006")
+	default:
+		return t.Format("2006-01")
+	}
+}
+
+// Period returns the period of the granularity containing t.
+func (g Granularity) Period(t time.Time) Period {
+	start := g.start(t)
+	return Period{Start: start, End: g.next(start), Label: g.label(start)}
+}
+
+// Grouping decides what the rows of the report are.
+type Grouping string
+
+const (
+	// ByClass has one row per financial class.
+	ByClass Grouping = "class"
+	// ByHierarchy nests classes under their parents with subtotals.
+	ByHierarchy Grouping = "hierarchy"
+	// ByParameter has one row per parameter. A service counts towards every
+	// parameter it has, so the rows do not add up to the grand total.
+	ByParameter Grouping = "parameter"
+)
+
+func ParseGrouping(value string) (Grouping, error) {
+	switch grouping := Grouping(value); grouping {
+	case ByClass, ByHierarchy, ByParameter:
+		return grouping, nil
+	default:
+		return "", fmt.Errorf("unknown grouping %q, use class, hierarchy or parameter", value)
+	}
+}
+
+// Format is the file format of the rendered report.
+type Format string
+
+const (
+	XLSX Format = "xlsx"
+	PDF  Format = "pdf"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(value); format {
+	case XLSX, PDF:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, use xlsx or pdf", value)
+	}
+}
+
+// ContentType is the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == PDF {
+		return "application/pdf"
+	}
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+
+// FileName is the name of the report file for options.
+func (f Format) FileName(options Options) string {
+	return "report_profit_" + options.Name() + "." + string(f)
+}
+
+// MaxPeriods limits the number of period columns.
+const MaxPeriods = 120
+
+// Options select the report content. Revenue is recorded per month, so the
+// range covers whole months.
+type Options struct {
+	// From is the first day of the first month, To the first day after the
+	// last month.
+	From        time.Time
+	To          time.Time
+	Granularity Granularity
+	Grouping    Grouping
+	Currency    string
+}
+
+// NewOptions extends the dates from and to, both included, to whole months.
+func NewOptions(from, to time.Time, granularity Granularity, grouping Grouping, currency string) (Options, error) {
+	options := Options{
+		From:        time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(to.Year(), to.Month()+1, 1, 0, 0, 0, 0, time.UTC),
+		Granularity: granularity,
+		Grouping:    grouping,
+		Currency:    currency,
+	}
+	if !options.From.Before(options.To) {
+		return Options{}, fmt.Errorf("from date must not be after to date")
+	}
+	if n := len(options.Periods()); n > MaxPeriods {
+		return Options{}, fmt.Errorf("%d periods requested, at most %d are allowed", n, MaxPeriods)
+	}
+	return options, nil
+}
+
+// Period is one column of the report, End is exclusive.
+type Period struct {
+	Start time.Time
+	End   time.Time
+	Label string
+}
+
+// Periods splits the range by granularity. The first and last period may
+// extend beyond the range.
+func (o Options) Periods() []Period {
+	var periods []Period
+	for start := o.Granularity.start(o.From); start.Before(o.To); start = o.Granularity.next(start) {
+		periods = append(periods, Period{Start: start, End: o.Granularity.next(start), Label: o.Granularity.label(start)})
+	}
+	return periods
+}
+
+// Name describes the range for file names, e.g. 2024, 2024-Q2, 2024-03 or
+// 2024-01_2024-06.
+func (o Options) Name() string {
+	return o.describe("_")
+}
+
+// Label describes the range for titles.
+func (o Options) Label() string {
+	return o.describe(" – ")
+}
+
+func (o Options) describe(separator string) string {
+	switch {
+	case o.From.Month() == time.January && o.To.Equal(o.From.AddDate(1, 0, 0)):
+		return o.From.Format("2006")
+	case (o.From.Month()-1)%3 == 0 && o.To.Equal(o.From.AddDate(0, 3, 0)):
+		return fmt.Sprintf("%d-Q%d", o.From.Year(), (int(o.From.Month())+2)/3)
+	case o.To.Equal(o.From.AddDate(0, 1, 0)):
+		return o.From.Format("2006-01")
+	default:
+		return o.From.Format("2006-01") + separator + o.To.AddDate(0, -1, 0).Format("2006-01")
+	}
+}
+
+type RowKind string
+
+const (
+	// Item rows hold the revenue of one class or parameter.
+	Item RowKind = "item"
+	// Group rows head a class with subclasses and hold its own revenue.
+	Group    RowKind = "group"
+	Subtotal RowKind = "subtotal"
+	Total    RowKind = "total"
+)
+
+// Row is one line of the table with an amount per period.
+type Row struct {
+	Title string
+	Level int
+	Kind  RowKind
+	// Services is the number of approved services behind the row.
+	Services int
+	Amounts  []float64
+}
+
+// Sum is the amount of all periods.
+func (r Row) Sum() float64 {
+	sum := 0.0
+	for _, amount := range r.Amounts {
+		sum += amount
+	}
+	return sum
+}
+
+// Table is the report layout shared by the renderers.
+type Table struct {
+	Title      string
+	GroupTitle string
+	Currency   string
+	Periods    []Period
+	Rows       []Row
+	// Summary is shown on a page of its own when it is set.
+	Summary *Summary
+}
+
+// Header returns the column titles: the grouping, the service count, the
+// periods and the total.
+func (t *Table) Header() []string {
+	header := make([]string, 0, len(t.Periods)+3)
+	header = append(header, t.GroupTitle, "Services")
+	for _, period := range t.Periods {
+		header = append(header, period.Label)
+	}
+	return append(header, "Total")
+}
+
+// GrandTotal is the sum of the total row.
+func (t *Table) GrandTotal() float64 {
+	for _, row := range t.Rows {
+		if row.Kind == Total {
+			return row.Sum()
+		}
+	}
+	return 0
+}
+
+// Summary counts the services behind the report.
+type Summary struct {
+	Approved int
+	// Pending services are neither approved nor rejected yet.
+	Pending  int
+	Rejected int
+}
+
+// NewSummary sorts the service counts by status into the summary.
+func NewSummary(counts map[models.ServiceStatus]int) *Summary {
+	summary := &Summary{}
+	for status, count := range counts {
+		switch status {
+		case models.ServiceApproved:
+			summary.Approved += count
+		case models.ServiceRejected:
+			summary.Rejected += count
+		default:
+			summary.Pending += count
+		}
+	}
+	return summary
+}
+
+// SummaryLines returns the label and value of each summary line.
+func (t *Table) SummaryLines() [][2]string {
+	lines := [][2]string{
+		{"Period", t.Title},
+		{"Total revenue", FormatAmount(t.GrandTotal(), t.Currency)},
+	}
+	if t.Summary == nil {
+		return lines
+	}
+	return append(lines,
+		[2]string{"Approved services", fmt.Sprint(t.Summary.Approved)},
+		[2]string{"Pending services", fmt.Sprint(t.Summary.Pending)},
+		[2]string{"Rejected services", fmt.Sprint(t.Summary.Rejected)},
+	)
+}
+
+// FormatAmount writes amount like the XLSX number format of currency.
+func FormatAmount(amount float64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	cents := int64(math.Round(amount * 100))
+	digits := fmt.Sprint(cents / 100)
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	number := fmt.Sprintf("%s.%02d", grouped.String(), cents%100)
+
+	switch currency {
+	case "USD":
+		return sign + "$" + number
+	case "EUR":
+		return sign + "€" + number
+	default:
+		return sign + number + " " + currency
+	}
+}
+
+// Build groups the revenue by the grouping of options. classes are needed
+// for ByHierarchy, parameters for ByParameter, classRevenue always provides
+// the grand total.
+func Build(options Options, classes []models.Class, classRevenue []models.ClassRevenue, parameterRevenue []models.ParameterRevenue) *Table {
+	table := &Table{
+		Title:      fmt.Sprintf("%s, %s", options.Label(), options.Currency),
+		GroupTitle: "Financial Class",
+		Currency:   options.Currency,
+		Periods:    options.Periods(),
+	}
+	byClass := newAmounts(table.Periods)
+	for _, revenue := range classRevenue {
+		byClass.add(fmt.Sprint(revenue.ClassID), revenue.ClassTitle, revenue.ServiceCount, revenue.Month, revenue.Amount)
+	}
+
+	switch options.Grouping {
+	case ByHierarchy:
+		table.Rows = hierarchyRows(classes, byClass)
+	case ByParameter:
+		table.GroupTitle = "Parameter"
+		byParameter := newAmounts(table.Periods)
+		for _, revenue := range parameterRevenue {
+			byParameter.add(revenue.ParameterID, revenue.ParameterTitle, revenue.ServiceCount, revenue.Month, revenue.Amount)
+		}
+		table.Rows = byParameter.rows()
+	default:
+		table.Rows = byClass.rows()
+	}
+
+	total := Row{Title: "Total", Kind: Total, Amounts: make([]float64, len(table.Periods))}
+	for _, key := range byClass.keys {
+		addTo(total.Amounts, byClass.amounts[key])
+		total.Services += byClass.services[key]
+	}
+	table.Rows = append(table.Rows, total)
+	return table
+}
+
+// amounts collects the revenue per key and period in order of appearance.
+type amounts struct {
+	periods  []Period
+	keys     []string
+	titles   map[string]string
+	services map[string]int
+	amounts  map[string][]float64
+}
+
+func newAmounts(periods []Period) *amounts {
+	return &amounts{periods: periods, titles: map[string]string{}, services: map[string]int{}, amounts: map[string][]float64{}}
+}
+
+// add records amount for the month, a nil month only makes the key known.
+// services is the same on every row of a key.
+func (a *amounts) add(key, title string, services int, month *time.Time, amount float64) {
+	if _, ok := a.amounts[key]; !ok {
+		a.keys = append(a.keys, key)
+		a.titles[key] = title
+		a.services[key] = services
+		a.amounts[key] = make([]float64, len(a.periods))
+	}
+	if month == nil {
+		return
+	}
+	for i, period := range a.periods {
+		if !month.Before(period.Start) && month.Before(period.End) {
+			a.amounts[key][i] += amount
+			return
+		}
+	}
+}
+
+func (a *amounts) rows() []Row {
+	rows := make([]Row, 0, len(a.keys))
+	for _, key := range a.keys {
+		rows = append(rows, Row{Title: a.titles[key], Kind: Item, Services: a.services[key], Amounts: a.amounts[key]})
+	}
+	return rows
+}
+
+// hierarchyRows nests the classes with revenue under their ancestors. A
+// class with subclasses gets a group row with its own revenue and a
+// subtotal row after its subclasses.
+func hierarchyRows(classes []models.Class, byClass *amounts) []Row {
+	known := make(map[uint]models.Class, len(classes))
+	for _, class := range classes {
+		known[class.ID] = class
+	}
+	children := make(map[uint][]models.Class)
+	var roots []models.Class
+	for _, class := range classes {
+		if class.ParentID != nil {
+			if _, ok := known[*class.ParentID]; ok && *class.ParentID != class.ID {
+				children[*class.ParentID] = append(children[*class.ParentID], class)
+				continue
+			}
+		}
+		roots = append(roots, class)
+	}
+	byTitle := func(a, b models.Class) int {
+		return strings.Compare(a.Title, b.Title)
+	}
+	slices.SortFunc(roots, byTitle)
+	for _, list := range children {
+		slices.SortFunc(list, byTitle)
+	}
+
+	visited := make(map[uint]bool)
+	var walk func(class models.Class, level int) []Row
+	walk = func(class models.Class, level int) []Row {
+		if visited[class.ID] {
+			return nil
+		}
+		visited[class.ID] = true
+
+		var nested []Row
+		subtotal := Row{Title: class.Title + " total", Level: level, Kind: Subtotal}
+		for _, child := range children[class.ID] {
+			rows := walk(child, level+1)
+			if rows == nil {
+				continue
+			}
+			nested = append(nested, rows...)
+			if subtotal.Amounts == nil {
+				subtotal.Amounts = make([]float64, len(byClass.periods))
+			}
+			// The last row of a subtree covers all of it.
+			addTo(subtotal.Amounts, rows[len(rows)-1].Amounts)
+			subtotal.Services += rows[len(rows)-1].Services
+		}
+
+		key := fmt.Sprint(class.ID)
+		own, hasOwn := byClass.amounts[key]
+		row := Row{Title: class.Title, Level: level, Kind: Item, Services: byClass.services[key], Amounts: own}
+		switch {
+		case nested == nil && !hasOwn:
+			return nil
+		case nested == nil:
+			return []Row{row}
+		}
+		addTo(subtotal.Amounts, own)
+		subtotal.Services += row.Services
+		row.Kind = Group
+		rows := append([]Row{row}, nested...)
+		return append(rows, subtotal)
+	}
+
+	var rows []Row
+	for _, root := range roots {
+		rows = append(rows, walk(root, 0)...)
+	}
+	return rows
+}
+
+// addTo adds amounts to sum, nil amounts add nothing.
+func addTo(sum, amounts []float64) {
+	for i := range amounts {
+		sum[i] += amounts[i]
+	}
+}
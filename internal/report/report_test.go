@@ -0,0 +1,205 @@
+package report
+
+import (
+	"backend/internal/models"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func month(year int, m time.Month) *time.Time {
+	t := time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		from, to    time.Time
+		granularity Granularity
+		wantName    string
+		wantPeriods []string
+		wantErr     bool
+	}{
+		{
+			name:        "year by quarter",
+			from:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:          time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+			granularity: Quarter,
+			wantName:    "2024",
+			wantPeriods: []string{"Q1 2024", "Q2 2024", "Q3 2024", "Q4 2024"},
+		},
+		{
+			name:        "quarter by month",
+			from:        time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			to:          time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+			granularity: Month,
+			wantName:    "2024-Q2",
+			wantPeriods: []string{"2024-04", "2024-05", "2024-06"},
+		},
+		{
+			name:        "single month",
+			from:        time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+			to:          time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC),
+			granularity: Year,
+			wantName:    "2024-03",
+			wantPeriods: []string{"2024"},
+		},
+		{
+			name:        "months across years",
+			from:        time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC),
+			to:          time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			granularity: Quarter,
+			wantName:    "2023-11_2024-02",
+			wantPeriods: []string{"Q4 2023", "Q1 2024"},
+		},
+		{
+			name:        "reversed",
+			from:        time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			granularity: Month,
+			wantErr:     true,
+		},
+		{
+			name:        "too many periods",
+			from:        time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			granularity: Month,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := NewOptions(tt.from, tt.to, tt.granularity, ByClass, "USD")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, options.Name())
+
+			var labels []string
+			for _, period := range options.Periods() {
+				labels = append(labels, period.Label)
+			}
+			assert.Equal(t, tt.wantPeriods, labels)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	options, err := NewOptions(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC), Quarter, ByClass, "USD")
+	require.NoError(t, err)
+
+	parent := uint(1)
+	classes := []models.Class{
+		{ID: 1, Title: "Internet"},
+		{ID: 2, Title: "Mobile", ParentID: &parent},
+		{ID: 3, Title: "Fixed", ParentID: &parent},
+		{ID: 4, Title: "TV"},
+		{ID: 5, Title: "Roaming"},
+	}
+	classRevenue := []models.ClassRevenue{
+		{ClassID: 2, ClassTitle: "Mobile", ServiceCount: 3, Month: month(2024, 1), Amount: 100},
+		{ClassID: 2, ClassTitle: "Mobile", ServiceCount: 3, Month: month(2024, 5), Amount: 50},
+		{ClassID: 3, ClassTitle: "Fixed", ServiceCount: 1, Month: month(2024, 2), Amount: 10},
+		{ClassID: 4, ClassTitle: "TV", ServiceCount: 2},
+	}
+	parameterRevenue := []models.ParameterRevenue{
+		{ParameterID: "mob_inet", ParameterTitle: "Mobile Internet", ServiceCount: 3, Month: month(2024, 1), Amount: 100},
+		{ParameterID: "roaming", ParameterTitle: "Roaming", ServiceCount: 1, Month: month(2024, 1), Amount: 100},
+	}
+	total := Row{Title: "Total", Kind: Total, Services: 6, Amounts: []float64{110, 50}}
+
+	tests := []struct {
+		grouping Grouping
+		want     []Row
+	}{
+		{
+			grouping: ByClass,
+			want: []Row{
+				{Title: "Mobile", Kind: Item, Services: 3, Amounts: []float64{100, 50}},
+				{Title: "Fixed", Kind: Item, Services: 1, Amounts: []float64{10, 0}},
+				{Title: "TV", Kind: Item, Services: 2, Amounts: []float64{0, 0}},
+				total,
+			},
+		},
+		{
+			grouping: ByHierarchy,
+			want: []Row{
+				{Title: "Internet", Kind: Group},
+				{Title: "Fixed", Level: 1, Kind: Item, Services: 1, Amounts: []float64{10, 0}},
+				{Title: "Mobile", Level: 1, Kind: Item, Services: 3, Amounts: []float64{100, 50}},
+				{Title: "Internet total", Kind: Subtotal, Services: 4, Amounts: []float64{110, 50}},
+				{Title: "TV", Kind: Item, Services: 2, Amounts: []float64{0, 0}},
+				total,
+			},
+		},
+		{
+			grouping: ByParameter,
+			want: []Row{
+				{Title: "Mobile Internet", Kind: Item, Services: 3, Amounts: []float64{100, 0}},
+				{Title: "Roaming", Kind: Item, Services: 1, Amounts: []float64{100, 0}},
+				total,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.grouping), func(t *testing.T) {
+			options.Grouping = tt.grouping
+			table := Build(options, classes, classRevenue, parameterRevenue)
+			assert.Equal(t, "2024-01 – 2024-06, USD", table.Title)
+			assert.Len(t, table.Periods, 2)
+			assert.Equal(t, tt.want, table.Rows)
+		})
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	options, err := NewOptions(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC), Quarter, ByClass, "USD")
+	require.NoError(t, err)
+	table := Build(options, nil, []models.ClassRevenue{
+		{ClassID: 2, ClassTitle: "Mobile", ServiceCount: 2, Month: month(2024, 1), Amount: 100},
+	}, nil)
+
+	f := excelize.NewFile()
+	_, err = WriteXLSX(f, table)
+	require.NoError(t, err)
+
+	rows, err := f.GetRows(Sheet)
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, []string{"Financial Class", "2024-01 – 2024-06, USD"}, rows[0])
+	assert.Equal(t, []string{"", "Services", "Q1 2024", "Q2 2024", "Total"}, rows[1])
+	assert.Equal(t, []string{"Mobile", "2"}, rows[2][:2])
+	formula, err := f.GetCellFormula(Sheet, "E3")
+	require.NoError(t, err)
+	assert.Equal(t, "SUM(C3:D3)", formula)
+}
+
+func TestWritePDF(t *testing.T) {
+	options, err := NewOptions(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), Quarter, ByClass, "EUR")
+	require.NoError(t, err)
+	revenue := make([]models.ClassRevenue, 0, 60)
+	for i := range 60 {
+		revenue = append(revenue, models.ClassRevenue{ClassID: uint(i + 1), ClassTitle: "Классы", Month: month(2024, 1), Amount: 1234.5})
+	}
+	table := Build(options, nil, revenue, nil)
+	table.Summary = &Summary{Approved: 60, Pending: 3}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePDF(&buf, table))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "$1,234,567.89", FormatAmount(1234567.891, "USD"))
+	assert.Equal(t, "-€0.50", FormatAmount(-0.5, "EUR"))
+	assert.Equal(t, "100.00 RUB", FormatAmount(100, "RUB"))
+}
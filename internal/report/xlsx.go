@@ -0,0 +1,237 @@
+package report
+
+import (
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Sheet is the name of the revenue sheet.
+const Sheet = "Sheet1"
+
+// xlsxStyles are the cell styles of the revenue sheet.
+type xlsxStyles struct {
+	title, subtitle, class, group, amount, subtotal, total int
+	// indented caches copies of styles with an indent, by style and level.
+	indented map[[2]int]int
+}
+
+// indent returns style indented by level.
+func (s *xlsxStyles) indent(f *excelize.File, style, level int) (int, error) {
+	key := [2]int{style, level}
+	if id, ok := s.indented[key]; ok {
+		return id, nil
+	}
+	spec, err := f.GetStyle(style)
+	if err != nil {
+		return 0, err
+	}
+	if spec.Alignment == nil {
+		spec.Alignment = &excelize.Alignment{}
+	}
+	spec.Alignment.Indent = 2 * level
+	id, err := f.NewStyle(spec)
+	if err != nil {
+		return 0, err
+	}
+	s.indented[key] = id
+	return id, nil
+}
+
+func newXLSXStyles(f *excelize.File, currency string) (*xlsxStyles, error) {
+	numberFormat := CurrencyFormat(currency)
+	specs := []*excelize.Style{
+		{
+			Font:      &excelize.Font{Bold: true, Size: 14},
+			Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+			Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#D9E1F2"}},
+		},
+		{
+			Font:      &excelize.Font{Bold: true, Size: 12},
+			Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+			Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#FCE4D6"}},
+		},
+		{
+			Font: &excelize.Font{Bold: true},
+		},
+		{
+			Font: &excelize.Font{Bold: true},
+			Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#FCE4D6"}},
+		},
+		{
+			NumFmt:       164,
+			CustomNumFmt: &numberFormat,
+			Font:         &excelize.Font{Size: 11},
+		},
+		{
+			NumFmt:       164,
+			CustomNumFmt: &numberFormat,
+			Font:         &excelize.Font{Bold: true, Size: 11},
+			Fill:         excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#FCE4D6"}},
+		},
+		{
+			NumFmt:       164,
+			CustomNumFmt: &numberFormat,
+			Font:         &excelize.Font{Bold: true, Size: 11, Color: "#FFFFFF"},
+			Fill:         excelize.Fill{Type: "pattern", Color: []string{"#FF5733"}, Pattern: 1},
+			Alignment:    &excelize.Alignment{Horizontal: "right", Vertical: "center"},
+		},
+	}
+	ids := make([]int, 0, len(specs))
+	for _, spec := range specs {
+		id, err := f.NewStyle(spec)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return &xlsxStyles{
+		title:    ids[0],
+		subtitle: ids[1],
+		class:    ids[2],
+		group:    ids[3],
+		amount:   ids[4],
+		subtotal: ids[5],
+		total:    ids[6],
+		indented: make(map[[2]int]int),
+	}, nil
+}
+
+// WriteXLSX writes the table to the first sheet of f: the group title and
+// report title on top, a column with the service count, a column per period
+// plus a total column, subtotal and total rows highlighted. The sheet is
+// streamed, so f must not have touched it before. The sheet header style is
+// returned for the other sheets of the workbook.
+func WriteXLSX(f *excelize.File, table *Table) (int, error) {
+	styles, err := newXLSXStyles(f, table.Currency)
+	if err != nil {
+		return 0, err
+	}
+	stream, err := f.NewStreamWriter(Sheet)
+	if err != nil {
+		return 0, err
+	}
+
+	// Column widths go before the first row of a stream.
+	width := 20
+	for _, row := range table.Rows {
+		width = max(width, len(row.Title)+2*row.Level)
+	}
+	lastColumn := len(table.Periods) + 3
+	if err := stream.SetColWidth(1, 1, float64(width)); err != nil {
+		return 0, err
+	}
+	if err := stream.SetColWidth(2, 2, 12); err != nil {
+		return 0, err
+	}
+	if err := stream.SetColWidth(3, lastColumn, 20); err != nil {
+		return 0, err
+	}
+
+	cell := func(column, row int) string {
+		name, _ := excelize.CoordinatesToCellName(column, row)
+		return name
+	}
+
+	// Titles, the group title is merged over both header rows
+	titles := make([]any, lastColumn)
+	titles[0] = excelize.Cell{StyleID: styles.title, Value: table.GroupTitle}
+	titles[1] = excelize.Cell{StyleID: styles.title, Value: table.Title}
+	for i := 2; i < lastColumn; i++ {
+		titles[i] = excelize.Cell{StyleID: styles.title}
+	}
+	if err := stream.SetRow("A1", titles); err != nil {
+		return 0, err
+	}
+	if err := stream.MergeCell("A1", "A2"); err != nil {
+		return 0, err
+	}
+	if err := stream.MergeCell("B1", cell(lastColumn, 1)); err != nil {
+		return 0, err
+	}
+
+	header := table.Header()
+	headers := make([]any, len(header))
+	headers[0] = excelize.Cell{StyleID: styles.title}
+	for i, title := range header[1:] {
+		headers[i+1] = excelize.Cell{StyleID: styles.subtitle, Value: title}
+	}
+	if err := stream.SetRow("A2", headers); err != nil {
+		return 0, err
+	}
+
+	for i, row := range table.Rows {
+		line := i + 3
+		titleStyle, amountStyle, totalStyle := styles.class, styles.amount, styles.total
+		switch row.Kind {
+		case Group:
+			titleStyle = styles.group
+		case Subtotal:
+			titleStyle, amountStyle, totalStyle = styles.group, styles.subtotal, styles.subtotal
+		case Total:
+			titleStyle, amountStyle = styles.total, styles.total
+		}
+		if row.Level > 0 {
+			if titleStyle, err = styles.indent(f, titleStyle, row.Level); err != nil {
+				return 0, err
+			}
+		}
+
+		values := []any{excelize.Cell{StyleID: titleStyle, Value: row.Title}}
+		if row.Amounts != nil {
+			values = append(values, excelize.Cell{StyleID: titleStyle, Value: row.Services})
+			for _, amount := range row.Amounts {
+				values = append(values, excelize.Cell{StyleID: amountStyle, Value: amount})
+			}
+			formula := "SUM(" + cell(3, line) + ":" + cell(lastColumn-1, line) + ")"
+			values = append(values, excelize.Cell{StyleID: totalStyle, Formula: formula})
+		}
+		if err := stream.SetRow(cell(1, line), values); err != nil {
+			return 0, err
+		}
+	}
+	if err := stream.Flush(); err != nil {
+		return 0, err
+	}
+
+	if table.Summary != nil {
+		if err := writeSummarySheet(f, table, styles); err != nil {
+			return 0, err
+		}
+	}
+	return styles.title, nil
+}
+
+// SummarySheet is the name of the summary sheet.
+const SummarySheet = "Summary"
+
+func writeSummarySheet(f *excelize.File, table *Table, styles *xlsxStyles) error {
+	if _, err := f.NewSheet(SummarySheet); err != nil {
+		return err
+	}
+	for i, line := range table.SummaryLines() {
+		row := strconv.Itoa(i + 1)
+		if err := f.SetCellValue(SummarySheet, "A"+row, line[0]); err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(SummarySheet, "A"+row, "A"+row, styles.class); err != nil {
+			return err
+		}
+		if err := f.SetCellValue(SummarySheet, "B"+row, line[1]); err != nil {
+			return err
+		}
+	}
+	return f.SetColWidth(SummarySheet, "A", "B", 30)
+}
+
+// CurrencyFormat is the Excel number format for amounts in currency.
+func CurrencyFormat(currency string) string {
+	switch currency {
+	case "USD":
+		return `"$"#,##0.00`
+	case "EUR":
+		return `"€"#,##0.00`
+	default:
+		return `#,##0.00 "` + currency + `"`
+	}
+}
@@ -2,18 +2,58 @@ package repositories
 
 import (
 	"backend/internal/models"
+	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrStatusConflict is returned by the conditional service writes when the
+// stored status is no longer the expected one, e.g. because an expert or a
+// prediction worker changed the service in the meantime.
+var ErrStatusConflict = errors.New("service status was changed concurrently")
+
 type ServiceRepository interface {
+	WithTx(tx *gorm.DB) ServiceRepository
 	Create(service *models.Service) error
 	Update(service *models.Service) error
 	Delete(id uint) error
+	// DeleteFrom deletes the service while its stored status is from, it
+	// returns ErrStatusConflict otherwise.
+	DeleteFrom(id uint, from models.ServiceStatus) error
 	GetByID(id uint) (*models.Service, error)
-	List(offset, limit int) ([]models.Service, error)
+	// UpdateColumns writes only the given columns, including zero values.
+	UpdateColumns(service *models.Service, columns ...string) error
+	// UpdateColumnsFrom does the same while the stored status is still
+	// from, it returns ErrStatusConflict otherwise.
+	UpdateColumnsFrom(service *models.Service, from models.ServiceStatus, columns ...string) error
+	ReplaceParameters(service *models.Service, parameters []models.Parameter) error
+	// List returns services in any of statuses, or all of them when none
+	// are given.
+	List(offset, limit int, statuses ...models.ServiceStatus) ([]models.Service, error)
+	// ListForReview returns services waiting for an expert by the day they
+	// were created, oldest first, and within a day by the probability of the
+	// latest top prediction, most confident first. Days rather than exact
+	// timestamps keep the probability from only breaking ties.
+	ListForReview(offset, limit int, statuses ...models.ServiceStatus) ([]models.Service, error)
 	FindByParameterID(parameterID string) ([]models.Service, error)
 	FindByClassID(id uint) ([]models.Service, error)
+	FindByBatchID(batchID uint) ([]models.Service, error)
+	// FindInBatches calls fn with all services in chunks of size, ordered by
+	// ID, with their parameters and class.
+	FindInBatches(size int, fn func([]models.Service) error) error
+	// FindOverriddenInBatches does the same for the approved services whose
+	// class rules were overridden, with their class only.
+	FindOverriddenInBatches(size int, fn func([]models.Service) error) error
+	CountByStatus() (map[models.ServiceStatus]int, error)
+	// ExistingIDs returns the IDs out of ids that belong to a service.
+	ExistingIDs(ids []uint) ([]uint, error)
+	ListApproved() ([]models.Service, error)
+	Unapprove(id uint) error
+	// BackfillStatus derives the status of services stored before it
+	// existed.
+	BackfillStatus() error
 }
 
 type serviceRepository struct {
@@ -24,6 +64,10 @@ func NewServiceRepository(db *gorm.DB) ServiceRepository {
 	return &serviceRepository{db}
 }
 
+func (r *serviceRepository) WithTx(tx *gorm.DB) ServiceRepository {
+	return &serviceRepository{tx}
+}
+
 func (r *serviceRepository) Create(service *models.Service) error {
 	return r.db.Create(service).Error
 }
@@ -32,8 +76,43 @@ func (r *serviceRepository) Update(service *models.Service) error {
 	return r.db.Model(service).Omit("CreatedAt").Updates(service).Error
 }
 
+func (r *serviceRepository) UpdateColumns(service *models.Service, columns ...string) error {
+	return r.db.Model(service).Select(columns).Updates(service).Error
+}
+
+func (r *serviceRepository) UpdateColumnsFrom(service *models.Service, from models.ServiceStatus, columns ...string) error {
+	result := r.db.Model(service).Where("status = ?", from).Select(columns).Updates(service)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+func (r *serviceRepository) ReplaceParameters(service *models.Service, parameters []models.Parameter) error {
+	return r.db.Model(service).Association("Parameters").Replace(parameters)
+}
+
+// Delete removes the service together with its parameter links.
 func (r *serviceRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Service{}, id).Error
+	return r.db.Select("Parameters").Delete(&models.Service{ID: id}).Error
+}
+
+func (r *serviceRepository) DeleteFrom(id uint, from models.ServiceStatus) error {
+	// The parameter links go first, the transaction brings them back when
+	// the service turns out to be in another status.
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Select("Parameters").Where("status = ?", from).Delete(&models.Service{ID: id})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStatusConflict
+		}
+		return nil
+	})
 }
 
 func (r *serviceRepository) GetByID(id uint) (*models.Service, error) {
@@ -42,9 +121,13 @@ func (r *serviceRepository) GetByID(id uint) (*models.Service, error) {
 	return &service, err
 }
 
-func (r *serviceRepository) List(offset, limit int) ([]models.Service, error) {
+func (r *serviceRepository) List(offset, limit int, statuses ...models.ServiceStatus) ([]models.Service, error) {
 	var services []models.Service
-	err := r.db.
+	query := r.db
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	err := query.
 		Preload("Class").
 		Offset(offset).
 		Limit(limit).
@@ -54,6 +137,22 @@ func (r *serviceRepository) List(offset, limit int) ([]models.Service, error) {
 	return services, err
 }
 
+func (r *serviceRepository) ListForReview(offset, limit int, statuses ...models.ServiceStatus) ([]models.Service, error) {
+	var services []models.Service
+	err := r.db.
+		Preload("Parameters").
+		Select("services.*").
+		Joins(`LEFT JOIN service_predictions top ON top.service_id = services.id AND top.rank = 1
+			AND top.job_id = (SELECT MAX(job_id) FROM service_predictions WHERE service_id = services.id)`).
+		Where("services.status IN ?", statuses).
+		Order("date_trunc('day', services.created_at), top.probability DESC NULLS LAST, services.created_at, services.id").
+		Offset(offset).
+		Limit(limit).
+		Find(&services).
+		Error
+	return services, err
+}
+
 func (r *serviceRepository) FindByParameterID(parameterID string) ([]models.Service, error) {
 	var services []models.Service
 	err := r.db.
@@ -74,10 +173,109 @@ func (r *serviceRepository) FindByClassID(id uint) ([]models.Service, error) {
 	return services, err
 }
 
+func (r *serviceRepository) FindByBatchID(batchID uint) ([]models.Service, error) {
+	var services []models.Service
+	err := r.db.
+		Preload("Class").
+		Where("batch_id = ?", batchID).
+		Order("id").
+		Find(&services).Error
+	return services, err
+}
+
+func (r *serviceRepository) FindInBatches(size int, fn func([]models.Service) error) error {
+	var services []models.Service
+	return r.db.
+		Preload("Parameters").
+		Preload("Class").
+		FindInBatches(&services, size, func(*gorm.DB, int) error {
+			return fn(services)
+		}).Error
+}
+
+func (r *serviceRepository) FindOverriddenInBatches(size int, fn func([]models.Service) error) error {
+	var services []models.Service
+	return r.db.
+		Preload("Class").
+		Where("approved_at IS NOT NULL AND approval_overridden").
+		FindInBatches(&services, size, func(*gorm.DB, int) error {
+			return fn(services)
+		}).Error
+}
+
+func (r *serviceRepository) CountByStatus() (map[models.ServiceStatus]int, error) {
+	var rows []struct {
+		Status models.ServiceStatus
+		Count  int
+	}
+	err := r.db.Model(&models.Service{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[models.ServiceStatus]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *serviceRepository) ExistingIDs(ids []uint) ([]uint, error) {
+	var existing []uint
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	err := r.db.Model(&models.Service{}).Where("id IN ?", ids).Pluck("id", &existing).Error
+	return existing, err
+}
+
+func (r *serviceRepository) ListApproved() ([]models.Service, error) {
+	var services []models.Service
+	err := r.db.
+		Preload("Parameters").
+		Preload("Class").
+		Where("approved_at IS NOT NULL").
+		Order("id").
+		Find(&services).Error
+	return services, err
+}
+
+func (r *serviceRepository) Unapprove(id uint) error {
+	return r.db.Model(&models.Service{}).Where("id = ?", id).Updates(map[string]any{
+		"approved_at": nil,
+		"status":      models.ServiceNeedsReview,
+	}).Error
+}
+
+func (r *serviceRepository) BackfillStatus() error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		missing := "status IS NULL OR status = ''"
+		err := tx.Model(&models.Service{}).
+			Where(missing).Where("approved_at IS NOT NULL").
+			Update("status", models.ServiceApproved).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.Service{}).
+			Where(missing).Where("class_id IS NOT NULL").
+			Update("status", models.ServicePredicted).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Service{}).
+			Where(missing).
+			Update("status", models.ServiceNeedsReview).Error
+	})
+}
+
 type ClassRepository interface {
+	WithTx(tx *gorm.DB) ClassRepository
 	GetByID(id uint) (*models.Class, error)
 	List(offset, limit int) ([]models.Class, error)
 	Update(class *models.Class) error
+	FindByParentID(parentID uint) ([]models.Class, error)
+	// FindInBatches calls fn with all classes in chunks of size, ordered by
+	// ID.
+	FindInBatches(size int, fn func([]models.Class) error) error
 	Create(class *models.Class) error
 	Delete(u uint) error
 }
@@ -90,6 +288,10 @@ func NewClassRepository(db *gorm.DB) ClassRepository {
 	return &classRepository{db}
 }
 
+func (r *classRepository) WithTx(tx *gorm.DB) ClassRepository {
+	return &classRepository{tx}
+}
+
 func (r *classRepository) GetByID(id uint) (*models.Class, error) {
 	var class models.Class
 	err := r.db.First(&class, id).Error
@@ -106,8 +308,23 @@ func (r *classRepository) Create(class *models.Class) error {
 	return r.db.Create(class).Error
 }
 
+// Update writes all editable fields, so a nil ParentID detaches the class
+// from its parent.
 func (r *classRepository) Update(class *models.Class) error {
-	return r.db.Model(class).Omit("CreatedAt").Updates(class).Error
+	return r.db.Model(class).Select("*").Omit("CreatedAt", "New").Updates(class).Error
+}
+
+func (r *classRepository) FindByParentID(parentID uint) ([]models.Class, error) {
+	var classes []models.Class
+	err := r.db.Where("parent_id = ?", parentID).Find(&classes).Error
+	return classes, err
+}
+
+func (r *classRepository) FindInBatches(size int, fn func([]models.Class) error) error {
+	var classes []models.Class
+	return r.db.FindInBatches(&classes, size, func(*gorm.DB, int) error {
+		return fn(classes)
+	}).Error
 }
 
 func (r *classRepository) Delete(u uint) error {
@@ -115,12 +332,16 @@ func (r *classRepository) Delete(u uint) error {
 }
 
 type ParameterRepository interface {
+	WithTx(tx *gorm.DB) ParameterRepository
 	Create(parameter *models.Parameter) error
 	Update(parameter *models.Parameter) error
 	Delete(code string) error
 	GetByID(code string) (*models.Parameter, error)
 	List(offset, limit int) ([]models.Parameter, error)
 	ListSupportedParameters() ([]string, error)
+	// FindInBatches calls fn with all parameters in chunks of size, ordered
+	// by ID.
+	FindInBatches(size int, fn func([]models.Parameter) error) error
 }
 
 type parameterRepository struct {
@@ -131,6 +352,10 @@ func NewParameterRepository(db *gorm.DB) ParameterRepository {
 	return &parameterRepository{db}
 }
 
+func (r *parameterRepository) WithTx(tx *gorm.DB) ParameterRepository {
+	return &parameterRepository{tx}
+}
+
 func (r *parameterRepository) Create(parameter *models.Parameter) error {
 	return r.db.Create(parameter).Error
 }
@@ -155,8 +380,668 @@ func (r *parameterRepository) List(offset, limit int) ([]models.Parameter, error
 	return parameters, err
 }
 
+func (r *parameterRepository) FindInBatches(size int, fn func([]models.Parameter) error) error {
+	var parameters []models.Parameter
+	return r.db.FindInBatches(&parameters, size, func(*gorm.DB, int) error {
+		return fn(parameters)
+	}).Error
+}
+
 func (r *parameterRepository) ListSupportedParameters() ([]string, error) {
 	var parameters []string
 	err := r.db.Model(&models.Parameter{}).Where("new = false").Pluck("id", &parameters).Error
 	return parameters, err
 }
+
+type OutboxRepository interface {
+	WithTx(tx *gorm.DB) OutboxRepository
+	Create(event *models.OutboxEvent) error
+	// CreateAll stores the events in one statement, all or none.
+	CreateAll(events []models.OutboxEvent) error
+	GetByID(id uint) (*models.OutboxEvent, error)
+	LastID() (uint, error)
+	// UnappliedAggregates returns the aggregates with events that are not
+	// done or have an ID above afterID.
+	UnappliedAggregates(afterID uint) ([]string, error)
+	List(statuses []models.OutboxStatus, offset, limit int) ([]models.OutboxEvent, error)
+	Retry(id uint) error
+	// ClaimNext marks the oldest due event as running and counts the
+	// attempt, nil means there is nothing to do. An event is due when it is
+	// pending and its retry time has come, or when it was claimed before
+	// staleBefore by a dispatcher that has not finished it. Events wait for
+	// every earlier event of their aggregate to be done, so a failed event
+	// holds back the later changes of the same entity until it is retried.
+	ClaimNext(now, staleBefore time.Time) (*models.OutboxEvent, error)
+	// Finish stores the outcome of a claimed event. It returns
+	// gorm.ErrRecordNotFound when another dispatcher has taken the event
+	// over in the meantime.
+	Finish(event *models.OutboxEvent) error
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db}
+}
+
+func (r *outboxRepository) WithTx(tx *gorm.DB) OutboxRepository {
+	return &outboxRepository{tx}
+}
+
+func (r *outboxRepository) Create(event *models.OutboxEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *outboxRepository) CreateAll(events []models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.db.Create(&events).Error
+}
+
+func (r *outboxRepository) GetByID(id uint) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	err := r.db.First(&event, id).Error
+	return &event, err
+}
+
+func (r *outboxRepository) LastID() (uint, error) {
+	var id uint
+	err := r.db.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+func (r *outboxRepository) UnappliedAggregates(afterID uint) ([]string, error) {
+	var aggregates []string
+	err := r.db.Model(&models.OutboxEvent{}).
+		Distinct("aggregate").
+		Where("status <> ? OR id > ?", models.OutboxDone, afterID).
+		Pluck("aggregate", &aggregates).Error
+	return aggregates, err
+}
+
+func (r *outboxRepository) List(statuses []models.OutboxStatus, offset, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	query := r.db.Offset(offset).Limit(limit).Order("id")
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	err := query.Find(&events).Error
+	return events, err
+}
+
+func (r *outboxRepository) Retry(id uint) error {
+	result := r.db.Model(&models.OutboxEvent{}).
+		Where("id = ? AND status = ?", id, models.OutboxFailed).
+		Updates(map[string]any{
+			"status":          models.OutboxPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *outboxRepository) ClaimNext(now, staleBefore time.Time) (*models.OutboxEvent, error) {
+	var claimed *models.OutboxEvent
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// An earlier event of the aggregate that is claimed by another
+		// dispatcher is still visible as pending or running, so its
+		// successors are never claimed before it is done.
+		var event models.OutboxEvent
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND claimed_at < ?)",
+				models.OutboxPending, now, models.OutboxRunning, staleBefore).
+			Where(`NOT EXISTS (SELECT 1 FROM outbox_events AS earlier
+				WHERE earlier.aggregate = outbox_events.aggregate AND earlier.id < outbox_events.id AND earlier.status <> ?)`,
+				models.OutboxDone).
+			Order("id").
+			First(&event).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		event.Status = models.OutboxRunning
+		event.ClaimedAt = &now
+		event.Attempts++
+		err = tx.Model(&event).Updates(map[string]any{
+			"status":     event.Status,
+			"claimed_at": now,
+			"attempts":   event.Attempts,
+		}).Error
+		if err != nil {
+			return err
+		}
+		claimed = &event
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *outboxRepository) Finish(event *models.OutboxEvent) error {
+	// The attempt counter tells whether the claim is still ours.
+	result := r.db.Model(&models.OutboxEvent{}).
+		Where("id = ? AND status = ? AND attempts = ?", event.ID, models.OutboxRunning, event.Attempts).
+		Updates(map[string]any{
+			"status":          event.Status,
+			"last_error":      event.LastError,
+			"next_attempt_at": event.NextAttemptAt,
+			"processed_at":    event.ProcessedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+type PredictionJobRepository interface {
+	WithTx(tx *gorm.DB) PredictionJobRepository
+	Create(job *models.PredictionJob) error
+	// Renew moves the claim of a running job to now and Finish stores its
+	// outcome. Both return gorm.ErrRecordNotFound when the claim was lost,
+	// i.e. the job was requeued and claimed again.
+	Renew(job *models.PredictionJob, now time.Time) error
+	Finish(job *models.PredictionJob) error
+	// GetLatestByServiceID returns the most recent job of the service.
+	GetLatestByServiceID(serviceID uint) (*models.PredictionJob, error)
+	// ClaimDue marks up to limit due pending jobs as running, claimed at
+	// now, and returns them, oldest first.
+	ClaimDue(now time.Time, limit int) ([]models.PredictionJob, error)
+	// Requeue puts running jobs claimed before staleBefore, i.e. left by a
+	// crashed process, back to pending and creates jobs for services that
+	// wait for a prediction without one.
+	Requeue(now, staleBefore time.Time) (int64, error)
+}
+
+type predictionJobRepository struct {
+	db *gorm.DB
+}
+
+func NewPredictionJobRepository(db *gorm.DB) PredictionJobRepository {
+	return &predictionJobRepository{db}
+}
+
+func (r *predictionJobRepository) WithTx(tx *gorm.DB) PredictionJobRepository {
+	return &predictionJobRepository{tx}
+}
+
+func (r *predictionJobRepository) Create(job *models.PredictionJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *predictionJobRepository) Renew(job *models.PredictionJob, now time.Time) error {
+	err := r.claimed(job, map[string]any{"claimed_at": now})
+	if err == nil {
+		job.ClaimedAt = &now
+	}
+	return err
+}
+
+func (r *predictionJobRepository) Finish(job *models.PredictionJob) error {
+	return r.claimed(job, map[string]any{
+		"status":          job.Status,
+		"last_error":      job.LastError,
+		"next_attempt_at": job.NextAttemptAt,
+		"finished_at":     job.FinishedAt,
+	})
+}
+
+// claimed updates the job while it is running under the same claim. The
+// attempt counter tells whether the claim is still ours.
+func (r *predictionJobRepository) claimed(job *models.PredictionJob, columns map[string]any) error {
+	result := r.db.Model(&models.PredictionJob{}).
+		Where("id = ? AND status = ? AND attempts = ?", job.ID, models.PredictionRunning, job.Attempts).
+		Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *predictionJobRepository) GetLatestByServiceID(serviceID uint) (*models.PredictionJob, error) {
+	var job models.PredictionJob
+	err := r.db.Where("service_id = ?", serviceID).Order("id DESC").First(&job).Error
+	return &job, err
+}
+
+func (r *predictionJobRepository) ClaimDue(now time.Time, limit int) ([]models.PredictionJob, error) {
+	var jobs []models.PredictionJob
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.PredictionPending, now).
+			Order("next_attempt_at, id").
+			Limit(limit).
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(jobs))
+		for i := range jobs {
+			jobs[i].Status = models.PredictionRunning
+			jobs[i].Attempts++
+			jobs[i].ClaimedAt = &now
+			ids = append(ids, jobs[i].ID)
+		}
+		return tx.Model(&models.PredictionJob{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"status":     models.PredictionRunning,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"claimed_at": now,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (r *predictionJobRepository) Requeue(now, staleBefore time.Time) (int64, error) {
+	var requeued int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PredictionJob{}).
+			Where("status = ? AND (claimed_at IS NULL OR claimed_at < ?)", models.PredictionRunning, staleBefore).
+			Updates(map[string]any{
+				"status":          models.PredictionPending,
+				"next_attempt_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		requeued = result.RowsAffected
+
+		result = tx.Exec(`
+			INSERT INTO prediction_jobs (service_id, status, attempts, next_attempt_at, created_at, updated_at)
+			SELECT s.id, ?, 0, ?, ?, ?
+			FROM services s
+			WHERE s.status = ?
+			  AND NOT EXISTS (
+			    SELECT 1 FROM prediction_jobs j
+			    WHERE j.service_id = s.id AND j.status IN ?
+			  )`,
+			models.PredictionPending, now, now, now,
+			models.ServiceAwaitingPrediction,
+			[]models.PredictionJobStatus{models.PredictionPending, models.PredictionRunning})
+		if result.Error != nil {
+			return result.Error
+		}
+		requeued += result.RowsAffected
+		return nil
+	})
+	return requeued, err
+}
+
+type ServicePredictionRepository interface {
+	WithTx(tx *gorm.DB) ServicePredictionRepository
+	// ReplaceJob stores the predictions of the job in place of any stored
+	// by an earlier attempt of it.
+	ReplaceJob(jobID uint, predictions []models.ServicePrediction) error
+	// ListByServiceID returns all predictions of the service, newest job
+	// first and by rank within a job.
+	ListByServiceID(serviceID uint) ([]models.ServicePrediction, error)
+	// ListLatestByServiceID returns the predictions of the newest job.
+	ListLatestByServiceID(serviceID uint) ([]models.ServicePrediction, error)
+}
+
+type servicePredictionRepository struct {
+	db *gorm.DB
+}
+
+func NewServicePredictionRepository(db *gorm.DB) ServicePredictionRepository {
+	return &servicePredictionRepository{db}
+}
+
+func (r *servicePredictionRepository) WithTx(tx *gorm.DB) ServicePredictionRepository {
+	return &servicePredictionRepository{tx}
+}
+
+func (r *servicePredictionRepository) ReplaceJob(jobID uint, predictions []models.ServicePrediction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job_id = ?", jobID).Delete(&models.ServicePrediction{}).Error; err != nil {
+			return err
+		}
+		if len(predictions) == 0 {
+			return nil
+		}
+		return tx.Create(&predictions).Error
+	})
+}
+
+func (r *servicePredictionRepository) ListByServiceID(serviceID uint) ([]models.ServicePrediction, error) {
+	var predictions []models.ServicePrediction
+	err := r.db.
+		Where("service_id = ?", serviceID).
+		Order("job_id DESC, rank").
+		Find(&predictions).Error
+	return predictions, err
+}
+
+func (r *servicePredictionRepository) ListLatestByServiceID(serviceID uint) ([]models.ServicePrediction, error) {
+	var predictions []models.ServicePrediction
+	err := r.db.
+		Where("service_id = ? AND job_id = (?)", serviceID,
+			r.db.Model(&models.ServicePrediction{}).Select("MAX(job_id)").Where("service_id = ?", serviceID)).
+		Order("rank").
+		Find(&predictions).Error
+	return predictions, err
+}
+
+type ApprovalFeedbackRepository interface {
+	WithTx(tx *gorm.DB) ApprovalFeedbackRepository
+	Create(feedback *models.ApprovalFeedback) error
+	// ListLatest returns the latest approval of every service approved
+	// within [from, to), a nil bound is open.
+	ListLatest(from, to *time.Time) ([]models.ApprovalFeedback, error)
+}
+
+type approvalFeedbackRepository struct {
+	db *gorm.DB
+}
+
+func NewApprovalFeedbackRepository(db *gorm.DB) ApprovalFeedbackRepository {
+	return &approvalFeedbackRepository{db}
+}
+
+func (r *approvalFeedbackRepository) WithTx(tx *gorm.DB) ApprovalFeedbackRepository {
+	return &approvalFeedbackRepository{tx}
+}
+
+func (r *approvalFeedbackRepository) Create(feedback *models.ApprovalFeedback) error {
+	return r.db.Create(feedback).Error
+}
+
+func (r *approvalFeedbackRepository) ListLatest(from, to *time.Time) ([]models.ApprovalFeedback, error) {
+	latest := r.db.Model(&models.ApprovalFeedback{}).
+		Select("DISTINCT ON (service_id) id").
+		Order("service_id, approved_at DESC, id DESC")
+
+	query := r.db.Where("id IN (?)", latest)
+	if from != nil {
+		query = query.Where("approved_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("approved_at < ?", *to)
+	}
+
+	var feedback []models.ApprovalFeedback
+	err := query.Order("approved_at, id").Find(&feedback).Error
+	return feedback, err
+}
+
+type FeatureSchemaRepository interface {
+	// Register stores the schema unless its version is already known and
+	// returns the stored one.
+	Register(schema *models.FeatureSchema) (*models.FeatureSchema, error)
+	List() ([]models.FeatureSchema, error)
+}
+
+type featureSchemaRepository struct {
+	db *gorm.DB
+}
+
+func NewFeatureSchemaRepository(db *gorm.DB) FeatureSchemaRepository {
+	return &featureSchemaRepository{db}
+}
+
+func (r *featureSchemaRepository) Register(schema *models.FeatureSchema) (*models.FeatureSchema, error) {
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(schema).Error
+	if err != nil {
+		return nil, err
+	}
+	var stored models.FeatureSchema
+	err = r.db.First(&stored, "version = ?", schema.Version).Error
+	return &stored, err
+}
+
+func (r *featureSchemaRepository) List() ([]models.FeatureSchema, error) {
+	var schemas []models.FeatureSchema
+	err := r.db.Order("created_at DESC").Find(&schemas).Error
+	return schemas, err
+}
+
+type ServiceBatchRepository interface {
+	WithTx(tx *gorm.DB) ServiceBatchRepository
+	Create(batch *models.ServiceBatch) error
+	GetByID(id uint) (*models.ServiceBatch, error)
+}
+
+type serviceBatchRepository struct {
+	db *gorm.DB
+}
+
+func NewServiceBatchRepository(db *gorm.DB) ServiceBatchRepository {
+	return &serviceBatchRepository{db}
+}
+
+func (r *serviceBatchRepository) WithTx(tx *gorm.DB) ServiceBatchRepository {
+	return &serviceBatchRepository{tx}
+}
+
+func (r *serviceBatchRepository) Create(batch *models.ServiceBatch) error {
+	return r.db.Create(batch).Error
+}
+
+func (r *serviceBatchRepository) GetByID(id uint) (*models.ServiceBatch, error) {
+	var batch models.ServiceBatch
+	err := r.db.First(&batch, id).Error
+	return &batch, err
+}
+
+type RevenueRepository interface {
+	// Upsert stores the records, replacing the amount of existing records
+	// for the same service, period and currency.
+	Upsert(records []models.RevenueRecord) error
+	List(serviceID *uint, from, to *time.Time, offset, limit int) ([]models.RevenueRecord, error)
+	// SumByClassAndMonth adds up the revenue of approved services in
+	// currency per class and month of the periods in [from, to). Every row
+	// carries the number of approved services of the class.
+	SumByClassAndMonth(from, to time.Time, currency string) ([]models.ClassRevenue, error)
+	// SumByParameterAndMonth does the same per parameter.
+	SumByParameterAndMonth(from, to time.Time, currency string) ([]models.ParameterRevenue, error)
+}
+
+type revenueRepository struct {
+	db *gorm.DB
+}
+
+func NewRevenueRepository(db *gorm.DB) RevenueRepository {
+	return &revenueRepository{db}
+}
+
+func (r *revenueRepository) Upsert(records []models.RevenueRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "service_id"}, {Name: "period"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"amount", "updated_at"}),
+	}).CreateInBatches(records, 500).Error
+}
+
+func (r *revenueRepository) List(serviceID *uint, from, to *time.Time, offset, limit int) ([]models.RevenueRecord, error) {
+	var records []models.RevenueRecord
+	query := r.db
+	if serviceID != nil {
+		query = query.Where("service_id = ?", *serviceID)
+	}
+	if from != nil {
+		query = query.Where("period >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("period < ?", *to)
+	}
+	err := query.Order("period, service_id, currency").Offset(offset).Limit(limit).Find(&records).Error
+	return records, err
+}
+
+func (r *revenueRepository) SumByClassAndMonth(from, to time.Time, currency string) ([]models.ClassRevenue, error) {
+	var revenue []models.ClassRevenue
+	err := r.approvedRevenue(from, to, currency).
+		Select(`services.class_id,
+			classes.title AS class_title,
+			counts.service_count,
+			revenue_records.period AS month,
+			COALESCE(SUM(revenue_records.amount), 0) AS amount`).
+		Joins("JOIN classes ON classes.id = services.class_id").
+		Joins(`JOIN (SELECT class_id, COUNT(*) AS service_count FROM services
+			WHERE status = ? GROUP BY class_id) AS counts ON counts.class_id = services.class_id`, models.ServiceApproved).
+		Group("services.class_id, classes.title, counts.service_count, revenue_records.period").
+		Order("classes.title, services.class_id, revenue_records.period").
+		Scan(&revenue).Error
+	return revenue, err
+}
+
+func (r *revenueRepository) SumByParameterAndMonth(from, to time.Time, currency string) ([]models.ParameterRevenue, error) {
+	var revenue []models.ParameterRevenue
+	err := r.approvedRevenue(from, to, currency).
+		Select(`parameters.id AS parameter_id,
+			parameters.title AS parameter_title,
+			counts.service_count,
+			revenue_records.period AS month,
+			COALESCE(SUM(revenue_records.amount), 0) AS amount`).
+		Joins("JOIN service_parameters ON service_parameters.service_id = services.id").
+		Joins("JOIN parameters ON parameters.id = service_parameters.parameter_id").
+		Joins(`JOIN (SELECT service_parameters.parameter_id, COUNT(*) AS service_count FROM service_parameters
+			JOIN services ON services.id = service_parameters.service_id
+			WHERE services.status = ? GROUP BY service_parameters.parameter_id) AS counts
+			ON counts.parameter_id = parameters.id`, models.ServiceApproved).
+		Group("parameters.id, parameters.title, counts.service_count, revenue_records.period").
+		Order("parameters.title, parameters.id, revenue_records.period").
+		Scan(&revenue).Error
+	return revenue, err
+}
+
+// approvedRevenue joins the approved services with their revenue records in
+// the range, services without records are kept.
+func (r *revenueRepository) approvedRevenue(from, to time.Time, currency string) *gorm.DB {
+	return r.db.Table("services").
+		Joins(`LEFT JOIN revenue_records ON revenue_records.service_id = services.id
+			AND revenue_records.currency = ? AND revenue_records.period >= ? AND revenue_records.period < ?`, currency, from, to).
+		Where("services.status = ?", models.ServiceApproved)
+}
+
+type ArchivedReportRepository interface {
+	// Create stores report unless the schedule already has a report for the
+	// same range and format, the result tells whether it was stored.
+	Create(report *models.ArchivedReport) (bool, error)
+	// TakeOver restarts the run stored for the schedule, range and format of
+	// report when it failed fewer than maxAttempts times or has been running
+	// since before staleBefore. The result tells whether the run was taken
+	// over, report then carries the ID, attempts and creation time.
+	TakeOver(report *models.ArchivedReport, staleBefore time.Time, maxAttempts int) (bool, error)
+	// ListRetryable returns the runs TakeOver would restart, without their
+	// content.
+	ListRetryable(staleBefore time.Time, maxAttempts int) ([]models.ArchivedReport, error)
+	// Finish stores the outcome of a run while the run is still the attempt
+	// of report, it returns gorm.ErrRecordNotFound once it was taken over.
+	Finish(report *models.ArchivedReport) error
+	// List returns the reports without their content, newest first.
+	List(offset, limit int) ([]models.ArchivedReport, error)
+	GetByID(id uint) (*models.ArchivedReport, error)
+}
+
+type archivedReportRepository struct {
+	db *gorm.DB
+}
+
+func NewArchivedReportRepository(db *gorm.DB) ArchivedReportRepository {
+	return &archivedReportRepository{db}
+}
+
+func (r *archivedReportRepository) Create(report *models.ArchivedReport) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(report)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *archivedReportRepository) TakeOver(report *models.ArchivedReport, staleBefore time.Time, maxAttempts int) (bool, error) {
+	result := whereRetryableReport(r.db.Model(&models.ArchivedReport{}), staleBefore, maxAttempts).
+		Where("schedule = ? AND period_start = ? AND period_end = ? AND format = ?",
+			report.Schedule, report.PeriodStart, report.PeriodEnd, report.Format).
+		Updates(map[string]any{
+			"status":      models.ReportRunning,
+			"error":       "",
+			"attempts":    gorm.Expr("attempts + 1"),
+			"started_at":  report.StartedAt,
+			"finished_at": nil,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	var taken models.ArchivedReport
+	err := r.db.Select("id", "attempts", "created_at").
+		Where("schedule = ? AND period_start = ? AND period_end = ? AND format = ?",
+			report.Schedule, report.PeriodStart, report.PeriodEnd, report.Format).
+		Take(&taken).Error
+	if err != nil {
+		return false, err
+	}
+	report.ID = taken.ID
+	report.Attempts = taken.Attempts
+	report.CreatedAt = taken.CreatedAt
+	report.Status = models.ReportRunning
+	return true, nil
+}
+
+func (r *archivedReportRepository) ListRetryable(staleBefore time.Time, maxAttempts int) ([]models.ArchivedReport, error) {
+	var reports []models.ArchivedReport
+	err := whereRetryableReport(r.db.Omit("content"), staleBefore, maxAttempts).Order("id").Find(&reports).Error
+	return reports, err
+}
+
+// whereRetryableReport selects runs with attempts left that failed or that
+// started before staleBefore and never finished, e.g. after a crash.
+func whereRetryableReport(db *gorm.DB, staleBefore time.Time, maxAttempts int) *gorm.DB {
+	return db.Where("attempts < ? AND (status = ? OR (status = ? AND (started_at IS NULL OR started_at < ?)))",
+		maxAttempts, models.ReportFailed, models.ReportRunning, staleBefore)
+}
+
+func (r *archivedReportRepository) Finish(report *models.ArchivedReport) error {
+	result := r.db.Model(&models.ArchivedReport{}).
+		Where("id = ? AND status = ? AND attempts = ?", report.ID, models.ReportRunning, report.Attempts).
+		Updates(map[string]any{
+			"status":      report.Status,
+			"error":       report.Error,
+			"content":     report.Content,
+			"size":        report.Size,
+			"finished_at": report.FinishedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *archivedReportRepository) List(offset, limit int) ([]models.ArchivedReport, error) {
+	var reports []models.ArchivedReport
+	err := r.db.Omit("content").Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&reports).Error
+	return reports, err
+}
+
+func (r *archivedReportRepository) GetByID(id uint) (*models.ArchivedReport, error) {
+	var report models.ArchivedReport
+	err := r.db.First(&report, id).Error
+	return &report, err
+}
@@ -0,0 +1,197 @@
+package retrain
+
+import (
+	"backend/internal/backoff"
+	"backend/internal/classifier"
+	"backend/internal/repositories"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reasons passed to Notify.
+const (
+	ClassesChanged    = "classes_changed"
+	ParametersChanged = "parameters_changed"
+	Manual            = "manual"
+)
+
+// Trigger tells the model service that it should be retrained. Changes are
+// collected for the debounce period so that a burst of edits results in one
+// call, failed calls are retried with backoff until they succeed.
+type Trigger struct {
+	url             string
+	token           string
+	trainingDataURL string
+	parameterRepo   repositories.ParameterRepository
+	client          *http.Client
+	debounce        time.Duration
+
+	mu      sync.Mutex
+	reasons map[string]struct{}
+	wake    chan struct{}
+}
+
+type payload struct {
+	Reasons         []string `json:"reasons"`
+	SchemaVersion   string   `json:"schema_version"`
+	Parameters      []string `json:"parameters"`
+	TrainingDataURL string   `json:"training_data_url"`
+}
+
+// NewTrigger returns a trigger posting to url. An empty url disables it.
+func NewTrigger(url, token, trainingDataURL string, parameterRepo repositories.ParameterRepository, debounce time.Duration) *Trigger {
+	return &Trigger{
+		url:             url,
+		token:           token,
+		trainingDataURL: trainingDataURL,
+		parameterRepo:   parameterRepo,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		debounce:        debounce,
+		reasons:         make(map[string]struct{}),
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+// Enabled reports whether a model service is configured.
+func (t *Trigger) Enabled() bool {
+	return t != nil && t.url != ""
+}
+
+// Notify schedules a call to the model service.
+func (t *Trigger) Notify(reason string) {
+	if !t.Enabled() {
+		return
+	}
+
+	t.mu.Lock()
+	t.reasons[reason] = struct{}{}
+	t.mu.Unlock()
+	t.wakeUp()
+}
+
+// Run sends the collected notifications until ctx is cancelled.
+func (t *Trigger) Run(ctx context.Context) {
+	if !t.Enabled() {
+		slog.Info("Model retraining trigger is disabled")
+		return
+	}
+
+	attempts := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.wake:
+		}
+		if !sleep(ctx, t.debounce) {
+			return
+		}
+
+		reasons := t.take()
+		if len(reasons) == 0 {
+			continue
+		}
+		err := t.send(ctx, reasons)
+		if err == nil {
+			attempts = 0
+			slog.Info("Model retraining triggered", slog.Any("reasons", reasons))
+			continue
+		}
+
+		attempts++
+		t.restore(reasons)
+		delay := backoff.Delay(max(t.debounce, time.Second), attempts)
+		slog.Warn("Model retraining trigger will be retried", slog.Int("attempts", attempts), slog.Duration("delay", delay), slog.Any("error", err))
+		if !sleep(ctx, delay) {
+			return
+		}
+		t.wakeUp()
+	}
+}
+
+func (t *Trigger) wakeUp() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (t *Trigger) take() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reasons := make([]string, 0, len(t.reasons))
+	for reason := range t.reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	clear(t.reasons)
+	return reasons
+}
+
+func (t *Trigger) restore(reasons []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, reason := range reasons {
+		t.reasons[reason] = struct{}{}
+	}
+}
+
+func (t *Trigger) send(ctx context.Context, reasons []string) error {
+	parameters, err := t.parameterRepo.ListSupportedParameters()
+	if err != nil {
+		return fmt.Errorf("failed to list parameters: %w", err)
+	}
+	parameters = slices.Sorted(slices.Values(parameters))
+
+	data, err := json.Marshal(payload{
+		Reasons:         reasons,
+		SchemaVersion:   classifier.SchemaVersion(parameters),
+		Parameters:      parameters,
+		TrainingDataURL: t.trainingDataURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.token)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("model service responded %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package retrain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriggerCollectsReasons(t *testing.T) {
+	trigger := NewTrigger("http://model/retrain", "", "", nil, time.Second)
+	trigger.Notify(ParametersChanged)
+	trigger.Notify(ClassesChanged)
+	trigger.Notify(ParametersChanged)
+
+	assert.Equal(t, []string{ClassesChanged, ParametersChanged}, trigger.take())
+	assert.Empty(t, trigger.take())
+
+	trigger.restore([]string{Manual})
+	assert.Equal(t, []string{Manual}, trigger.take())
+}
+
+func TestTriggerDisabled(t *testing.T) {
+	var missing *Trigger
+	assert.False(t, missing.Enabled())
+	missing.Notify(Manual)
+
+	trigger := NewTrigger("", "", "", nil, time.Second)
+	trigger.Notify(Manual)
+	assert.Empty(t, trigger.take())
+}
@@ -26,16 +26,26 @@ func SetupRouter(h *handlers.Handler) *gin.Engine {
 	serviceGroup := r.Group("/services")
 	{
 		serviceGroup.POST("", h.CreateService)
+		serviceGroup.POST("/validate", h.ValidateService)
+		serviceGroup.POST("/batch", h.CreateServiceBatch)
+		serviceGroup.GET("/batch/:id", h.GetServiceBatch)
+		serviceGroup.POST("/import", h.ImportServices)
 		serviceGroup.GET("", h.ListServices)
 		serviceGroup.GET("/:id", h.GetServiceByID)
+		serviceGroup.PUT("/:id", h.UpdateService)
+		serviceGroup.DELETE("/:id", h.DeleteService)
 
 		serviceGroup.POST("/:id/approve", h.ApproveService)
+		serviceGroup.POST("/:id/reject", h.RejectService)
+		serviceGroup.POST("/:id/reopen", h.ReopenService)
 		serviceGroup.GET("/:id/proposed_classes", h.ListProposedClasses)
+		serviceGroup.GET("/:id/prediction", h.GetServicePrediction)
 	}
 
 	classGroup := r.Group("/classes")
 	{
 		classGroup.GET("", h.ListClasses)
+		classGroup.GET("/tree", h.GetClassTree)
 		classGroup.GET("/:id", h.GetClassByID)
 		classGroup.POST("", h.CreateClass)
 		classGroup.PUT("/:id", h.UpdateClass)
@@ -51,7 +61,44 @@ func SetupRouter(h *handlers.Handler) *gin.Engine {
 		parameterGroup.DELETE("/:id", h.DeleteParameter)
 	}
 
+	exportGroup := r.Group("/export")
+	{
+		exportGroup.GET("/services", h.ExportServices)
+		exportGroup.GET("/classes", h.ExportClasses)
+		exportGroup.GET("/parameters", h.ExportParameters)
+	}
+
+	r.GET("/review-queue", h.ListReviewQueue)
+
+	mlGroup := r.Group("/ml")
+	{
+		mlGroup.GET("/training-data", h.ExportTrainingData)
+		mlGroup.GET("/schema", h.GetFeatureSchema)
+		mlGroup.POST("/retrain", h.TriggerRetraining)
+	}
+
+	revenueGroup := r.Group("/revenue")
+	{
+		revenueGroup.GET("", h.ListRevenue)
+		revenueGroup.POST("", h.CreateRevenue)
+		revenueGroup.POST("/import", h.ImportRevenue)
+	}
+
+	r.GET("/metrics/classification", h.GetClassificationMetrics)
 	r.GET("/report", h.BuildReport)
 
+	reportGroup := r.Group("/reports")
+	{
+		reportGroup.GET("", h.ListReports)
+		reportGroup.GET("/:id", h.DownloadReport)
+	}
+
+	adminGroup := r.Group("/admin")
+	{
+		adminGroup.GET("/outbox", h.ListOutbox)
+		adminGroup.POST("/outbox/:id/retry", h.RetryOutboxEvent)
+		adminGroup.POST("/reconcile", h.Reconcile)
+	}
+
 	return r
 }
@@ -1,39 +1,224 @@
 package services
 
 import (
-	"backend/internal/apache_jena"
+	"backend/internal/knowledge_base"
 	"backend/internal/models"
+	"backend/internal/outbox"
 	"backend/internal/repositories"
-	"context"
+	"backend/internal/retrain"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrParentNotFound = errors.New("parent class not found")
+	ErrClassCycle     = errors.New("class cannot be its own ancestor")
 )
 
 type ClassService struct {
 	ClassRepository repositories.ClassRepository
-	jenaService     *apache_jena.Service
+	db              *gorm.DB
+	outbox          *outbox.Outbox
+	retrain         *retrain.Trigger
 }
 
-func NewClassService(classRepository repositories.ClassRepository, service *apache_jena.Service) *ClassService {
+func NewClassService(db *gorm.DB, classRepository repositories.ClassRepository, outbox *outbox.Outbox, retrain *retrain.Trigger) *ClassService {
 	return &ClassService{
 		ClassRepository: classRepository,
-		jenaService:     service,
+		db:              db,
+		outbox:          outbox,
+		retrain:         retrain,
 	}
 }
 
 func (s *ClassService) CreateClass(classView models.ClassView, new bool) (*models.Class, error) {
+	if err := knowledge_base.ValidateClassView(classView); err != nil {
+		return nil, err
+	}
+
 	model := &models.Class{
-		ID:    classView.ID,
-		Title: classView.Title,
-		New:   new,
+		ID:       classView.ID,
+		Title:    classView.Title,
+		ParentID: classView.ParentID,
+		New:      new,
 	}
-	err := s.ClassRepository.Create(model)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.ClassRepository.WithTx(tx)
+		if err := validateParent(repo, classView.ID, classView.ParentID); err != nil {
+			return err
+		}
+		if err := repo.Create(model); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(tx, outbox.AddClass, classView)
+	})
 	if err != nil {
 		return model, err
 	}
+	s.outbox.Notify()
+	s.retrain.Notify(retrain.ClassesChanged)
+
+	return model, nil
+}
+
+// UpdateClass replaces the class stored under id. When the view carries a
+// different ID the class is re-created under the new one.
+func (s *ClassService) UpdateClass(id uint, classView models.ClassView) (*models.Class, error) {
+	if err := knowledge_base.ValidateClassView(classView); err != nil {
+		return nil, err
+	}
 
-	err = s.jenaService.AddClass(context.TODO(), classView)
+	model := &models.Class{
+		ID:       classView.ID,
+		Title:    classView.Title,
+		ParentID: classView.ParentID,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.ClassRepository.WithTx(tx)
+		if err := validateParent(repo, classView.ID, classView.ParentID); err != nil {
+			return err
+		}
+		if id == classView.ID {
+			if err := repo.Update(model); err != nil {
+				return err
+			}
+			return s.outbox.Enqueue(tx, outbox.UpdateClass, classView)
+		}
+
+		if err := repo.Delete(id); err != nil {
+			return err
+		}
+		if err := repo.Create(model); err != nil {
+			return err
+		}
+		if err := s.outbox.Enqueue(tx, outbox.DeleteClass, id); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(tx, outbox.AddClass, classView)
+	})
 	if err != nil {
 		return model, err
 	}
+	s.outbox.Notify()
+	s.retrain.Notify(retrain.ClassesChanged)
 
 	return model, nil
 }
+
+func (s *ClassService) DeleteClass(id uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.ClassRepository.WithTx(tx).Delete(id); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(tx, outbox.DeleteClass, id)
+	})
+	if err != nil {
+		return err
+	}
+	s.outbox.Notify()
+	s.retrain.Notify(retrain.ClassesChanged)
+
+	return nil
+}
+
+// validateParent makes sure the parent exists and that walking up from it
+// never reaches the class itself.
+func validateParent(repo repositories.ClassRepository, id uint, parentID *uint) error {
+	visited := make(map[uint]struct{})
+	for next := parentID; next != nil; {
+		if *next == id {
+			return ErrClassCycle
+		}
+		if _, ok := visited[*next]; ok {
+			return ErrClassCycle
+		}
+		visited[*next] = struct{}{}
+
+		parent, err := repo.GetByID(*next)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %d", ErrParentNotFound, *next)
+		}
+		if err != nil {
+			return err
+		}
+		next = parent.ParentID
+	}
+	return nil
+}
+
+// Tree returns the class hierarchy. Classes whose parent is unknown are
+// treated as roots, and so is the class with the lowest ID of every parent
+// cycle.
+func (s *ClassService) Tree() ([]*models.ClassNode, error) {
+	classes, err := s.ClassRepository.List(0, -1)
+	if err != nil {
+		return nil, err
+	}
+	return BuildClassTree(classes), nil
+}
+
+func BuildClassTree(classes []models.Class) []*models.ClassNode {
+	nodes := make(map[uint]*models.ClassNode, len(classes))
+	parents := make(map[uint]uint, len(classes))
+	for _, class := range classes {
+		nodes[class.ID] = &models.ClassNode{ID: class.ID, Title: class.Title, Children: []*models.ClassNode{}}
+		if class.ParentID != nil {
+			parents[class.ID] = *class.ParentID
+		}
+	}
+	cycleRoots := findCycleRoots(parents)
+
+	sorted := make([]models.Class, len(classes))
+	copy(sorted, classes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	roots := []*models.ClassNode{}
+	for _, class := range sorted {
+		node := nodes[class.ID]
+		_, cycleRoot := cycleRoots[class.ID]
+		if class.ParentID != nil && !cycleRoot {
+			if parent, ok := nodes[*class.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		if cycleRoot {
+			slog.Warn("Class is part of a parent cycle, showing it as a root", slog.Uint64("id", uint64(class.ID)))
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+// findCycleRoots walks up from every class and returns the lowest ID of
+// each parent cycle found on the way.
+func findCycleRoots(parents map[uint]uint) map[uint]struct{} {
+	roots := make(map[uint]struct{})
+	done := make(map[uint]struct{}, len(parents))
+	for start := range parents {
+		onPath := make(map[uint]int)
+		var path []uint
+		id, ok := start, true
+		for ok {
+			if _, seen := done[id]; seen {
+				break
+			}
+			if i, seen := onPath[id]; seen {
+				roots[slices.Min(path[i:])] = struct{}{}
+				break
+			}
+			onPath[id] = len(path)
+			path = append(path, id)
+			id, ok = parents[id]
+		}
+		for _, id := range path {
+			done[id] = struct{}{}
+		}
+	}
+	return roots
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"backend/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildClassTree(t *testing.T) {
+	telephony := uint(3000)
+	fixed := uint(3001)
+	unknown := uint(42)
+
+	tree := BuildClassTree([]models.Class{
+		{ID: 3002, Title: "Fixed internet", ParentID: &fixed},
+		{ID: 3001, Title: "Fixed telephony", ParentID: &telephony},
+		{ID: 3000, Title: "Telephony"},
+		{ID: 9, Title: "SMS"},
+		{ID: 10, Title: "Orphan", ParentID: &unknown},
+	})
+
+	leaf := &models.ClassNode{ID: 3002, Title: "Fixed internet", Children: []*models.ClassNode{}}
+	assert.Equal(t, []*models.ClassNode{
+		{ID: 9, Title: "SMS", Children: []*models.ClassNode{}},
+		{ID: 10, Title: "Orphan", Children: []*models.ClassNode{}},
+		{ID: 3000, Title: "Telephony", Children: []*models.ClassNode{
+			{ID: 3001, Title: "Fixed telephony", Children: []*models.ClassNode{leaf}},
+		}},
+	}, tree)
+}
+
+func TestBuildClassTreeCycle(t *testing.T) {
+	parent := func(id uint) *uint { return &id }
+
+	tree := BuildClassTree([]models.Class{
+		{ID: 1, Title: "Root"},
+		{ID: 7, Title: "Loop", ParentID: parent(7)},
+		{ID: 21, Title: "Cycle B", ParentID: parent(20)},
+		{ID: 20, Title: "Cycle A", ParentID: parent(22)},
+		{ID: 22, Title: "Cycle C", ParentID: parent(21)},
+		{ID: 30, Title: "Below cycle", ParentID: parent(22)},
+	})
+
+	assert.Equal(t, []*models.ClassNode{
+		{ID: 1, Title: "Root", Children: []*models.ClassNode{}},
+		{ID: 7, Title: "Loop", Children: []*models.ClassNode{}},
+		{ID: 20, Title: "Cycle A", Children: []*models.ClassNode{
+			{ID: 21, Title: "Cycle B", Children: []*models.ClassNode{
+				{ID: 22, Title: "Cycle C", Children: []*models.ClassNode{
+					{ID: 30, Title: "Below cycle", Children: []*models.ClassNode{}},
+				}},
+			}},
+		}},
+	}, tree)
+}
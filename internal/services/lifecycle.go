@@ -0,0 +1,46 @@
+package services
+
+import (
+	"backend/internal/models"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidTransition = errors.New("invalid service status transition")
+
+// serviceTransitions lists the statuses a service may move to from each
+// status. Approved and rejected services have to be reopened before anything
+// else can happen to them.
+var serviceTransitions = map[models.ServiceStatus][]models.ServiceStatus{
+	models.ServiceDraft: {
+		models.ServiceAwaitingPrediction, models.ServiceNeedsReview, models.ServiceApproved, models.ServiceRejected,
+	},
+	models.ServiceAwaitingPrediction: {
+		models.ServicePredicted, models.ServiceNeedsReview, models.ServiceApproved, models.ServiceRejected,
+	},
+	models.ServicePredicted: {
+		models.ServiceAwaitingPrediction, models.ServiceNeedsReview, models.ServiceApproved, models.ServiceRejected,
+	},
+	models.ServiceNeedsReview: {
+		models.ServiceAwaitingPrediction, models.ServiceApproved, models.ServiceRejected,
+	},
+	models.ServiceApproved: {models.ServiceReopened},
+	models.ServiceRejected: {models.ServiceReopened},
+	models.ServiceReopened: {
+		models.ServiceAwaitingPrediction, models.ServiceNeedsReview, models.ServiceApproved, models.ServiceRejected,
+	},
+}
+
+func CanTransition(from, to models.ServiceStatus) bool {
+	return slices.Contains(serviceTransitions[from], to)
+}
+
+// transition moves the service to status or explains why it cannot.
+func transition(service *models.Service, status models.ServiceStatus) error {
+	if !CanTransition(service.Status, status) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, service.Status, status)
+	}
+	service.Status = status
+	return nil
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"backend/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    models.ServiceStatus
+		to      models.ServiceStatus
+		wantErr bool
+	}{
+		{name: "Prediction requested", from: models.ServiceDraft, to: models.ServiceAwaitingPrediction},
+		{name: "Prediction stored", from: models.ServiceAwaitingPrediction, to: models.ServicePredicted},
+		{name: "Approve predicted", from: models.ServicePredicted, to: models.ServiceApproved},
+		{name: "Reject under review", from: models.ServiceNeedsReview, to: models.ServiceRejected},
+		{name: "Reopen approved", from: models.ServiceApproved, to: models.ServiceReopened},
+		{name: "Approve reopened", from: models.ServiceReopened, to: models.ServiceApproved},
+		{name: "Approve twice", from: models.ServiceApproved, to: models.ServiceApproved, wantErr: true},
+		{name: "Reject approved", from: models.ServiceApproved, to: models.ServiceRejected, wantErr: true},
+		{name: "Approve rejected", from: models.ServiceRejected, to: models.ServiceApproved, wantErr: true},
+		{name: "Reopen draft", from: models.ServiceDraft, to: models.ServiceReopened, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &models.Service{Status: tt.from}
+			err := transition(service, tt.to)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidTransition)
+				assert.Equal(t, tt.from, service.Status)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.to, service.Status)
+		})
+	}
+}
@@ -1,39 +1,115 @@
 package services
 
 import (
-	"backend/internal/apache_jena"
+	"backend/internal/knowledge_base"
 	"backend/internal/models"
+	"backend/internal/outbox"
 	"backend/internal/repositories"
-	"context"
+	"backend/internal/retrain"
+
+	"gorm.io/gorm"
 )
 
 type ParameterService struct {
 	ParameterRepository repositories.ParameterRepository
-	jenaService         *apache_jena.Service
+	db                  *gorm.DB
+	outbox              *outbox.Outbox
+	retrain             *retrain.Trigger
 }
 
-func NewParameterService(parameterRepo repositories.ParameterRepository, service *apache_jena.Service) *ParameterService {
+func NewParameterService(db *gorm.DB, parameterRepo repositories.ParameterRepository, outbox *outbox.Outbox, retrain *retrain.Trigger) *ParameterService {
 	return &ParameterService{
 		ParameterRepository: parameterRepo,
-		jenaService:         service,
+		db:                  db,
+		outbox:              outbox,
+		retrain:             retrain,
+	}
+}
+
+func validateParameterView(parameter models.ParameterView) error {
+	if err := knowledge_base.ValidateParameterID(parameter.ID); err != nil {
+		return err
 	}
+	return knowledge_base.ValidateParameterIDs(parameter.ContradictionParameters)
 }
 
 func (s *ParameterService) CreateParameter(parameter models.ParameterView, new bool) (*models.Parameter, error) {
+	if err := validateParameterView(parameter); err != nil {
+		return nil, err
+	}
+
 	model := &models.Parameter{
 		ID:    parameter.ID,
 		Title: parameter.Title,
 		New:   new,
 	}
-	err := s.ParameterRepository.Create(model)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.ParameterRepository.WithTx(tx).Create(model); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(tx, outbox.AddParameter, parameter)
+	})
 	if err != nil {
 		return model, err
 	}
+	s.outbox.Notify()
+	s.retrain.Notify(retrain.ParametersChanged)
+
+	return model, nil
+}
+
+// UpdateParameter replaces the parameter stored under id. When the view
+// carries a different ID the parameter is re-created under the new one.
+func (s *ParameterService) UpdateParameter(id string, parameter models.ParameterView) (*models.Parameter, error) {
+	if err := validateParameterView(parameter); err != nil {
+		return nil, err
+	}
 
-	err = s.jenaService.AddParameter(context.TODO(), parameter)
+	model := &models.Parameter{
+		ID:    parameter.ID,
+		Title: parameter.Title,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.ParameterRepository.WithTx(tx)
+		if id == parameter.ID {
+			if err := repo.Update(model); err != nil {
+				return err
+			}
+			return s.outbox.Enqueue(tx, outbox.UpdateParameter, parameter)
+		}
+
+		if err := repo.Delete(id); err != nil {
+			return err
+		}
+		if err := repo.Create(model); err != nil {
+			return err
+		}
+		if err := s.outbox.Enqueue(tx, outbox.DeleteParameter, id); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(tx, outbox.AddParameter, parameter)
+	})
 	if err != nil {
 		return model, err
 	}
+	s.outbox.Notify()
+	s.retrain.Notify(retrain.ParametersChanged)
 
 	return model, nil
 }
+
+func (s *ParameterService) DeleteParameter(id string) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.ParameterRepository.WithTx(tx).Delete(id); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(tx, outbox.DeleteParameter, id)
+	})
+	if err != nil {
+		return err
+	}
+	s.outbox.Notify()
+	s.retrain.Notify(retrain.ParametersChanged)
+
+	return nil
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"backend/internal/models"
+	"backend/internal/outbox"
+	"backend/internal/prediction"
+	"backend/internal/repositories"
+	"errors"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrServiceApproved is returned when an approved service is changed without
+// reopening it first.
+var ErrServiceApproved = errors.New("approved service has to be reopened first")
+
+// ServiceService owns the state changes of services that have to reach the
+// knowledge base. Every status change goes through transition and is only
+// written while the stored status is still the one it started from, so a
+// concurrent decision fails with repositories.ErrStatusConflict instead of
+// being overwritten.
+type ServiceService struct {
+	ServiceRepository repositories.ServiceRepository
+	db                *gorm.DB
+	outbox            *outbox.Outbox
+	predictions       *prediction.Queue
+	resultRepo        repositories.ServicePredictionRepository
+	feedbackRepo      repositories.ApprovalFeedbackRepository
+	batchRepo         repositories.ServiceBatchRepository
+}
+
+func NewServiceService(db *gorm.DB, serviceRepo repositories.ServiceRepository, resultRepo repositories.ServicePredictionRepository, feedbackRepo repositories.ApprovalFeedbackRepository, batchRepo repositories.ServiceBatchRepository, outbox *outbox.Outbox, predictions *prediction.Queue) *ServiceService {
+	return &ServiceService{
+		ServiceRepository: serviceRepo,
+		db:                db,
+		outbox:            outbox,
+		predictions:       predictions,
+		resultRepo:        resultRepo,
+		feedbackRepo:      feedbackRepo,
+		batchRepo:         batchRepo,
+	}
+}
+
+// CreateService stores a new service waiting for the model and queues its
+// prediction job in the same transaction.
+func (s *ServiceService) CreateService(service *models.Service) error {
+	service.Status = models.ServiceAwaitingPrediction
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.ServiceRepository.WithTx(tx).Create(service); err != nil {
+			return err
+		}
+		return s.predictions.Enqueue(tx, service.ID)
+	})
+	if err != nil {
+		return err
+	}
+	s.predictions.Notify()
+
+	return nil
+}
+
+// CreateBatch stores the services of one upload in a single transaction and
+// queues a prediction job for each of them. total is the number of submitted
+// items including the ones that failed validation.
+func (s *ServiceService) CreateBatch(total int, services []*models.Service) (*models.ServiceBatch, error) {
+	batch := &models.ServiceBatch{Total: total, Created: len(services)}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.batchRepo.WithTx(tx).Create(batch); err != nil {
+			return err
+		}
+		repo := s.ServiceRepository.WithTx(tx)
+		for _, service := range services {
+			service.Status = models.ServiceAwaitingPrediction
+			service.BatchID = &batch.ID
+			if err := repo.Create(service); err != nil {
+				return err
+			}
+			if err := s.predictions.Enqueue(tx, service.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(services) > 0 {
+		s.predictions.Notify()
+	}
+
+	return batch, nil
+}
+
+// ApplyPrediction stores the class suggested by the model.
+func (s *ServiceService) ApplyPrediction(service *models.Service, class *models.Class) error {
+	from := service.Status
+	if err := transition(service, models.ServicePredicted); err != nil {
+		return err
+	}
+	service.Class = class
+	service.ClassID = &class.ID
+	return s.ServiceRepository.UpdateColumnsFrom(service, from, "Status", "ClassID")
+}
+
+// MarkNeedsReview hands the service over to an expert, e.g. when the model
+// failed or suggested an invalid class.
+func (s *ServiceService) MarkNeedsReview(service *models.Service) error {
+	from := service.Status
+	if err := transition(service, models.ServiceNeedsReview); err != nil {
+		return err
+	}
+	return s.ServiceRepository.UpdateColumnsFrom(service, from, "Status")
+}
+
+// ApproveService assigns class to the service, marks it approved and
+// schedules it to be added to the knowledge base. A non-empty justification
+// records that the class rules were overridden. The approved class is
+// compared with the latest prediction for retraining.
+func (s *ServiceService) ApproveService(service *models.Service, class *models.Class, justification string) error {
+	from := service.Status
+	if err := transition(service, models.ServiceApproved); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	service.Class = class
+	service.ClassID = &class.ID
+	service.ApprovedAt = &now
+	service.ApprovalOverridden = justification != ""
+	service.OverrideJustification = justification
+	service.RejectionReason = ""
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := s.ServiceRepository.WithTx(tx).UpdateColumnsFrom(service, from,
+			"Status", "ClassID", "ApprovedAt", "ApprovalOverridden", "OverrideJustification", "RejectionReason")
+		if err != nil {
+			return err
+		}
+		predictions, err := s.resultRepo.WithTx(tx).ListLatestByServiceID(service.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.feedbackRepo.WithTx(tx).Create(approvalFeedback(service, predictions)); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(tx, outbox.AddService, service)
+	})
+	if err != nil {
+		return err
+	}
+	s.outbox.Notify()
+
+	return nil
+}
+
+// RejectService closes the service without a class.
+func (s *ServiceService) RejectService(service *models.Service, reason string) error {
+	from := service.Status
+	if err := transition(service, models.ServiceRejected); err != nil {
+		return err
+	}
+	service.RejectionReason = reason
+	return s.ServiceRepository.UpdateColumnsFrom(service, from, "Status", "RejectionReason")
+}
+
+// ReopenService puts an approved or rejected service back under review. An
+// approved service loses its approval and is removed from the knowledge
+// base, the class stays as a suggestion.
+func (s *ServiceService) ReopenService(service *models.Service) error {
+	from := service.Status
+	wasApproved := from == models.ServiceApproved
+	if err := transition(service, models.ServiceReopened); err != nil {
+		return err
+	}
+	service.ApprovedAt = nil
+	service.ApprovalOverridden = false
+	service.OverrideJustification = ""
+	service.RejectionReason = ""
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := s.ServiceRepository.WithTx(tx).UpdateColumnsFrom(service, from,
+			"Status", "ApprovedAt", "ApprovalOverridden", "OverrideJustification", "RejectionReason")
+		if err != nil {
+			return err
+		}
+		if !wasApproved {
+			return nil
+		}
+		return s.outbox.Enqueue(tx, outbox.DeleteService, service.ID)
+	})
+	if err != nil {
+		return err
+	}
+	if wasApproved {
+		s.outbox.Notify()
+	}
+
+	return nil
+}
+
+// UpdateService changes the title and parameters of a service that is not
+// approved. A parameter change drops the suggested class and, when the
+// status allows it, queues a new prediction.
+func (s *ServiceService) UpdateService(service *models.Service, title string, parameters []models.Parameter) error {
+	if service.Status == models.ServiceApproved {
+		return ErrServiceApproved
+	}
+
+	from := service.Status
+	parametersChanged := !slices.Equal(parameterIDs(service.Parameters), parameterIDs(parameters))
+	predict := false
+	service.Title = title
+	if parametersChanged {
+		service.Parameters = parameters
+		service.Class = nil
+		service.ClassID = nil
+		switch {
+		case service.Status == models.ServiceAwaitingPrediction:
+			predict = true
+		case CanTransition(service.Status, models.ServiceAwaitingPrediction):
+			if err := transition(service, models.ServiceAwaitingPrediction); err != nil {
+				return err
+			}
+			predict = true
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.ServiceRepository.WithTx(tx)
+		if err := repo.UpdateColumnsFrom(service, from, "Title", "ClassID", "Status"); err != nil {
+			return err
+		}
+		if !parametersChanged {
+			return nil
+		}
+		if err := repo.ReplaceParameters(service, parameters); err != nil {
+			return err
+		}
+		if !predict {
+			return nil
+		}
+		return s.predictions.Enqueue(tx, service.ID)
+	})
+	if err != nil {
+		return err
+	}
+	if predict {
+		s.predictions.Notify()
+	}
+
+	return nil
+}
+
+// DeleteService removes a service that is not approved. The graph is
+// cleaned up as well in case an earlier removal did not reach it.
+func (s *ServiceService) DeleteService(service *models.Service) error {
+	if service.Status == models.ServiceApproved {
+		return ErrServiceApproved
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.ServiceRepository.WithTx(tx).DeleteFrom(service.ID, service.Status); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(tx, outbox.DeleteService, service.ID)
+	})
+	if err != nil {
+		return err
+	}
+	s.outbox.Notify()
+
+	return nil
+}
+
+// approvalFeedback compares the approved class of service with its ranked
+// predictions.
+func approvalFeedback(service *models.Service, predictions []models.ServicePrediction) *models.ApprovalFeedback {
+	feedback := &models.ApprovalFeedback{
+		ServiceID:    service.ID,
+		FinalClassID: *service.ClassID,
+		Overridden:   service.ApprovalOverridden,
+		ApprovedAt:   *service.ApprovedAt,
+	}
+	if len(predictions) == 0 {
+		return feedback
+	}
+
+	top := predictions[0]
+	feedback.PredictedClassID = &top.ClassID
+	feedback.PredictedProbability = &top.Probability
+	feedback.ModelVersion = top.ModelVersion
+	feedback.Accepted = top.ClassID == feedback.FinalClassID
+	for _, prediction := range predictions {
+		if prediction.ClassID == feedback.FinalClassID {
+			feedback.FinalClassRank = prediction.Rank
+			break
+		}
+	}
+	return feedback
+}
+
+func parameterIDs(parameters []models.Parameter) []string {
+	ids := make([]string, 0, len(parameters))
+	for _, parameter := range parameters {
+		ids = append(ids, parameter.ID)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
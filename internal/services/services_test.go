@@ -0,0 +1,56 @@
+package services
+
+import (
+	"backend/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalFeedback(t *testing.T) {
+	now := time.Now()
+	classID := uint(9)
+	service := &models.Service{ID: 3, ClassID: &classID, ApprovedAt: &now}
+	predictions := []models.ServicePrediction{
+		{Rank: 1, ClassID: 29, Probability: 0.6, ModelVersion: "v2"},
+		{Rank: 2, ClassID: 9, Probability: 0.3, ModelVersion: "v2"},
+	}
+	confident := []models.ServicePrediction{{Rank: 1, ClassID: 9, Probability: 0.9, ModelVersion: "v3"}}
+
+	tests := []struct {
+		name        string
+		predictions []models.ServicePrediction
+		want        *models.ApprovalFeedback
+	}{
+		{
+			name:        "Without prediction",
+			predictions: nil,
+			want:        &models.ApprovalFeedback{ServiceID: 3, FinalClassID: 9, ApprovedAt: now},
+		},
+		{
+			name:        "Second suggestion chosen",
+			predictions: predictions,
+			want: &models.ApprovalFeedback{
+				ServiceID: 3, FinalClassID: 9, ApprovedAt: now,
+				PredictedClassID: &predictions[0].ClassID, PredictedProbability: &predictions[0].Probability,
+				ModelVersion: "v2", FinalClassRank: 2,
+			},
+		},
+		{
+			name:        "Top prediction accepted",
+			predictions: confident,
+			want: &models.ApprovalFeedback{
+				ServiceID: 3, FinalClassID: 9, ApprovedAt: now,
+				PredictedClassID: &confident[0].ClassID, PredictedProbability: &confident[0].Probability,
+				ModelVersion: "v3", FinalClassRank: 1, Accepted: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, approvalFeedback(service, tt.predictions))
+		})
+	}
+}
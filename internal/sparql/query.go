@@ -0,0 +1,288 @@
+package sparql
+
+import (
+	"strings"
+)
+
+type Prefix struct {
+	Name string
+	IRI  IRI
+}
+
+func writePrologue(b *strings.Builder, prefixes []Prefix) {
+	for _, prefix := range prefixes {
+		b.WriteString("PREFIX ")
+		b.WriteString(prefix.Name)
+		b.WriteString(": ")
+		b.WriteString(prefix.IRI.term())
+		b.WriteString("\n")
+	}
+}
+
+// Pattern is an element of a group graph pattern (a WHERE clause).
+type Pattern interface {
+	writePattern(b *strings.Builder, indent string)
+}
+
+type Triple struct {
+	Subject   Term
+	Predicate Term
+	Object    Term
+}
+
+func T(subject, predicate, object Term) Triple {
+	return Triple{Subject: subject, Predicate: predicate, Object: object}
+}
+
+func (t Triple) writePattern(b *strings.Builder, indent string) {
+	b.WriteString(indent)
+	b.WriteString(t.Subject.term())
+	b.WriteString(" ")
+	b.WriteString(t.Predicate.term())
+	b.WriteString(" ")
+	b.WriteString(t.Object.term())
+	b.WriteString(" .\n")
+}
+
+// Values is an inline data block binding a single variable.
+type Values struct {
+	Var   Var
+	Terms []Term
+}
+
+func (v Values) writePattern(b *strings.Builder, indent string) {
+	b.WriteString(indent)
+	b.WriteString("VALUES ")
+	b.WriteString(v.Var.term())
+	b.WriteString(" {")
+	for _, term := range v.Terms {
+		b.WriteString(" ")
+		b.WriteString(term.term())
+	}
+	b.WriteString(" }\n")
+}
+
+type filter struct {
+	expr Expr
+}
+
+func Filter(expr Expr) Pattern {
+	return filter{expr: expr}
+}
+
+func (f filter) writePattern(b *strings.Builder, indent string) {
+	b.WriteString(indent)
+	b.WriteString("FILTER(")
+	b.WriteString(f.expr.text)
+	b.WriteString(")\n")
+}
+
+type optional []Pattern
+
+func Optional(patterns ...Pattern) Pattern {
+	return optional(patterns)
+}
+
+func (o optional) writePattern(b *strings.Builder, indent string) {
+	b.WriteString(indent)
+	b.WriteString("OPTIONAL {\n")
+	writePatterns(b, o, indent+"\t")
+	b.WriteString(indent)
+	b.WriteString("}\n")
+}
+
+func writePatterns[P Pattern](b *strings.Builder, patterns []P, indent string) {
+	for _, pattern := range patterns {
+		pattern.writePattern(b, indent)
+	}
+}
+
+func writeGroup[P Pattern](b *strings.Builder, keyword string, patterns []P) {
+	b.WriteString(keyword)
+	b.WriteString(" {\n")
+	writePatterns(b, patterns, "\t")
+	b.WriteString("}")
+}
+
+// Expr is a rendered SPARQL expression. It can only be produced by the
+// functions below, which render their operands as terms.
+type Expr struct {
+	text string
+}
+
+func Equal(a, b Term) Expr {
+	return Expr{text: a.term() + " = " + b.term()}
+}
+
+func NotEqual(a, b Term) Expr {
+	return Expr{text: a.term() + " != " + b.term()}
+}
+
+func Count(v Var, distinct bool) Expr {
+	return Expr{text: "COUNT(" + distinctKeyword(distinct) + v.term() + ")"}
+}
+
+func GroupConcat(v Var, separator string, distinct bool) Expr {
+	return Expr{text: "GROUP_CONCAT(" + distinctKeyword(distinct) + v.term() + "; SEPARATOR=" + String(separator).term() + ")"}
+}
+
+func distinctKeyword(distinct bool) string {
+	if distinct {
+		return "DISTINCT "
+	}
+	return ""
+}
+
+// Projection is a variable or an expression bound to a variable in a
+// SELECT clause.
+type Projection interface {
+	projection() string
+}
+
+func (v Var) projection() string {
+	return v.term()
+}
+
+type binding struct {
+	expr Expr
+	as   Var
+}
+
+func As(expr Expr, as Var) Projection {
+	return binding{expr: expr, as: as}
+}
+
+func (b binding) projection() string {
+	return "(" + b.expr.text + " AS " + b.as.term() + ")"
+}
+
+type Order struct {
+	text string
+}
+
+func Asc(v Var) Order {
+	return Order{text: "ASC(" + v.term() + ")"}
+}
+
+func Desc(v Var) Order {
+	return Order{text: "DESC(" + v.term() + ")"}
+}
+
+type Select struct {
+	Prefixes   []Prefix
+	Distinct   bool
+	Projection []Projection
+	Where      []Pattern
+	GroupBy    []Var
+	OrderBy    []Order
+}
+
+func (q Select) String() string {
+	var b strings.Builder
+	writePrologue(&b, q.Prefixes)
+
+	b.WriteString("SELECT ")
+	b.WriteString(distinctKeyword(q.Distinct))
+	for i, projection := range q.Projection {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(projection.projection())
+	}
+	b.WriteString("\n")
+
+	writeGroup(&b, "WHERE", q.Where)
+
+	if len(q.GroupBy) > 0 {
+		b.WriteString("\nGROUP BY")
+		for _, v := range q.GroupBy {
+			b.WriteString(" ")
+			b.WriteString(v.term())
+		}
+	}
+	if len(q.OrderBy) > 0 {
+		b.WriteString("\nORDER BY")
+		for _, order := range q.OrderBy {
+			b.WriteString(" ")
+			b.WriteString(order.text)
+		}
+	}
+
+	return b.String()
+}
+
+// Operation is a single SPARQL Update operation.
+type Operation interface {
+	writeOperation(b *strings.Builder)
+}
+
+type insertData []Triple
+
+// InsertData adds ground triples. Variables are not allowed in the data.
+func InsertData(triples ...Triple) Operation {
+	return insertData(triples)
+}
+
+func (o insertData) writeOperation(b *strings.Builder) {
+	writeGroup(b, "INSERT DATA", o)
+}
+
+type deleteData []Triple
+
+// DeleteData removes ground triples. Variables are not allowed in the data.
+func DeleteData(triples ...Triple) Operation {
+	return deleteData(triples)
+}
+
+func (o deleteData) writeOperation(b *strings.Builder) {
+	writeGroup(b, "DELETE DATA", o)
+}
+
+type deleteWhere []Triple
+
+// DeleteWhere removes every triple matched by the patterns.
+func DeleteWhere(patterns ...Triple) Operation {
+	return deleteWhere(patterns)
+}
+
+func (o deleteWhere) writeOperation(b *strings.Builder) {
+	writeGroup(b, "DELETE WHERE", o)
+}
+
+// Modify is a DELETE/INSERT ... WHERE operation. Either template may be empty.
+type Modify struct {
+	Delete []Triple
+	Insert []Triple
+	Where  []Pattern
+}
+
+func (o Modify) writeOperation(b *strings.Builder) {
+	if len(o.Delete) > 0 {
+		writeGroup(b, "DELETE", o.Delete)
+		b.WriteString("\n")
+	}
+	if len(o.Insert) > 0 {
+		writeGroup(b, "INSERT", o.Insert)
+		b.WriteString("\n")
+	}
+	writeGroup(b, "WHERE", o.Where)
+}
+
+type Update struct {
+	Prefixes   []Prefix
+	Operations []Operation
+}
+
+func (u Update) String() string {
+	var b strings.Builder
+	writePrologue(&b, u.Prefixes)
+
+	for i, operation := range u.Operations {
+		if i > 0 {
+			b.WriteString(" ;\n")
+		}
+		operation.writeOperation(&b)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package sparql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLocalName(t *testing.T) {
+	tests := []struct {
+		name    string
+		local   string
+		wantErr bool
+	}{
+		{name: "Plain", local: "param_mob_inet"},
+		{name: "Hyphen", local: "param_one-time_fee_for_number"},
+		{name: "Leading digit", local: "1004"},
+		{name: "Empty", local: "", wantErr: true},
+		{name: "Space", local: "param_mob inet", wantErr: true},
+		{name: "Brace", local: "param_x}", wantErr: true},
+		{name: "Comment", local: "param_x#", wantErr: true},
+		{name: "Dot", local: "param_x.", wantErr: true},
+		{name: "Leading hyphen", local: "-param", wantErr: true},
+		{name: "Non-ASCII", local: "param_интернет", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLocalName(tt.local)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLocalName)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewIRI(t *testing.T) {
+	_, err := NewIRI("http://localhost:3030/service-classification#")
+	assert.NoError(t, err)
+
+	for _, value := range []string{"", "http://a b", "http://a>", "http://a{"} {
+		_, err := NewIRI(value)
+		assert.ErrorIs(t, err, ErrInvalidIRI, value)
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		name    string
+		literal Literal
+		want    string
+	}{
+		{name: "Plain", literal: String("SMS"), want: `"SMS"`},
+		{name: "Escaped", literal: String("a \"quoted\" \\ line\nbreak"), want: `"a \"quoted\" \\ line\nbreak"`},
+		{name: "Integer", literal: Integer(-42), want: `-42`},
+		{name: "Boolean", literal: Boolean(true), want: `true`},
+		{name: "Typed", literal: Typed("2024-01-01", MustIRI("http://www.w3.org/2001/XMLSchema#date")), want: `"2024-01-01"^^<http://www.w3.org/2001/XMLSchema#date>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.literal.term())
+		})
+	}
+}
+
+func TestModify(t *testing.T) {
+	subject := MustPrefixed("", "class_1")
+	predicate := MustPrefixed("rdfs", "label")
+
+	update := Update{
+		Prefixes: []Prefix{
+			{Name: "", IRI: MustIRI("http://example.com/")},
+			{Name: "rdfs", IRI: MustIRI("http://www.w3.org/2000/01/rdf-schema#")},
+		},
+		Operations: []Operation{
+			Modify{
+				Delete: []Triple{T(subject, predicate, Var("old"))},
+				Insert: []Triple{T(subject, predicate, String(`x" } ; DROP ALL #`))},
+				Where: []Pattern{
+					Optional(T(subject, predicate, Var("old"))),
+				},
+			},
+			DeleteData(T(subject, A, MustPrefixed("", "Class"))),
+		},
+	}
+
+	require.Equal(t, `PREFIX : <http://example.com/>
+PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#>
+DELETE {
+	:class_1 rdfs:label ?old .
+}
+INSERT {
+	:class_1 rdfs:label "x\" } ; DROP ALL #" .
+}
+WHERE {
+	OPTIONAL {
+		:class_1 rdfs:label ?old .
+	}
+} ;
+DELETE DATA {
+	:class_1 a :Class .
+}`, update.String())
+}
@@ -0,0 +1,168 @@
+package sparql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidLocalName = errors.New("invalid local name")
+	ErrInvalidPrefix    = errors.New("invalid prefix")
+	ErrInvalidIRI       = errors.New("invalid IRI")
+)
+
+// localNamePattern is the subset of PN_LOCAL we are willing to emit: it never
+// needs escaping and cannot end a triple or start a comment.
+var localNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*$`)
+
+var prefixPattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_-]*)?$`)
+
+// Term is anything that can be placed in a triple: an IRI, a prefixed name,
+// a literal or a variable. Terms can only be built through the constructors
+// of this package, so raw strings never reach the query text.
+type Term interface {
+	term() string
+}
+
+// ValidateLocalName reports whether name can be used as the local part of a
+// prefixed name without escaping.
+func ValidateLocalName(name string) error {
+	if !localNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidLocalName, name)
+	}
+	return nil
+}
+
+type IRI struct {
+	value string
+}
+
+func NewIRI(value string) (IRI, error) {
+	if value == "" {
+		return IRI{}, fmt.Errorf("%w: empty", ErrInvalidIRI)
+	}
+	for _, r := range value {
+		if r <= 0x20 || strings.ContainsRune("<>\"{}|^`\\", r) {
+			return IRI{}, fmt.Errorf("%w: %q", ErrInvalidIRI, value)
+		}
+	}
+	return IRI{value: value}, nil
+}
+
+func MustIRI(value string) IRI {
+	iri, err := NewIRI(value)
+	if err != nil {
+		panic(err)
+	}
+	return iri
+}
+
+func (i IRI) term() string {
+	return "<" + i.value + ">"
+}
+
+type PrefixedName struct {
+	prefix string
+	local  string
+}
+
+func Prefixed(prefix, local string) (PrefixedName, error) {
+	if !prefixPattern.MatchString(prefix) {
+		return PrefixedName{}, fmt.Errorf("%w: %q", ErrInvalidPrefix, prefix)
+	}
+	if err := ValidateLocalName(local); err != nil {
+		return PrefixedName{}, err
+	}
+	return PrefixedName{prefix: prefix, local: local}, nil
+}
+
+func MustPrefixed(prefix, local string) PrefixedName {
+	name, err := Prefixed(prefix, local)
+	if err != nil {
+		panic(err)
+	}
+	return name
+}
+
+func (n PrefixedName) term() string {
+	return n.prefix + ":" + n.local
+}
+
+// Path is a property path used in the predicate position.
+type Path struct {
+	text string
+}
+
+// ZeroOrMore matches chains of predicate of any length, including none.
+func ZeroOrMore(predicate PrefixedName) Path {
+	return Path{text: predicate.term() + "*"}
+}
+
+func (p Path) term() string {
+	return p.text
+}
+
+type Var string
+
+func (v Var) term() string {
+	return "?" + string(v)
+}
+
+type keyword string
+
+func (k keyword) term() string {
+	return string(k)
+}
+
+// A is the rdf:type shorthand.
+const A = keyword("a")
+
+type Literal struct {
+	lexical  string
+	datatype *IRI
+	bare     bool
+}
+
+func String(value string) Literal {
+	return Literal{lexical: value}
+}
+
+func Typed(value string, datatype IRI) Literal {
+	return Literal{lexical: value, datatype: &datatype}
+}
+
+func Integer(value int64) Literal {
+	return Literal{lexical: strconv.FormatInt(value, 10), bare: true}
+}
+
+func Boolean(value bool) Literal {
+	return Literal{lexical: strconv.FormatBool(value), bare: true}
+}
+
+func (l Literal) term() string {
+	if l.bare {
+		return l.lexical
+	}
+	out := `"` + escapeString(l.lexical) + `"`
+	if l.datatype != nil {
+		out += "^^" + l.datatype.term()
+	}
+	return out
+}
+
+var stringEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+	"\b", `\b`,
+	"\f", `\f`,
+)
+
+func escapeString(value string) string {
+	return stringEscaper.Replace(value)
+}